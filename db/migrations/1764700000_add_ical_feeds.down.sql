@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_shift2bikes_events_group_code;
+
+ALTER TABLE shift2bikes_events DROP COLUMN group_code;
+
+ALTER TABLE ride_groups DROP COLUMN ical_url;
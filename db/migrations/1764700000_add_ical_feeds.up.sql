@@ -0,0 +1,7 @@
+-- Ride groups can register an iCalendar feed that the scraper keeps in sync
+ALTER TABLE ride_groups ADD COLUMN ical_url TEXT;
+
+-- Scraped rides imported from a group's feed point back to that group
+ALTER TABLE shift2bikes_events ADD COLUMN group_code TEXT;
+
+CREATE INDEX idx_shift2bikes_events_group_code ON shift2bikes_events (group_code);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_scrape_changes_event;
+DROP INDEX IF EXISTS idx_scrape_changes_run_id;
+DROP INDEX IF EXISTS idx_scrape_changes_city_changed_at;
+DROP TABLE IF EXISTS scrape_changes;
+
+ALTER TABLE shift2bikes_events DROP COLUMN source_hash;
@@ -0,0 +1,18 @@
+-- Hash of the upstream payload, used to skip rides that have not changed
+ALTER TABLE shift2bikes_events ADD COLUMN source_hash TEXT;
+
+-- Every create/update/cancel/remove a scrape run makes, with the field-level diff
+CREATE TABLE IF NOT EXISTS scrape_changes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  run_id TEXT NOT NULL,
+  composite_event_id TEXT NOT NULL,
+  citycode TEXT NOT NULL,
+  ridesource TEXT NOT NULL,
+  change_type TEXT NOT NULL,
+  diff TEXT,
+  changed_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_scrape_changes_city_changed_at ON scrape_changes (citycode, changed_at);
+CREATE INDEX idx_scrape_changes_run_id ON scrape_changes (run_id);
+CREATE INDEX idx_scrape_changes_event ON scrape_changes (composite_event_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_shift2bikes_events_removed_at;
+
+ALTER TABLE shift2bikes_events DROP COLUMN removed_at;
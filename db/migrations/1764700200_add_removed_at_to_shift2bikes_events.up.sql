@@ -0,0 +1,4 @@
+-- Set when a ride is no longer returned by its source; cleared if it comes back
+ALTER TABLE shift2bikes_events ADD COLUMN removed_at TEXT;
+
+CREATE INDEX idx_shift2bikes_events_removed_at ON shift2bikes_events (removed_at);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_geocode_cache_expires_at;
+DROP INDEX IF EXISTS idx_geocode_cache_confidence;
+
+ALTER TABLE geocode_cache DROP COLUMN expires_at;
+ALTER TABLE geocode_cache DROP COLUMN confidence;
+ALTER TABLE geocode_cache DROP COLUMN viewport;
+ALTER TABLE geocode_cache DROP COLUMN granularity;
+ALTER TABLE geocode_cache DROP COLUMN formatted_address;
+ALTER TABLE geocode_cache DROP COLUMN place_id;
+ALTER TABLE geocode_cache DROP COLUMN provider;
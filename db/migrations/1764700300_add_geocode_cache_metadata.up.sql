@@ -0,0 +1,23 @@
+-- Keep what the provider told us about each match, not just the coordinates
+ALTER TABLE geocode_cache ADD COLUMN provider TEXT;
+ALTER TABLE geocode_cache ADD COLUMN place_id TEXT;
+ALTER TABLE geocode_cache ADD COLUMN formatted_address TEXT;
+ALTER TABLE geocode_cache ADD COLUMN granularity TEXT;
+ALTER TABLE geocode_cache ADD COLUMN viewport TEXT;
+
+-- high / medium / low / unknown, derived from granularity and result types
+ALTER TABLE geocode_cache ADD COLUMN confidence TEXT;
+
+-- When the entry should be geocoded again; shorter for low confidence hits
+ALTER TABLE geocode_cache ADD COLUMN expires_at TEXT;
+
+-- Entries cached before this migration all came from Google and have no
+-- metadata, so refresh them over the next month
+UPDATE geocode_cache
+SET provider = 'google',
+    confidence = 'unknown',
+    expires_at = STRFTIME('%Y-%m-%dT%H:%M:%SZ', last_updated, '+30 days')
+WHERE provider IS NULL;
+
+CREATE INDEX idx_geocode_cache_confidence ON geocode_cache (confidence);
+CREATE INDEX idx_geocode_cache_expires_at ON geocode_cache (expires_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_geocode_cache_provider;
+DROP INDEX IF EXISTS idx_events_geocode_query;
+DROP INDEX IF EXISTS idx_shift2bikes_events_geocode_query;
+
+ALTER TABLE events DROP COLUMN geocode_query;
+ALTER TABLE shift2bikes_events DROP COLUMN geocode_query;
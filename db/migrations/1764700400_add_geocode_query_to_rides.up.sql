@@ -0,0 +1,10 @@
+-- The geocode_cache key each ride's start was looked up with, so an admin
+-- correction to that key can be applied back to every ride that used it
+ALTER TABLE shift2bikes_events ADD COLUMN geocode_query TEXT;
+ALTER TABLE events ADD COLUMN geocode_query TEXT;
+
+CREATE INDEX idx_shift2bikes_events_geocode_query ON shift2bikes_events (geocode_query);
+CREATE INDEX idx_events_geocode_query ON events (geocode_query);
+
+-- The admin API lists cache entries by provider, e.g. just the manual overrides
+CREATE INDEX idx_geocode_cache_provider ON geocode_cache (provider);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_job_runs_status;
+DROP INDEX IF EXISTS idx_job_runs_job_started_at;
+DROP TABLE IF EXISTS job_runs;
@@ -0,0 +1,23 @@
+-- One row per run of a scheduled job (scraper, token-cleaner, db-backups)
+CREATE TABLE IF NOT EXISTS job_runs (
+  id TEXT PRIMARY KEY,
+  job TEXT NOT NULL,
+  city TEXT,
+  -- running / succeeded / failed; a run left 'running' crashed before it could finish
+  status TEXT NOT NULL,
+  started_at TEXT NOT NULL,
+  finished_at TEXT,
+  duration_ms INTEGER,
+  events_seen INTEGER NOT NULL DEFAULT 0,
+  events_upserted INTEGER NOT NULL DEFAULT 0,
+  events_geocoded INTEGER NOT NULL DEFAULT 0,
+  fallbacks INTEGER NOT NULL DEFAULT 0,
+  routes_created INTEGER NOT NULL DEFAULT 0,
+  error_count INTEGER NOT NULL DEFAULT 0,
+  -- JSON array of error messages
+  errors TEXT,
+  detail TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_started_at ON job_runs (job, started_at);
+CREATE INDEX IF NOT EXISTS idx_job_runs_status ON job_runs (status);
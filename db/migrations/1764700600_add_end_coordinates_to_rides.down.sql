@@ -0,0 +1,4 @@
+ALTER TABLE events DROP COLUMN end_lng;
+ALTER TABLE events DROP COLUMN end_lat;
+ALTER TABLE shift2bikes_events DROP COLUMN end_lng;
+ALTER TABLE shift2bikes_events DROP COLUMN end_lat;
@@ -0,0 +1,12 @@
+-- Where rides that aren't loops finish, geocoded from locend / ending_location.
+-- NULL when the ride has no ending location or it couldn't be found
+ALTER TABLE shift2bikes_events ADD COLUMN end_lat REAL;
+ALTER TABLE shift2bikes_events ADD COLUMN end_lng REAL;
+ALTER TABLE events ADD COLUMN end_lat REAL;
+ALTER TABLE events ADD COLUMN end_lng REAL;
+
+-- End locations aren't part of the upstream hash, so clear it on existing
+-- rides that have one; the next scrape sees them as changed and geocodes where
+-- they finish
+UPDATE shift2bikes_events SET source_hash = NULL
+WHERE loopride = 0 AND TRIM(COALESCE(locend, '')) <> '' AND end_lat IS NULL;
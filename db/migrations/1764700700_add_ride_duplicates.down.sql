@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ride_duplicates_event_id;
+DROP INDEX IF EXISTS idx_ride_duplicates_city_status;
+DROP TABLE IF EXISTS ride_duplicates;
@@ -0,0 +1,26 @@
+-- Candidate pairs of a scraped ride and a user-submitted ride that look like
+-- the same ride posted in both places, scored by title, start time and start
+-- location. Admins confirm or reject them; confirmed pairs collapse to the
+-- submitted ride in public listings.
+CREATE TABLE IF NOT EXISTS ride_duplicates (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  composite_event_id TEXT NOT NULL REFERENCES shift2bikes_events (composite_event_id) ON DELETE CASCADE,
+  event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  city TEXT NOT NULL,
+  date TEXT NOT NULL,
+  score REAL NOT NULL,
+  title_score REAL NOT NULL,
+  time_score REAL NOT NULL,
+  distance_score REAL NOT NULL,
+  -- metres between the two start points, NULL when either has no coordinates
+  distance_m REAL,
+  -- pending / confirmed / rejected
+  status TEXT NOT NULL DEFAULT 'pending',
+  review_notes TEXT,
+  reviewed_at TEXT,
+  detected_at TEXT NOT NULL,
+  UNIQUE (composite_event_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_duplicates_city_status ON ride_duplicates (city, status);
+CREATE INDEX IF NOT EXISTS idx_ride_duplicates_event_id ON ride_duplicates (event_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ride_archive_id;
+DROP INDEX IF EXISTS idx_ride_archive_city_date;
+DROP TABLE IF EXISTS ride_archive;
@@ -0,0 +1,28 @@
+-- Every scraped ride the backfill has seen, going back years, kept apart from
+-- shift2bikes_events so history doesn't slow down the live listings. Used for
+-- historical stats and for finding the same ride in earlier years.
+CREATE TABLE IF NOT EXISTS ride_archive (
+  composite_event_id TEXT PRIMARY KEY,
+  -- the upstream event id, shared by every date of a recurring ride
+  id TEXT NOT NULL,
+  citycode TEXT NOT NULL,
+  ridesource TEXT NOT NULL,
+  title TEXT NOT NULL,
+  date TEXT NOT NULL,
+  starttime TEXT NOT NULL,
+  endtime TEXT,
+  eventduration INTEGER,
+  venue TEXT NOT NULL,
+  address TEXT NOT NULL,
+  organizer TEXT NOT NULL,
+  audience TEXT NOT NULL,
+  length TEXT,
+  area TEXT,
+  loopride INTEGER NOT NULL,
+  cancelled INTEGER NOT NULL,
+  source_data TEXT NOT NULL,
+  archived_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_archive_city_date ON ride_archive (citycode, date);
+CREATE INDEX IF NOT EXISTS idx_ride_archive_id ON ride_archive (id);
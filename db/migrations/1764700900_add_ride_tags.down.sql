@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_event_tags_tag;
+DROP INDEX IF EXISTS idx_shift2bikes_event_tags_tag;
+DROP TABLE IF EXISTS event_tags;
+DROP TABLE IF EXISTS shift2bikes_event_tags;
@@ -0,0 +1,19 @@
+-- Tags on rides, such as "family-friendly" or "gravel". Scraped rides are
+-- tagged by the scraper's keyword rules; submitted rides by the same rules plus
+-- any tags the submitter picked.
+CREATE TABLE IF NOT EXISTS shift2bikes_event_tags (
+  composite_event_id TEXT NOT NULL REFERENCES shift2bikes_events (composite_event_id) ON DELETE CASCADE,
+  tag TEXT NOT NULL,
+  PRIMARY KEY (composite_event_id, tag)
+);
+
+CREATE TABLE IF NOT EXISTS event_tags (
+  event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  tag TEXT NOT NULL,
+  -- rule / submitter
+  source TEXT NOT NULL DEFAULT 'rule',
+  PRIMARY KEY (event_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shift2bikes_event_tags_tag ON shift2bikes_event_tags (tag);
+CREATE INDEX IF NOT EXISTS idx_event_tags_tag ON event_tags (tag);
@@ -0,0 +1,8 @@
+ALTER TABLE shift2bikes_events DROP COLUMN status;
+ALTER TABLE shift2bikes_events DROP COLUMN caldaily_id;
+ALTER TABLE shift2bikes_events DROP COLUMN datestype;
+ALTER TABLE shift2bikes_events DROP COLUMN printdescr;
+ALTER TABLE shift2bikes_events DROP COLUMN tinytitle;
+ALTER TABLE shift2bikes_events DROP COLUMN featured;
+ALTER TABLE shift2bikes_events DROP COLUMN area;
+ALTER TABLE shift2bikes_events DROP COLUMN length;
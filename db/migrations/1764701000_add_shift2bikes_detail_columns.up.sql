@@ -0,0 +1,22 @@
+-- Shift2Bikes fields that used to survive only inside source_data, stored as
+-- columns so the API can return and filter on them. Existing rows are filled
+-- in from their source_data.
+ALTER TABLE shift2bikes_events ADD COLUMN length TEXT;
+ALTER TABLE shift2bikes_events ADD COLUMN area TEXT;
+ALTER TABLE shift2bikes_events ADD COLUMN featured INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE shift2bikes_events ADD COLUMN tinytitle TEXT;
+ALTER TABLE shift2bikes_events ADD COLUMN printdescr TEXT;
+ALTER TABLE shift2bikes_events ADD COLUMN datestype TEXT;
+ALTER TABLE shift2bikes_events ADD COLUMN caldaily_id TEXT;
+ALTER TABLE shift2bikes_events ADD COLUMN status TEXT;
+
+UPDATE shift2bikes_events SET
+  length = NULLIF(json_extract(source_data, '$.length'), ''),
+  area = NULLIF(json_extract(source_data, '$.area'), ''),
+  featured = COALESCE(json_extract(source_data, '$.featured'), 0),
+  tinytitle = NULLIF(json_extract(source_data, '$.tinytitle'), ''),
+  printdescr = NULLIF(json_extract(source_data, '$.printdescr'), ''),
+  datestype = NULLIF(json_extract(source_data, '$.datestype'), ''),
+  caldaily_id = NULLIF(json_extract(source_data, '$.caldaily_id'), ''),
+  status = NULLIF(json_extract(source_data, '$.status'), '')
+WHERE json_valid(source_data);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_shift2bikes_events_series;
+DROP INDEX IF EXISTS idx_ride_series_citycode;
+DROP TABLE IF EXISTS ride_series;
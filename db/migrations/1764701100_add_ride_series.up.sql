@@ -0,0 +1,23 @@
+-- Repeating rides grouped into series. A scraped ride's series is every
+-- stored date sharing its source and upstream id (series_id "Shift2Bikes:123");
+-- a submitted ride's is its occurrences (series_id "user-submitted:42").
+CREATE TABLE IF NOT EXISTS ride_series (
+  series_id TEXT PRIMARY KEY,
+  citycode TEXT NOT NULL,
+  ridesource TEXT NOT NULL,
+  title TEXT NOT NULL,
+  -- once / daily / weekly / biweekly / monthly / weekday / irregular
+  recurrence TEXT NOT NULL,
+  -- 0 (Sunday) to 6, for series held on one weekday
+  weekday INTEGER,
+  -- week of the month for monthly series, 1 to 4 or -1 for the last
+  week INTEGER,
+  summary TEXT NOT NULL,
+  first_date TEXT NOT NULL,
+  last_date TEXT NOT NULL,
+  occurrence_count INTEGER NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_series_citycode ON ride_series (citycode);
+CREATE INDEX IF NOT EXISTS idx_shift2bikes_events_series ON shift2bikes_events (citycode, ridesource, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_image_mirrors_status;
+DROP TABLE IF EXISTS image_mirrors;
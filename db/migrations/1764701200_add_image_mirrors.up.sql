@@ -0,0 +1,17 @@
+-- Optimized copies of images hosted elsewhere, such as Shift2Bikes ride
+-- photos. The scraper queues each new image URL as pending; the image
+-- optimizer fetches it, writes WebP variants next to submitted ride images and
+-- marks it done with the URL of the _optimized.webp variant.
+CREATE TABLE IF NOT EXISTS image_mirrors (
+  source_url TEXT PRIMARY KEY,
+  citycode TEXT NOT NULL,
+  -- pending / done / failed
+  status TEXT NOT NULL DEFAULT 'pending',
+  optimized_url TEXT,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  requested_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_mirrors_status ON image_mirrors (status);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_location_quality;
+DROP INDEX IF EXISTS idx_shift2bikes_events_location_quality;
+ALTER TABLE events DROP COLUMN location_quality;
+ALTER TABLE shift2bikes_events DROP COLUMN location_quality;
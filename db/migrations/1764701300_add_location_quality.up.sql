@@ -0,0 +1,32 @@
+-- Where each ride's coordinates came from: exact (given by the ride), geocoded,
+-- cached, fallback (couldn't be located) or unknown, so clients can show
+-- "location TBA" instead of a misleading pin. Existing scraped rides on a
+-- fallback point are marked fallback, and those whose geocode query is cached
+-- are marked cached; submitted rides either have coordinates from the
+-- geocoder or none.
+ALTER TABLE shift2bikes_events ADD COLUMN location_quality TEXT NOT NULL DEFAULT 'unknown';
+ALTER TABLE events ADD COLUMN location_quality TEXT NOT NULL DEFAULT 'unknown';
+
+-- Rides that couldn't be located used to be put downtown Portland whatever
+-- their city; they now go to their own city's center (centerLat/centerLng in
+-- cities.json). Move the ones stranded in Portland, which the scraper won't
+-- revisit until they change upstream, and mark both points as fallbacks.
+UPDATE shift2bikes_events SET lat = 40.760779, lng = -111.891047
+WHERE citycode = 'slc' AND lat = 45.523064 AND lng = -122.676483;
+
+UPDATE shift2bikes_events SET location_quality = 'fallback'
+WHERE (citycode = 'pdx' AND lat = 45.523064 AND lng = -122.676483)
+   OR (citycode = 'slc' AND lat = 40.760779 AND lng = -111.891047);
+
+UPDATE shift2bikes_events SET location_quality = 'cached'
+WHERE location_quality = 'unknown'
+  AND geocode_query IS NOT NULL
+  AND EXISTS (SELECT 1 FROM geocode_cache gc WHERE gc.location_key = shift2bikes_events.geocode_query);
+
+UPDATE events SET location_quality = CASE
+  WHEN latitude IS NULL OR longitude IS NULL OR (latitude = 0 AND longitude = 0) THEN 'fallback'
+  ELSE 'geocoded'
+END;
+
+CREATE INDEX IF NOT EXISTS idx_shift2bikes_events_location_quality ON shift2bikes_events (citycode, location_quality);
+CREATE INDEX IF NOT EXISTS idx_events_location_quality ON events (city, location_quality);
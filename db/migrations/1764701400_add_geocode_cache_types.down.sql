@@ -0,0 +1 @@
+ALTER TABLE geocode_cache DROP COLUMN types;
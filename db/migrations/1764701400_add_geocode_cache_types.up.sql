@@ -0,0 +1,3 @@
+-- The provider's result types as a JSON array, e.g. ["street_address"], so a
+-- cached result carries the same detail as a fresh lookup
+ALTER TABLE geocode_cache ADD COLUMN types TEXT;
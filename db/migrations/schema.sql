@@ -4,10 +4,10 @@
 --
 
 CREATE TABLE schema_migrations (id VARCHAR(255) NOT NULL PRIMARY KEY);
-CREATE TABLE shift2bikes_events (composite_event_id TEXT PRIMARY KEY, id TEXT NOT NULL, title TEXT NOT NULL, lat REAL NOT NULL, lng REAL NOT NULL, address TEXT NOT NULL, audience TEXT NOT NULL, cancelled INTEGER NOT NULL, date TEXT NOT NULL, starttime TEXT NOT NULL, safetyplan INTEGER NOT NULL, details TEXT NOT NULL, venue TEXT NOT NULL, organizer TEXT NOT NULL, loopride INTEGER NOT NULL, shareable TEXT NOT NULL, endtime TEXT, email TEXT, eventduration INTEGER, image TEXT, locdetails TEXT, locend TEXT, newsflash TEXT, timedetails TEXT, webname TEXT, weburl TEXT, citycode TEXT NOT NULL, ridesource TEXT NOT NULL, source_data TEXT NOT NULL, route_id TEXT REFERENCES routes (id) ON DELETE SET NULL);
+CREATE TABLE shift2bikes_events (composite_event_id TEXT PRIMARY KEY, id TEXT NOT NULL, title TEXT NOT NULL, lat REAL NOT NULL, lng REAL NOT NULL, address TEXT NOT NULL, audience TEXT NOT NULL, cancelled INTEGER NOT NULL, date TEXT NOT NULL, starttime TEXT NOT NULL, safetyplan INTEGER NOT NULL, details TEXT NOT NULL, venue TEXT NOT NULL, organizer TEXT NOT NULL, loopride INTEGER NOT NULL, shareable TEXT NOT NULL, endtime TEXT, email TEXT, eventduration INTEGER, image TEXT, locdetails TEXT, locend TEXT, newsflash TEXT, timedetails TEXT, webname TEXT, weburl TEXT, citycode TEXT NOT NULL, ridesource TEXT NOT NULL, source_data TEXT NOT NULL, route_id TEXT REFERENCES routes (id) ON DELETE SET NULL, group_code TEXT, source_hash TEXT, removed_at TEXT, geocode_query TEXT, end_lat REAL, end_lng REAL, length TEXT, area TEXT, featured INTEGER NOT NULL DEFAULT 0, tinytitle TEXT, printdescr TEXT, datestype TEXT, caldaily_id TEXT, status TEXT, location_quality TEXT NOT NULL DEFAULT 'unknown');
 CREATE INDEX idx_citycode ON shift2bikes_events (citycode);
 CREATE INDEX idx_date ON shift2bikes_events (date);
-CREATE TABLE geocode_cache (location_key TEXT PRIMARY KEY, lat REAL NOT NULL, lng REAL NOT NULL, city TEXT NOT NULL, last_updated TEXT NOT NULL);
+CREATE TABLE geocode_cache (location_key TEXT PRIMARY KEY, lat REAL NOT NULL, lng REAL NOT NULL, city TEXT NOT NULL, last_updated TEXT NOT NULL, provider TEXT, place_id TEXT, formatted_address TEXT, granularity TEXT, viewport TEXT, confidence TEXT, expires_at TEXT, types TEXT);
 CREATE UNIQUE INDEX idx_geocode_key ON geocode_cache (location_key);
 CREATE INDEX idx_geocode_city ON geocode_cache (city);
 CREATE TABLE event_occurrences (id INTEGER PRIMARY KEY, event_id INTEGER NOT NULL, start_date TEXT NOT NULL, start_time TEXT NOT NULL, start_datetime TEXT NOT NULL, event_duration_minutes INTEGER, event_time_details TEXT, is_cancelled INTEGER NOT NULL DEFAULT 0, newsflash TEXT, FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE CASCADE);
@@ -20,11 +20,11 @@ CREATE TABLE admin_api_keys (id INTEGER PRIMARY KEY AUTOINCREMENT, api_key TEXT
 CREATE TABLE sqlite_sequence(name,seq);
 CREATE INDEX idx_admin_api_keys_api_key ON admin_api_keys (api_key);
 CREATE INDEX idx_admin_api_keys_revoked ON admin_api_keys (revoked_at);
-CREATE TABLE "ride_groups" (id TEXT PRIMARY KEY, code TEXT UNIQUE NOT NULL, name TEXT NOT NULL, description TEXT, city TEXT, icon_url TEXT, is_active INTEGER NOT NULL DEFAULT 1, web_url TEXT, edit_token TEXT UNIQUE, created_at TEXT NOT NULL, public_id TEXT UNIQUE, marker TEXT, marker_color TEXT DEFAULT '#3B82F6', email TEXT);
+CREATE TABLE "ride_groups" (id TEXT PRIMARY KEY, code TEXT UNIQUE NOT NULL, name TEXT NOT NULL, description TEXT, city TEXT, icon_url TEXT, is_active INTEGER NOT NULL DEFAULT 1, web_url TEXT, edit_token TEXT UNIQUE, created_at TEXT NOT NULL, public_id TEXT UNIQUE, marker TEXT, marker_color TEXT DEFAULT '#3B82F6', email TEXT, ical_url TEXT);
 CREATE INDEX idx_groups_code ON ride_groups (code);
 CREATE INDEX idx_groups_edit_token ON ride_groups (edit_token);
 CREATE INDEX idx_groups_public_id ON ride_groups (public_id);
-CREATE TABLE "events" (id INTEGER PRIMARY KEY, title TEXT NOT NULL, tinytitle TEXT, description TEXT NOT NULL, image_url TEXT, audience TEXT, ride_length TEXT, area TEXT, date_type TEXT, venue_name TEXT, address TEXT, location_details TEXT, ending_location TEXT, is_loop_ride INTEGER NOT NULL DEFAULT 0, city TEXT NOT NULL, organizer_name TEXT, organizer_email TEXT, organizer_phone TEXT, web_url TEXT, web_name TEXT, newsflash TEXT, hide_email INTEGER NOT NULL DEFAULT 0, hide_phone INTEGER NOT NULL DEFAULT 0, hide_contact_name INTEGER NOT NULL DEFAULT 0, group_code TEXT, group_id TEXT, edit_token TEXT UNIQUE, is_published INTEGER NOT NULL DEFAULT 0, is_featured INTEGER NOT NULL DEFAULT 0, moderation_notes TEXT, moderated_at TEXT, created_at TEXT NOT NULL DEFAULT (STRFTIME ('%Y-%m-%d %H:%M:%f', 'NOW')), updated_at TEXT NOT NULL DEFAULT (STRFTIME ('%Y-%m-%d %H:%M:%f', 'NOW')), latitude REAL, longitude REAL, image_uuid TEXT, route_id TEXT REFERENCES routes (id) ON DELETE SET NULL, FOREIGN KEY (group_id) REFERENCES ride_groups (id) ON DELETE SET NULL);
+CREATE TABLE "events" (id INTEGER PRIMARY KEY, title TEXT NOT NULL, tinytitle TEXT, description TEXT NOT NULL, image_url TEXT, audience TEXT, ride_length TEXT, area TEXT, date_type TEXT, venue_name TEXT, address TEXT, location_details TEXT, ending_location TEXT, is_loop_ride INTEGER NOT NULL DEFAULT 0, city TEXT NOT NULL, organizer_name TEXT, organizer_email TEXT, organizer_phone TEXT, web_url TEXT, web_name TEXT, newsflash TEXT, hide_email INTEGER NOT NULL DEFAULT 0, hide_phone INTEGER NOT NULL DEFAULT 0, hide_contact_name INTEGER NOT NULL DEFAULT 0, group_code TEXT, group_id TEXT, edit_token TEXT UNIQUE, is_published INTEGER NOT NULL DEFAULT 0, is_featured INTEGER NOT NULL DEFAULT 0, moderation_notes TEXT, moderated_at TEXT, created_at TEXT NOT NULL DEFAULT (STRFTIME ('%Y-%m-%d %H:%M:%f', 'NOW')), updated_at TEXT NOT NULL DEFAULT (STRFTIME ('%Y-%m-%d %H:%M:%f', 'NOW')), latitude REAL, longitude REAL, image_uuid TEXT, route_id TEXT REFERENCES routes (id) ON DELETE SET NULL, geocode_query TEXT, end_lat REAL, end_lng REAL, location_quality TEXT NOT NULL DEFAULT 'unknown', FOREIGN KEY (group_id) REFERENCES ride_groups (id) ON DELETE SET NULL);
 CREATE INDEX idx_published ON events (is_published);
 CREATE INDEX idx_group_code ON events (group_code);
 CREATE INDEX idx_group_id ON events (group_id);
@@ -34,4 +34,35 @@ CREATE TABLE routes (id TEXT PRIMARY KEY, source TEXT NOT NULL, source_id TEXT N
 CREATE INDEX idx_routes_source_id ON routes (source, source_id);
 CREATE INDEX idx_events_route_id ON events (route_id);
 CREATE INDEX idx_shift2bikes_events_route_id ON shift2bikes_events (route_id);
-CREATE INDEX idx_routes_city ON routes (city);
\ No newline at end of file
+CREATE INDEX idx_routes_city ON routes (city);
+CREATE INDEX idx_shift2bikes_events_group_code ON shift2bikes_events (group_code);
+CREATE TABLE scrape_changes (id INTEGER PRIMARY KEY AUTOINCREMENT, run_id TEXT NOT NULL, composite_event_id TEXT NOT NULL, citycode TEXT NOT NULL, ridesource TEXT NOT NULL, change_type TEXT NOT NULL, diff TEXT, changed_at TEXT NOT NULL);
+CREATE INDEX idx_scrape_changes_city_changed_at ON scrape_changes (citycode, changed_at);
+CREATE INDEX idx_scrape_changes_run_id ON scrape_changes (run_id);
+CREATE INDEX idx_scrape_changes_event ON scrape_changes (composite_event_id);
+CREATE INDEX idx_shift2bikes_events_removed_at ON shift2bikes_events (removed_at);
+CREATE INDEX idx_geocode_cache_confidence ON geocode_cache (confidence);
+CREATE INDEX idx_geocode_cache_expires_at ON geocode_cache (expires_at);
+CREATE INDEX idx_shift2bikes_events_geocode_query ON shift2bikes_events (geocode_query);
+CREATE INDEX idx_events_geocode_query ON events (geocode_query);
+CREATE INDEX idx_geocode_cache_provider ON geocode_cache (provider);
+CREATE TABLE job_runs (id TEXT PRIMARY KEY, job TEXT NOT NULL, city TEXT, status TEXT NOT NULL, started_at TEXT NOT NULL, finished_at TEXT, duration_ms INTEGER, events_seen INTEGER NOT NULL DEFAULT 0, events_upserted INTEGER NOT NULL DEFAULT 0, events_geocoded INTEGER NOT NULL DEFAULT 0, fallbacks INTEGER NOT NULL DEFAULT 0, routes_created INTEGER NOT NULL DEFAULT 0, error_count INTEGER NOT NULL DEFAULT 0, errors TEXT, detail TEXT);
+CREATE INDEX idx_job_runs_job_started_at ON job_runs (job, started_at);
+CREATE INDEX idx_job_runs_status ON job_runs (status);
+CREATE TABLE ride_duplicates (id INTEGER PRIMARY KEY AUTOINCREMENT, composite_event_id TEXT NOT NULL REFERENCES shift2bikes_events (composite_event_id) ON DELETE CASCADE, event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE, city TEXT NOT NULL, date TEXT NOT NULL, score REAL NOT NULL, title_score REAL NOT NULL, time_score REAL NOT NULL, distance_score REAL NOT NULL, distance_m REAL, status TEXT NOT NULL DEFAULT 'pending', review_notes TEXT, reviewed_at TEXT, detected_at TEXT NOT NULL, UNIQUE (composite_event_id, event_id));
+CREATE INDEX idx_ride_duplicates_city_status ON ride_duplicates (city, status);
+CREATE INDEX idx_ride_duplicates_event_id ON ride_duplicates (event_id);
+CREATE TABLE ride_archive (composite_event_id TEXT PRIMARY KEY, id TEXT NOT NULL, citycode TEXT NOT NULL, ridesource TEXT NOT NULL, title TEXT NOT NULL, date TEXT NOT NULL, starttime TEXT NOT NULL, endtime TEXT, eventduration INTEGER, venue TEXT NOT NULL, address TEXT NOT NULL, organizer TEXT NOT NULL, audience TEXT NOT NULL, length TEXT, area TEXT, loopride INTEGER NOT NULL, cancelled INTEGER NOT NULL, source_data TEXT NOT NULL, archived_at TEXT NOT NULL);
+CREATE INDEX idx_ride_archive_city_date ON ride_archive (citycode, date);
+CREATE INDEX idx_ride_archive_id ON ride_archive (id);
+CREATE TABLE shift2bikes_event_tags (composite_event_id TEXT NOT NULL REFERENCES shift2bikes_events (composite_event_id) ON DELETE CASCADE, tag TEXT NOT NULL, PRIMARY KEY (composite_event_id, tag));
+CREATE TABLE event_tags (event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE, tag TEXT NOT NULL, source TEXT NOT NULL DEFAULT 'rule', PRIMARY KEY (event_id, tag));
+CREATE INDEX idx_shift2bikes_event_tags_tag ON shift2bikes_event_tags (tag);
+CREATE INDEX idx_event_tags_tag ON event_tags (tag);
+CREATE TABLE ride_series (series_id TEXT PRIMARY KEY, citycode TEXT NOT NULL, ridesource TEXT NOT NULL, title TEXT NOT NULL, recurrence TEXT NOT NULL, weekday INTEGER, week INTEGER, summary TEXT NOT NULL, first_date TEXT NOT NULL, last_date TEXT NOT NULL, occurrence_count INTEGER NOT NULL, updated_at TEXT NOT NULL);
+CREATE INDEX idx_ride_series_citycode ON ride_series (citycode);
+CREATE INDEX idx_shift2bikes_events_series ON shift2bikes_events (citycode, ridesource, id);
+CREATE TABLE image_mirrors (source_url TEXT PRIMARY KEY, citycode TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'pending', optimized_url TEXT, attempts INTEGER NOT NULL DEFAULT 0, last_error TEXT, requested_at TEXT NOT NULL, updated_at TEXT NOT NULL);
+CREATE INDEX idx_image_mirrors_status ON image_mirrors (status);
+CREATE INDEX idx_shift2bikes_events_location_quality ON shift2bikes_events (citycode, location_quality);
+CREATE INDEX idx_events_location_quality ON events (city, location_quality);
\ No newline at end of file
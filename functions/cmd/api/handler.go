@@ -12,13 +12,19 @@ import (
 	chimi "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/spacesedan/cyclescene/functions/internal/api/auth"
+	"github.com/spacesedan/cyclescene/functions/internal/api/duplicates"
 	"github.com/spacesedan/cyclescene/functions/internal/api/events"
+	geocodeapi "github.com/spacesedan/cyclescene/functions/internal/api/geocode"
 	"github.com/spacesedan/cyclescene/functions/internal/api/group"
+	"github.com/spacesedan/cyclescene/functions/internal/api/jobs"
 	"github.com/spacesedan/cyclescene/functions/internal/api/magiclink"
 	apimi "github.com/spacesedan/cyclescene/functions/internal/api/middleware"
 	"github.com/spacesedan/cyclescene/functions/internal/api/ride"
 	routesapi "github.com/spacesedan/cyclescene/functions/internal/api/routes"
 	"github.com/spacesedan/cyclescene/functions/internal/api/storage"
+	"github.com/spacesedan/cyclescene/functions/internal/routes"
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+	"github.com/spacesedan/cyclescene/functions/internal/tagging"
 )
 
 var allowedDomains = []string{
@@ -111,6 +117,19 @@ func NewRideAPIRouter(db *sql.DB) http.Handler {
 	} else {
 		rideService = ride.NewService(rideRepo)
 	}
+	geocoder, err := scraper.NewGeocoderFromEnv(nil)
+	if err != nil {
+		slog.Error("Failed to configure geocoder, submitted rides will not be geocoded", "error", err)
+	} else {
+		// share the scraper's geocode_cache so admin overrides apply to submissions too
+		rideService.SetGeocoder(scraper.NewCachingGeocoder(db, geocoder))
+	}
+	tagger, err := tagging.NewTaggerFromEnv()
+	if err != nil {
+		slog.Error("Failed to load tagging rules, submitted rides will only keep their submitter's tags", "error", err)
+	} else {
+		rideService.SetTagger(tagger)
+	}
 	rideHandler := ride.NewHandler(rideService, authService, eventarcClient)
 
 	groupRepo := group.NewRepository(db)
@@ -136,6 +155,16 @@ func NewRideAPIRouter(db *sql.DB) http.Handler {
 	}
 	storageHandler := storage.NewHandler(storageService)
 
+	// Routes attached to submitted rides, by RideWithGPS or Strava link or
+	// by a route file uploaded through a signed URL
+	rideService.SetRouteServices(
+		routes.NewRouteFetcher(&http.Client{Timeout: 30 * time.Second}, os.Getenv("STRAVA_ACCESS_TOKEN"), os.Getenv("RWGPS_AUTH_TOKEN"), os.Getenv("RWGPS_API_KEY")),
+		routes.NewRepository(db),
+	)
+	if storageService != nil {
+		rideService.SetRouteFiles(storageService)
+	}
+
 	// Rate limiter: 10 submissions per minute per IP
 	submissionRateLimiter := apimi.NewRateLimiter(10, time.Minute)
 
@@ -143,6 +172,20 @@ func NewRideAPIRouter(db *sql.DB) http.Handler {
 	routesRepo := routesapi.NewRepository(db)
 	routesHandler := routesapi.NewHandler(routesRepo)
 
+	// Geocode admin handler, guarded by the same admin keys as the ride admin endpoints
+	geocodeRepo := geocodeapi.NewRepository(db)
+	geocodeService := geocodeapi.NewService(geocodeRepo)
+	geocodeHandler := geocodeapi.NewHandler(geocodeService, rideService)
+
+	// Job run history for the scraper, token-cleaner and db-backups jobs
+	jobsRepo := jobs.NewRepository(db)
+	jobsHandler := jobs.NewHandler(jobsRepo, rideService)
+
+	// Review of rides posted both upstream and through ride submission
+	duplicatesRepo := duplicates.NewRepository(db)
+	duplicatesService := duplicates.NewService(duplicatesRepo)
+	duplicatesHandler := duplicates.NewHandler(duplicatesService, rideService)
+
 	r.Route("/v1", func(r chi.Router) {
 		// auth handlers -- /tokens
 		authHandler.RegisterRoutes(r)
@@ -163,13 +206,25 @@ func NewRideAPIRouter(db *sql.DB) http.Handler {
 			r.Patch("/edit/{token}/occurrences/{occurrenceId}", rideHandler.UpdateOccurrence)
 			r.Get("/admin/pending", rideHandler.GetPendingRides)
 			r.Patch("/admin/{id}/publish", rideHandler.PublishRide)
+			r.Get("/admin/changes", rideHandler.GetScrapeChanges)
 			r.Get("/upcoming", rideHandler.GetUpcomingRides)
 			r.Get("/past", rideHandler.GetPastRides)
 			r.Get("/ics", rideHandler.GenerateICS)
+			r.Get("/tags", rideHandler.GetTags)
+			r.Get("/series", rideHandler.GetSeries)
 		})
 
 		// group handlers
 		groupHandler.RegisterRoutes(r)
+
+		// geocode admin handlers -- /admin/geocode
+		geocodeHandler.RegisterRoutes(r)
+
+		// job run history -- /admin/jobs
+		jobsHandler.RegisterRoutes(r)
+
+		// duplicate ride review -- /admin/duplicates
+		duplicatesHandler.RegisterRoutes(r)
 	})
 
 	return r
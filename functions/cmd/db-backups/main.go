@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,8 @@ import (
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"github.com/spacesedan/cyclescene/functions/internal/jobruns"
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
 )
 
@@ -34,10 +37,25 @@ func main() {
 		AddSource: true,
 	})))
 
+	// the backup itself goes over HTTP; the libsql connection is only used to
+	// record the run in job_runs
+	var run *jobruns.Run
+	if dbURL := os.Getenv("TURSO_DB_URL"); dbURL != "" {
+		db, err := sql.Open("libsql", fmt.Sprintf("%s?authToken=%s", dbURL, os.Getenv("TURSO_DB_RW_TOKEN")))
+		if err != nil {
+			slog.Error("failed to open Turso DB connection, run will not be recorded", "error", err)
+		} else {
+			defer db.Close()
+			run = jobruns.Start(db, uuid.New().String(), jobruns.JobDBBackups, "")
+		}
+	} else {
+		slog.Warn("TURSO_DB_URL not set, run will not be recorded")
+	}
+
 	gcsClient, err := storage.NewClient(ctx)
 	if err != nil {
 		slog.Error("Failed to create GCP Storage Client", "error", err)
-		log.Fatalf("Failed to create GCP Storage Client: %v", err)
+		run.Fatalf("Failed to create GCP Storage Client: %v", err)
 	}
 
 	// 2. Fetch SQL Dump from Turso (HTTP GET)
@@ -46,7 +64,7 @@ func main() {
 	req, err := http.NewRequestWithContext(ctx, "GET", dumpURL, nil)
 	if err != nil {
 		slog.Error("Failed to create dump request")
-		log.Fatalf("Failed to create dump request: %v", err)
+		run.Fatalf("Failed to create dump request: %v", err)
 	}
 	// Authenticate the request using the Turso Auth Token
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", os.Getenv("TURSO_DB_RW_TOKEN")))
@@ -55,12 +73,12 @@ func main() {
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		slog.Error("failed to get dump from Turso", "error", err)
-		log.Fatalf("failed to get dump from Turso: %v", err)
+		run.Fatalf("failed to get dump from Turso: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("turso dump failed with status: %d", resp.StatusCode)
+		run.Fatalf("turso dump failed with status: %d", resp.StatusCode)
 	}
 
 	// 3. Define the GCS object name
@@ -87,9 +105,11 @@ func main() {
 
 	if copyErr != nil {
 		slog.Error("Backup failed", "error", copyErr)
-		log.Fatalf("Backup process failed: %v", copyErr)
+		run.Fatalf("Backup process failed: %v", copyErr)
 	}
 
 	slog.Info("Backup successfull", "bucket", os.Getenv("BACKUP_BUCKET"), "object", objectName)
+	run.SetDetail(fmt.Sprintf("gs://%s/%s", os.Getenv("BACKUP_BUCKET"), objectName))
+	run.Finish(nil)
 
 }
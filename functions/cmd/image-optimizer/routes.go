@@ -25,6 +25,19 @@ type OptimizeResponse struct {
 	Error    string `json:"error,omitempty"`
 }
 
+type MirrorRequest struct {
+	CityCode string `json:"cityCode"`
+	// Limit caps how many queued images are processed, default 50
+	Limit int `json:"limit,omitempty"`
+}
+
+type MirrorResponse struct {
+	Success  bool   `json:"success"`
+	Mirrored int    `json:"mirrored"`
+	Failed   int    `json:"failed"`
+	Error    string `json:"error,omitempty"`
+}
+
 type HealthResponse struct {
 	Status string `json:"status"`
 }
@@ -32,6 +45,7 @@ type HealthResponse struct {
 func setupRoutes(router *chi.Mux, dbConnector *imageprocessing.DBConnector) {
 	router.Get("/health", handleHealth)
 	router.Post("/optimize", handleOptimize(dbConnector))
+	router.Post("/mirror", handleMirror(dbConnector))
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -135,3 +149,88 @@ func processImageOptimization(ctx context.Context, req OptimizeRequest) (string,
 
 	return processor.ProcessImage(ctx, req.ImageUUID, req.CityCode, req.EntityID, req.EntityType)
 }
+
+// handleMirror works through the external images the scraper queued for a
+// city. Images that fail are recorded and retried on later requests.
+func handleMirror(dbConnector *imageprocessing.DBConnector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var req MirrorRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CityCode == "" {
+			slog.Error("invalid mirror request", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(MirrorResponse{
+				Success: false,
+				Error:   "missing required field: cityCode",
+			})
+			return
+		}
+		if req.Limit <= 0 {
+			req.Limit = 50
+		}
+
+		sourceURLs, err := dbConnector.PendingImageMirrors(req.CityCode, req.Limit)
+		if err != nil {
+			slog.Error("failed to load pending image mirrors", "error", err, "cityCode", req.CityCode)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(MirrorResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		resp := MirrorResponse{Success: true}
+		if len(sourceURLs) > 0 {
+			resp.Mirrored, resp.Failed, err = mirrorImages(r.Context(), dbConnector, req.CityCode, sourceURLs)
+			if err != nil {
+				slog.Error("failed to mirror images", "error", err, "cityCode", req.CityCode)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(MirrorResponse{
+					Success: false,
+					Error:   err.Error(),
+				})
+				return
+			}
+		}
+
+		slog.Info("mirrored external images", "cityCode", req.CityCode, "mirrored", resp.Mirrored, "failed", resp.Failed)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func mirrorImages(ctx context.Context, dbConnector *imageprocessing.DBConnector, cityCode string, sourceURLs []string) (mirrored, failed int, err error) {
+	stagingBucket := os.Getenv("STAGING_BUCKET")
+	optimizedBucket := os.Getenv("OPTIMIZED_BUCKET")
+
+	if stagingBucket == "" || optimizedBucket == "" {
+		return 0, 0, fmt.Errorf("missing required environment variables: STAGING_BUCKET or OPTIMIZED_BUCKET not set")
+	}
+
+	processor, err := imageprocessing.NewImageProcessor(ctx, stagingBucket, optimizedBucket)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer processor.Close()
+
+	for _, sourceURL := range sourceURLs {
+		imageURL, err := processor.MirrorImage(ctx, sourceURL, cityCode)
+		if err != nil {
+			slog.Warn("failed to mirror image", "error", err, "sourceURL", sourceURL)
+			failed++
+			if err := dbConnector.FailImageMirror(sourceURL, err); err != nil {
+				slog.Error("failed to record image mirror failure", "error", err, "sourceURL", sourceURL)
+			}
+			continue
+		}
+
+		if err := dbConnector.CompleteImageMirror(sourceURL, imageURL); err != nil {
+			return mirrored, failed, err
+		}
+		mirrored++
+	}
+
+	return mirrored, failed, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/jobruns"
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+// backfillWindow is the window from since to until, or to yesterday when until
+// is empty, since today onwards is covered by the regular scrape
+func backfillWindow(cityCode, since, until string) (scraper.Window, error) {
+	if until == "" {
+		location, err := scraper.CityLocation(cityCode)
+		if err != nil {
+			return scraper.Window{}, err
+		}
+		until = time.Now().In(location).AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return scraper.ParseWindow(cityCode, since, until, 0)
+}
+
+// runBackfill walks the window a chunk at a time, oldest first, and archives
+// every ride the sources return. Each chunk is written as soon as it is
+// fetched, so a backfill that fails part way can be resumed with --since set
+// to the first chunk that wasn't archived.
+func runBackfill(ctx context.Context, db *sql.DB, run *jobruns.Run, sources []scraper.EventSource, cityCode string, window scraper.Window, chunkDays int) {
+	if chunkDays <= 0 {
+		chunkDays = scraper.DefaultShift2BikesChunkDays
+	}
+	if len(sources) == 0 {
+		slog.Warn("no event sources configured for city", "city", cityCode)
+	}
+
+	run.SetDetail(fmt.Sprintf("%s to %s", window.Start.Format("2006-01-02"), window.End.Format("2006-01-02")))
+
+	archived := 0
+	for _, chunk := range window.Chunks(chunkDays) {
+		for _, source := range sources {
+			events, err := source.FetchEvents(ctx, chunk)
+			if err != nil {
+				slog.Error("failed to get ride data", "source", source.Name(), "since", chunk.Start.Format("2006-01-02"), "error", err)
+				run.AddError(fmt.Errorf("failed to get ride data from %s for %s: %w", source.Name(), chunk.Start.Format("2006-01-02"), err))
+				continue
+			}
+
+			for i := range events {
+				events[i].SourcedFrom = source.Name()
+				events[i].CityCode = cityCode
+			}
+			if err := scraper.ArchiveEvents(db, events); err != nil {
+				run.SetCounts(jobruns.Counts{EventsSeen: archived, EventsUpserted: archived})
+				run.Fatalf("unable to archive rides from %s: %v", chunk.Start.Format("2006-01-02"), err)
+			}
+			archived += len(events)
+
+			slog.Info("archived rides", "source", source.Name(), "since", chunk.Start.Format("2006-01-02"), "until", chunk.End.Format("2006-01-02"), "count", len(events))
+		}
+	}
+
+	run.SetCounts(jobruns.Counts{EventsSeen: archived, EventsUpserted: archived})
+	run.Finish(nil)
+	slog.Info("backfill finished", "city", cityCode, "archived", archived)
+}
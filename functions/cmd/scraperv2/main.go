@@ -3,53 +3,113 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"github.com/spacesedan/cyclescene/functions/internal/dedup"
+	"github.com/spacesedan/cyclescene/functions/internal/httpretry"
+	"github.com/spacesedan/cyclescene/functions/internal/jobruns"
+	"github.com/spacesedan/cyclescene/functions/internal/netguard"
 	"github.com/spacesedan/cyclescene/functions/internal/routes"
 	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+	"github.com/spacesedan/cyclescene/functions/internal/series"
+	"github.com/spacesedan/cyclescene/functions/internal/tagging"
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
+	"google.golang.org/api/idtoken"
 )
 
-const (
-	FALLBACK_LAT   = 45.523064
-	FALLBACK_LNG   = -122.676483
-	FALLBACK_QUERY = "fallback"
-	EVENT_SOURCE   = "Shift2Bikes"
-)
+// FALLBACK_QUERY is the geocode query of rides placed at their city's center
+// because they couldn't be located
+const FALLBACK_QUERY = "fallback"
+
+// Per-provider request limits. Hosts without one share the default per host.
+var providerLimits = map[string]httpretry.Limit{
+	"geocode.googleapis.com": {Interval: 50 * time.Millisecond, Burst: 10},
+	// Nominatim's usage policy allows at most one request a second
+	"nominatim.openstreetmap.org": {Interval: time.Second, Burst: 1},
+	"ridewithgps.com":             {Interval: 500 * time.Millisecond, Burst: 2},
+	// Strava allows 100 requests every 15 minutes
+	"strava.com": {Interval: 9 * time.Second, Burst: 10},
+	// a backfill walks years of history, so keep Shift2Bikes to a request a second
+	"shift2bikes.org": {Interval: time.Second, Burst: 2},
+}
+
+func newRateLimitedTransport(base http.RoundTripper) *httpretry.Transport {
+	transport := httpretry.NewTransport(base, httpretry.Limit{Interval: 200 * time.Millisecond, Burst: 5})
+	for host, limit := range providerLimits {
+		transport.SetLimit(host, limit)
+	}
+	return transport
+}
 
 func main() {
+	dryRun := flag.Bool("dry-run", false, "run the whole pipeline without writing to the DB and print a JSON report")
+	cityFlag := flag.String("city", "", "city code to scrape (default $CITY_CODE, then pdx)")
+	since := flag.String("since", "", "first day to scrape, YYYY-MM-DD in the city's time zone")
+	until := flag.String("until", "", "last day to scrape, YYYY-MM-DD in the city's time zone")
+	workers := flag.Int("workers", 8, "number of events to geocode and fetch routes for at once")
+	windowDays := flag.Int("window-days", envInt("SCRAPE_WINDOW_DAYS", scraper.DefaultWindowDays), "days before and after today to scrape when --since or --until isn't set")
+	chunkDays := flag.Int("chunk-days", envInt("SHIFT2BIKES_CHUNK_DAYS", scraper.DefaultShift2BikesChunkDays), "days covered by each request to Shift2Bikes")
+	maxDrop := flag.Float64("max-drop", scraper.DefaultMaxDrop, "refuse to write when a built-in source returns more than this share (0-1) fewer rides than are stored for it; 1 turns the check off")
+	backfill := flag.Bool("backfill", false, "archive the built-in sources' rides from --since to --until (default yesterday) into ride_archive, without geocoding")
+	flag.Parse()
+
+	if *backfill && *dryRun {
+		log.Fatal("FATAL: --backfill can't be combined with --dry-run")
+	}
+	if *backfill && *since == "" {
+		log.Fatal("FATAL: --backfill needs --since")
+	}
+
 	// used in development
 	if os.Getenv("APP_ENV") == "dev" {
 		_ = godotenv.Load()
 	}
+
+	// a dry run only reads, so prefer a read-only token when one is available
+	authToken := os.Getenv("TURSO_DB_RW_TOKEN")
+	if *dryRun && os.Getenv("TURSO_DB_RO_TOKEN") != "" {
+		authToken = os.Getenv("TURSO_DB_RO_TOKEN")
+	}
+
 	// DB Vars
-	if os.Getenv("TURSO_DB_URL") == "" || os.Getenv("TURSO_DB_RW_TOKEN") == "" {
+	if os.Getenv("TURSO_DB_URL") == "" || authToken == "" {
 		log.Fatal("FATAL: Turso env variable not set properly")
 	}
 
-	// Get city code from environment variable
-	cityCode := os.Getenv("CITY_CODE")
+	// Get city code from the flag or environment variable
+	cityCode := *cityFlag
+	if cityCode == "" {
+		cityCode = os.Getenv("CITY_CODE")
+	}
 	if cityCode == "" {
 		cityCode = "pdx" // Default to PDX if not specified
 	}
 
 	//
 	// // set up logger
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	// a dry run prints its report to stdout, so logs go to stderr
+	logOutput := os.Stdout
+	if *dryRun {
+		logOutput = os.Stderr
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{
 		AddSource: true,
 	})))
-	slog.Info("Starting scraper service", "city", cityCode)
+	runID := uuid.New().String()
+	slog.Info("Starting scraper service", "city", cityCode, "run_id", runID, "dry_run", *dryRun, "backfill", *backfill)
 	//
 	// connect to DB(Turso)
 	dbURL := os.Getenv("TURSO_DB_URL")
-	authToken := os.Getenv("TURSO_DB_RW_TOKEN")
 
 	fullURL := fmt.Sprintf("%s?authToken=%s", dbURL, authToken)
 
@@ -59,10 +119,33 @@ func main() {
 	}
 	defer db.Close()
 
+	// record the run in job_runs; a dry run writes nothing, so it isn't recorded
+	var run *jobruns.Run
+	switch {
+	case *backfill:
+		run = jobruns.Start(db, runID, jobruns.JobBackfill, cityCode)
+	case !*dryRun:
+		run = jobruns.Start(db, runID, jobruns.JobScraper, cityCode)
+	}
+
 	////// READY TO START (v1.1.0) /////////////////////////
 
-	// Initialize route services
-	httpClient := &http.Client{Timeout: 30 * time.Second}
+	// Initialize route services. Every outbound request is rate limited per
+	// provider and retried with backoff when throttled or on server errors.
+	// The transport times out each attempt after its turn comes up, so the
+	// client has no overall timeout that waiting on a provider's limit eats into
+	transport := newRateLimitedTransport(nil)
+	httpClient := &http.Client{Transport: transport}
+
+	if *backfill {
+		window, err := backfillWindow(cityCode, *since, *until)
+		if err != nil {
+			run.Fatalf("unable to determine backfill window: %v", err)
+		}
+		runBackfill(context.Background(), db, run, scraper.DefaultRegistry(httpClient, *chunkDays).Sources(cityCode), cityCode, window, *chunkDays)
+		return
+	}
+
 	stravaToken := os.Getenv("STRAVA_ACCESS_TOKEN")
 	rwgpsAuthToken := os.Getenv("RWGPS_AUTH_TOKEN")
 	rwgpsAPIKey := os.Getenv("RWGPS_API_KEY")
@@ -91,6 +174,7 @@ func main() {
 	existingRoutes, err := routeRepo.GetAllRoutes(context.Background(), cityCode)
 	if err != nil {
 		slog.Error("failed to load existing routes", "error", err, "city", cityCode)
+		run.AddError(fmt.Errorf("failed to load existing routes: %w", err))
 	}
 	routeCache := make(map[string]string) // "source:sourceID" -> routeID
 	for _, route := range existingRoutes {
@@ -99,146 +183,270 @@ func main() {
 		slog.Info("loaded route from cache", "source", route.Source, "sourceID", route.SourceID, "routeID", route.ID)
 	}
 
+	// rides that can't be located are placed at the city's center
+	city, ok := scraper.GetCityDetails(cityCode)
+	if !ok {
+		run.Fatalf("no cities.json entry for city %q", cityCode)
+	}
+
+	geocoder, err := scraper.NewGeocoderFromEnv(transport)
+	if err != nil {
+		run.Fatalf("unable to configure geocoder: %v", err)
+	}
+	slog.Info("using geocoder", "provider", geocoder.Name())
+
 	// get all previously saved locations from DB
 	geocodeCache, err := scraper.GetGeocodeCache(db)
 	if err != nil {
-		log.Fatalf("something went wrong: %s\n", err.Error())
+		run.Fatalf("something went wrong: %s", err.Error())
 	}
 
-	// get upcoming Rides
-	shift2BikesEvents, err := scraper.GetRides()
+	// get rides from every source configured for the city
+	window, err := scraper.ParseWindow(cityCode, *since, *until, *windowDays)
 	if err != nil {
-		fmt.Println("failed to get ride data")
-	}
-
-	var rideLocations []scraper.Location
-	for i := range shift2BikesEvents.Events {
-		event := &shift2BikesEvents.Events[i]
-		event.SourcedFrom = EVENT_SOURCE
-		event.CityCode = cityCode
-
-		// Extract and process route if present in event details
-		routeURL := routes.ExtractRouteURLFromDescription(event.Details)
-		if routeURL != "" {
-			slog.Info("route URL found in event description", "routeURL", routeURL, "event", event.Title)
-			source, sourceID, err := routes.ParseRouteURL(routeURL)
-			if err != nil {
-				slog.Warn("failed to parse route URL", "error", err, "routeURL", routeURL, "event", event.Title)
-			} else {
-				slog.Info("parsed route URL", "source", source, "sourceID", sourceID, "routeURL", routeURL, "event", event.Title)
-				// Check if route is already in cache
-				cacheKey := fmt.Sprintf("%s:%s", source, sourceID)
-				if routeID, found := routeCache[cacheKey]; found {
-					slog.Info("route found in cache", "source", source, "sourceID", sourceID, "routeID", routeID, "event", event.Title)
-					event.RouteID = routeID
-				} else {
-					// Fetch and process new route
-					slog.Info("processing new route", "source", source, "sourceID", sourceID, "routeURL", routeURL, "event", event.Title)
-					feature, err := routeFetcher.FetchAndConvert(routeURL)
-					if err != nil {
-						slog.Warn("failed to fetch route", "error", err, "routeURL", routeURL, "source", source, "sourceID", sourceID, "event", event.Title)
-					} else {
-						// Extract distance from properties
-						var distanceKm, distanceMi float64
-						if km, ok := feature.Properties["distance_km"].(float64); ok {
-							distanceKm = km
-						}
-						if mi, ok := feature.Properties["distance_mi"].(float64); ok {
-							distanceMi = mi
-						}
-
-						// Create route in database
-						newRouteID, err := routeRepo.CreateRoute(context.Background(), source, sourceID, routeURL, cityCode, feature, distanceKm, distanceMi)
-						if err != nil {
-							slog.Error("failed to create route", "error", err, "routeURL", routeURL, "event", event.Title)
-						} else {
-							slog.Info("route created successfully", "source", source, "sourceID", sourceID, "routeID", newRouteID, "distance_km", distanceKm, "event", event.Title)
-							routeCache[cacheKey] = newRouteID
-							event.RouteID = newRouteID
-						}
-					}
-				}
-			}
-		}
+		run.Fatalf("unable to determine scrape window: %v", err)
+	}
 
-		// parse Starting location
-		location := scraper.CreateLocationFromEvent(event)
-		geocodeQuery := scraper.CreateGeoCodingQuery(&location)
-		normalizedQuery := strings.ToLower(geocodeQuery)
+	// the built-in sources are what the city's listings depend on, so a bad
+	// fetch from one stops the run before anything is written
+	registry := scraper.DefaultRegistry(httpClient, *chunkDays)
+	required := make(map[scraper.EventSource]bool)
+	for _, source := range registry.Sources(cityCode) {
+		required[source] = true
+	}
 
-		location.Query = geocodeQuery
-		location.City = cityCode
+	// groups that publish an iCalendar feed are scraped alongside the built-in sources
+	calendars, err := scraper.GetGroupCalendars(db, cityCode)
+	if err != nil {
+		slog.Error("failed to load group calendars", "error", err, "city", cityCode)
+		run.AddError(fmt.Errorf("failed to load group calendars: %w", err))
+	}
+	// feed URLs come from group owners, so they're fetched through a transport
+	// that can't reach internal addresses
+	feedClient := &http.Client{Transport: newRateLimitedTransport(netguard.NewTransport())}
+	for _, calendar := range calendars {
+		registry.Register(cityCode, scraper.NewICalSource(feedClient, calendar))
+	}
 
-		// locations where coords were avialable in the ride data
-		if !location.NeedsGeocoding {
-			event.Location = location
-			fmt.Printf("SKIP: Lat: %v, Lng: %v\n", location.Latitude, location.Longitude)
-			continue
-		}
+	sources := registry.Sources(cityCode)
+	if len(sources) == 0 {
+		slog.Warn("no event sources configured for city", "city", cityCode)
+	}
 
-		// Fallback to default Portland Coords if no good address
-		if location.Address == "" && location.Venue == "" {
-			location.Query = FALLBACK_QUERY
-			location.Latitude = FALLBACK_LAT
-			location.Longitude = FALLBACK_LNG
-			location.NeedsGeocoding = false
-			event.Location = location
-			fmt.Println("FALLBACK: to default Portland Coords")
-			continue
-		}
+	// stored rides are the baseline each source's fetch is checked against
+	storedEvents, err := scraper.GetStoredEvents(db, cityCode, window)
+	if err != nil {
+		run.Fatalf("unable to load stored events: %v", err)
+	}
 
-		// check cache for location
-		var cachedLoc scraper.GeoCodeCached
-		var found bool
-		if cachedLoc, found = geocodeCache[normalizedQuery]; found {
-			location.Latitude = cachedLoc.Latitude
-			location.Longitude = cachedLoc.Longitude
-			location.NeedsGeocoding = false
-			event.Location = location
-			fmt.Printf("SKIP (CACHE): %s\n", geocodeQuery)
-			continue
+	var events []scraper.Event
+	var fetchedSources []scraper.EventSource
+	for _, source := range sources {
+		sourceEvents, err := source.FetchEvents(context.Background(), window)
+		if err == nil {
+			// a response that is much smaller than what is stored is more
+			// likely truncated upstream than most rides being deleted
+			err = scraper.CheckEventCount(source.Name(), len(sourceEvents), scraper.CountStoredEvents(storedEvents, source), *maxDrop)
 		}
-		// make request to geocode API for location
-		fmt.Printf("GEOCODE: %s\n", geocodeQuery)
-
-		lat, lng, err := scraper.GeocodeQuery(geocodeQuery, cityCode)
 		if err != nil {
-			slog.Error("Unable to geocode query, using fall back coords", "error", err.Error(), "query", geocodeQuery)
-			location.Query = FALLBACK_QUERY
-			location.Latitude = FALLBACK_LAT
-			location.Longitude = FALLBACK_LNG
-			location.NeedsGeocoding = false
-			event.Location = location
+			if required[source] {
+				run.Fatalf("refusing to write, failed to get ride data from %s: %v", source.Name(), err)
+			}
+			slog.Error("failed to get ride data", "source", source.Name(), "error", err)
+			run.AddError(fmt.Errorf("failed to get ride data from %s: %w", source.Name(), err))
 			continue
 		}
+		slog.Info("fetched events from source", "source", source.Name(), "count", len(sourceEvents))
+
+		// an empty response is more likely an upstream problem than every ride
+		// being deleted, so don't let it remove anything
+		if len(sourceEvents) > 0 {
+			fetchedSources = append(fetchedSources, source)
+		} else {
+			slog.Warn("source returned no events, skipping removal detection", "source", source.Name())
+		}
 
-		location.Latitude = lat
-		location.Longitude = lng
-		location.NeedsGeocoding = false
-		event.Location = location
-
-		// prevent from running geocode API twice in the same run
-		geocodeCache[normalizedQuery] = scraper.GeoCodeCached{
-			Query:     normalizedQuery,
-			Latitude:  location.Latitude,
-			Longitude: location.Longitude,
+		for i := range sourceEvents {
+			sourceEvents[i].SourcedFrom = source.Name()
+			sourceEvents[i].CityCode = cityCode
 		}
+		events = append(events, sourceEvents...)
+	}
+
+	// every fetched ride is tagged, not only changed ones, so edits to the
+	// tagging rules reach rides that haven't changed upstream
+	tagger, err := tagging.NewTaggerFromEnv()
+	if err != nil {
+		run.Fatalf("unable to load tagging rules: %v", err)
+	}
+	for i := range events {
+		events[i].Tags = tagger.Tag(tagging.Input{
+			City:      cityCode,
+			Audience:  events[i].Audience,
+			StartTime: events[i].Time,
+			Text:      []string{events[i].Title, events[i].Details, events[i].Timedetails, events[i].Length},
+		})
+	}
+	fetchedEvents := events
+
+	// only events that are new or have changed upstream get processed and written
+	removals := scraper.DetectRemovals(events, storedEvents, fetchedSources)
+	fetchedCount := len(events)
+	events, changes := scraper.DetectChanges(events, storedEvents)
+
+	// unchanged rides are located again once their geocode expires, or they'd
+	// keep it until they next change upstream
+	stale := scraper.DetectStaleGeocodes(fetchedEvents, events, storedEvents, geocodeCache, time.Now())
+	events = append(events, stale...)
+
+	var report *dryRunReport
+	if *dryRun {
+		report = newDryRunReport(runID, cityCode, window)
+		report.Fetched = fetchedCount
+		report.addChanges(slices.Concat(changes, removals), events)
+	}
+
+	slog.Info("compared scraped events with stored rides", "run_id", runID, "fetched", fetchedCount, "changed", len(events), "changes", len(changes), "removed", len(removals), "stale_geocodes", len(stale))
+
+	p := &pipeline{
+		cityCode:     cityCode,
+		city:         city,
+		dryRun:       *dryRun,
+		geocoder:     geocoder,
+		linkResolver: scraper.NewHTTPLinkResolver(httpClient),
+		routeFetcher: routeFetcher,
+		routeRepo:    routeRepo,
+		report:       report,
+		geocodeCache: newSyncCache(geocodeCache),
+		routeCache:   newSyncCache(routeCache),
+		jobRun:       run,
+	}
+	p.run(context.Background(), events, *workers)
+	rideLocations := p.rideLocations
 
-		// append any starting location that need to be geocoded
-		rideLocations = append(rideLocations, location)
+	if *dryRun {
+		slog.Info("dry run, skipping database writes", "run_id", runID, "changed", len(events), "removed", len(removals), "geocoded", len(rideLocations))
+		if err := report.write(os.Stdout); err != nil {
+			run.Fatalf("unable to write dry run report: %v", err)
+		}
+		return
 	}
 
 	// Get Locations ready to upsert into db
 	if err = scraper.BulkUpsertGeocodeData(db, rideLocations); err != nil {
 		slog.Error("unable to bulk upsert ride locations", "locations_len", len(rideLocations), "error", err.Error())
-		log.Fatalf("unable to bulk upsert ride locations: %v", err)
+		run.Fatalf("unable to bulk upsert ride locations: %v", err)
 	}
 
 	// store ride information
-	if err = scraper.BulkUpsertRideData(db, shift2BikesEvents.Events); err != nil {
+	if err = scraper.BulkUpsertRideData(db, events); err != nil {
 		slog.Error("unable to bulk upsert ride data", "locations_len", len(rideLocations), "error", err.Error())
-		log.Fatalf("unable to bulk upsert ride data: %v", err)
+		run.Fatalf("unable to bulk upsert ride data: %v", err)
+
+	}
 
+	// tombstone rides that are no longer returned upstream
+	if err = scraper.MarkEventsRemoved(db, removals); err != nil {
+		slog.Error("unable to mark removed rides", "removed_len", len(removals), "error", err.Error())
+		run.Fatalf("unable to mark removed rides: %v", err)
+	}
+	changes = append(changes, removals...)
+
+	// bring stored tags in line with the rules
+	retagged, err := scraper.SyncEventTags(db, cityCode, window, fetchedEvents)
+	if err != nil {
+		slog.Error("unable to tag rides", "run_id", runID, "error", err.Error())
+		run.AddError(fmt.Errorf("unable to tag rides: %w", err))
+	} else {
+		slog.Info("tagged rides", "run_id", runID, "retagged", retagged)
 	}
 
+	// and submitted rides, which are otherwise only tagged when saved
+	retaggedSubmitted, err := tagging.SyncSubmitted(db, cityCode, window.Start.Format("2006-01-02"), tagger)
+	if err != nil {
+		slog.Error("unable to tag submitted rides", "run_id", runID, "error", err.Error())
+		run.AddError(fmt.Errorf("unable to tag submitted rides: %w", err))
+	} else {
+		slog.Info("tagged submitted rides", "run_id", runID, "retagged", retaggedSubmitted)
+	}
+
+	// group each ride's dates into a series, such as "Every Thursday"
+	seriesWritten, err := series.Sync(db, cityCode, window.Start.Format("2006-01-02"), window.End.Format("2006-01-02"))
+	if err != nil {
+		slog.Error("unable to update ride series", "run_id", runID, "error", err.Error())
+		run.AddError(fmt.Errorf("unable to update ride series: %w", err))
+	} else {
+		slog.Info("updated ride series", "run_id", runID, "series", seriesWritten)
+	}
+
+	// mirror new external images through the image optimizer so scraped
+	// rides get the same WebP variants as submitted ones
+	queuedImages, err := scraper.QueueImageMirrors(db, cityCode, events)
+	if err != nil {
+		slog.Error("unable to queue images for optimization", "run_id", runID, "error", err.Error())
+		run.AddError(fmt.Errorf("unable to queue images for optimization: %w", err))
+	} else {
+		slog.Info("queued images for optimization", "run_id", runID, "images", queuedImages)
+	}
+	if optimizerURL := os.Getenv("IMAGE_OPTIMIZER_URL"); optimizerURL != "" {
+		if err := requestImageMirrors(optimizerURL, cityCode); err != nil {
+			slog.Error("unable to request image mirrors", "run_id", runID, "error", err.Error())
+			run.AddError(fmt.Errorf("unable to request image mirrors: %w", err))
+		}
+	}
+
+	// log what this run changed
+	if err = scraper.RecordScrapeChanges(db, runID, changes); err != nil {
+		slog.Error("unable to record scrape changes", "run_id", runID, "changes_len", len(changes), "error", err.Error())
+		run.AddError(fmt.Errorf("unable to record scrape changes: %w", err))
+	}
+
+	// pair up rides posted both upstream and through ride submission
+	duplicates, err := dedup.Detect(db, cityCode, window.Start.Format("2006-01-02"))
+	if err != nil {
+		slog.Error("unable to detect duplicate rides", "run_id", runID, "error", err.Error())
+		run.AddError(fmt.Errorf("unable to detect duplicate rides: %w", err))
+	} else {
+		slog.Info("detected duplicate rides", "run_id", runID, "candidates", duplicates)
+	}
+
+	run.SetCounts(jobruns.Counts{
+		EventsSeen:     fetchedCount,
+		EventsUpserted: len(events),
+		EventsGeocoded: len(rideLocations),
+		Fallbacks:      int(p.fallbacks.Load()),
+		RoutesCreated:  int(p.routesCreated.Load()),
+	})
+	run.Finish(nil)
+	slog.Info("scrape finished", "run_id", runID, "fetched", fetchedCount, "upserted", len(events), "geocoded", len(rideLocations), "fallbacks", p.fallbacks.Load(), "routes_created", p.routesCreated.Load())
+}
+
+// requestImageMirrors has the image optimizer work through the queued images,
+// authenticating with an identity token when running on Google Cloud. Fetching
+// and encoding takes a while, so it gets longer than other requests.
+func requestImageMirrors(optimizerURL, cityCode string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	client, err := idtoken.NewClient(ctx, optimizerURL)
+	if err != nil {
+		slog.Warn("no identity token available, calling image optimizer unauthenticated", "error", err)
+		client = &http.Client{}
+	}
+	return scraper.RequestImageMirrors(ctx, client, optimizerURL, cityCode)
+}
+
+// envInt reads an integer environment variable, falling back to def when it
+// is unset or invalid
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("ignoring invalid integer environment variable", "name", name, "value", value)
+		return def
+	}
+	return n
 }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/jobruns"
+	"github.com/spacesedan/cyclescene/functions/internal/routes"
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+	"golang.org/x/sync/singleflight"
+)
+
+// syncCache is a map that is safe for the pipeline's workers to share
+type syncCache[V any] struct {
+	mu    sync.RWMutex
+	items map[string]V
+}
+
+func newSyncCache[V any](items map[string]V) *syncCache[V] {
+	if items == nil {
+		items = make(map[string]V)
+	}
+	return &syncCache[V]{items: items}
+}
+
+func (c *syncCache[V]) get(key string) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.items[key]
+	return value, ok
+}
+
+func (c *syncCache[V]) set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+}
+
+// pipeline resolves the route and starting location of scraped events using
+// a pool of workers. Lookups for the same route or geocode query are only
+// made once, however many events share them.
+type pipeline struct {
+	cityCode     string
+	city         scraper.CityDetails
+	dryRun       bool
+	geocoder     scraper.Geocoder
+	linkResolver scraper.LinkResolver
+	routeFetcher *routes.RouteFetcher
+	routeRepo    *routes.Repository
+	report       *dryRunReport
+	jobRun       *jobruns.Run
+
+	geocodeCache  *syncCache[scraper.GeoCodeCached] // normalized query -> cached location
+	routeCache    *syncCache[string]                // "source:sourceID" -> routeID
+	geocodeFlight singleflight.Group
+	routeFlight   singleflight.Group
+
+	mu            sync.Mutex
+	rideLocations []scraper.Location
+
+	fallbacks     atomic.Int64
+	routesCreated atomic.Int64
+}
+
+// geocodeOutcome is the shared result of geocoding one query
+type geocodeOutcome struct {
+	entry  scraper.GeoCodeCached
+	result *scraper.GeocodeResult
+	err    error
+}
+
+// run processes every event with up to workers goroutines. Each worker only
+// writes to its own event.
+func (p *pipeline) run(ctx context.Context, events []scraper.Event, workers int) {
+	jobs := make(chan *scraper.Event)
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range jobs {
+				p.processRoute(ctx, event)
+				p.processLocation(ctx, event)
+				p.processEndLocation(ctx, event)
+			}
+		}()
+	}
+
+	for i := range events {
+		jobs <- &events[i]
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Extract and process route if present in event details
+func (p *pipeline) processRoute(ctx context.Context, event *scraper.Event) {
+	routeURL := routes.ExtractRouteURLFromDescription(event.Details)
+	if routeURL == "" {
+		return
+	}
+
+	slog.Info("route URL found in event description", "routeURL", routeURL, "event", event.Title)
+	source, sourceID, err := routes.ParseRouteURL(routeURL)
+	if err != nil {
+		slog.Warn("failed to parse route URL", "error", err, "routeURL", routeURL, "event", event.Title)
+		return
+	}
+	slog.Info("parsed route URL", "source", source, "sourceID", sourceID, "routeURL", routeURL, "event", event.Title)
+
+	// Check if route is already in cache
+	cacheKey := fmt.Sprintf("%s:%s", source, sourceID)
+	if routeID, found := p.routeCache.get(cacheKey); found {
+		slog.Info("route found in cache", "source", source, "sourceID", sourceID, "routeID", routeID, "event", event.Title)
+		event.RouteID = routeID
+		return
+	}
+
+	if p.dryRun {
+		p.report.addRoute(reportRouteFetch{URL: routeURL, Source: source, SourceID: sourceID, Event: event.Title})
+		return
+	}
+
+	routeID, err, _ := p.routeFlight.Do(cacheKey, func() (any, error) {
+		// another worker may have created the route while this one waited
+		if routeID, found := p.routeCache.get(cacheKey); found {
+			return routeID, nil
+		}
+
+		// Fetch and process new route
+		slog.Info("processing new route", "source", source, "sourceID", sourceID, "routeURL", routeURL, "event", event.Title)
+		feature, err := p.routeFetcher.FetchAndConvert(routeURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch route: %w", err)
+		}
+
+		// Extract distance from properties
+		var distanceKm, distanceMi float64
+		if km, ok := feature.Properties["distance_km"].(float64); ok {
+			distanceKm = km
+		}
+		if mi, ok := feature.Properties["distance_mi"].(float64); ok {
+			distanceMi = mi
+		}
+
+		// Create route in database
+		newRouteID, err := p.routeRepo.CreateRoute(ctx, source, sourceID, routeURL, p.cityCode, feature, distanceKm, distanceMi)
+		if err != nil {
+			return "", fmt.Errorf("failed to create route: %w", err)
+		}
+		slog.Info("route created successfully", "source", source, "sourceID", sourceID, "routeID", newRouteID, "distance_km", distanceKm, "event", event.Title)
+		p.routesCreated.Add(1)
+		p.routeCache.set(cacheKey, newRouteID)
+		return newRouteID, nil
+	})
+	if err != nil {
+		slog.Warn("unable to process route", "error", err, "routeURL", routeURL, "source", source, "sourceID", sourceID, "event", event.Title)
+		p.jobRun.AddError(fmt.Errorf("%s (%s): %w", routeURL, event.Title, err))
+		return
+	}
+	event.RouteID = routeID.(string)
+}
+
+// parse Starting location
+func (p *pipeline) processLocation(ctx context.Context, event *scraper.Event) {
+	location := scraper.CreateLocationFromEvent(event)
+	geocodeQuery := scraper.CreateGeoCodingQuery(&location, p.cityCode)
+	normalizedQuery := strings.ToLower(geocodeQuery)
+
+	location.Query = geocodeQuery
+	location.City = p.cityCode
+
+	p.resolveMapLink(ctx, event, &location)
+
+	// locations where coords were avialable in the ride data
+	if !location.NeedsGeocoding {
+		location.Quality = scraper.LocationExact
+		event.Location = location
+		slog.Info("using coordinates from ride text", "lat", location.Latitude, "lng", location.Longitude, "event", event.Title)
+		return
+	}
+
+	// Fallback to the city's center if no good address
+	if location.Address == "" && location.Venue == "" {
+		p.useFallback(event, location)
+		p.report.addFallback(event, "", "no address or venue")
+		slog.Info("no address or venue, using fallback coords", "event", event.Title)
+		return
+	}
+
+	// remember the cache key, even if geocoding fails below, so an admin
+	// override for it can be applied back to this ride
+	event.GeocodeQuery = normalizedQuery
+
+	if !p.locate(ctx, event, &location, normalizedQuery) {
+		slog.Error("Unable to geocode query, using fall back coords", "query", geocodeQuery, "event", event.Title)
+		p.report.addFallback(event, normalizedQuery, "geocoding failed")
+		p.useFallback(event, location)
+		return
+	}
+	event.Location = location
+}
+
+// parse ending location of rides that don't finish where they start. Unlike
+// the start there is no fallback: a ride whose end can't be found has none.
+func (p *pipeline) processEndLocation(ctx context.Context, event *scraper.Event) {
+	location := scraper.CreateEndLocationFromEvent(event)
+	if !location.NeedsGeocoding {
+		event.EndLocation = location
+		return
+	}
+
+	p.resolveMapLink(ctx, event, &location)
+	if !location.NeedsGeocoding {
+		event.EndLocation = location
+		return
+	}
+	if location.Address == "" {
+		return
+	}
+
+	location.Query = scraper.CreateGeoCodingQuery(&location, p.cityCode)
+	if p.locate(ctx, event, &location, strings.ToLower(location.Query)) {
+		event.EndLocation = location
+	}
+}
+
+// resolveMapLink follows a short map link found in the ride text, since it
+// can only be read online
+func (p *pipeline) resolveMapLink(ctx context.Context, event *scraper.Event, location *scraper.Location) {
+	link := location.MapLink
+	if link == "" {
+		return
+	}
+
+	resolved, err := scraper.ResolveMapLink(ctx, p.linkResolver, location, p.cityCode)
+	if err != nil {
+		slog.Warn("unable to resolve map link", "error", err, "link", link, "event", event.Title)
+	} else if resolved {
+		slog.Info("using coordinates from map link", "link", link, "lat", location.Latitude, "lng", location.Longitude, "event", event.Title)
+	}
+}
+
+// locate sets the location's coordinates from the geocode cache, or from the
+// geocoder when the query isn't cached or its entry has expired. It reports
+// false when neither has them.
+func (p *pipeline) locate(ctx context.Context, event *scraper.Event, location *scraper.Location, normalizedQuery string) bool {
+	// check cache for location, re-geocoding entries whose TTL has passed.
+	// manual overrides never expire, so they always win here
+	cachedLoc, found := p.geocodeCache.get(normalizedQuery)
+	if found && !cachedLoc.NeedsRefresh(time.Now()) {
+		location.Latitude = cachedLoc.Latitude
+		location.Longitude = cachedLoc.Longitude
+		location.NeedsGeocoding = false
+		location.Quality = scraper.LocationCached
+		if cachedLoc.Confidence == scraper.ConfidenceLow {
+			slog.Warn("using low confidence cached geocode", "query", location.Query, "granularity", cachedLoc.Granularity, "event", event.Title)
+		}
+		slog.Info("using cached geocode", "query", location.Query, "event", event.Title)
+		return true
+	}
+
+	value, _, _ := p.geocodeFlight.Do(normalizedQuery, func() (any, error) {
+		return p.geocode(ctx, event, *location, normalizedQuery, found), nil
+	})
+	outcome := value.(geocodeOutcome)
+
+	if outcome.err != nil && found {
+		// a stale cache entry is still better than nothing
+		slog.Warn("Unable to refresh geocode, keeping cached coords", "error", outcome.err.Error(), "query", location.Query)
+		location.Latitude = cachedLoc.Latitude
+		location.Longitude = cachedLoc.Longitude
+		location.NeedsGeocoding = false
+		location.Quality = scraper.LocationCached
+		return true
+	}
+	if outcome.err != nil {
+		slog.Warn("Unable to geocode query", "error", outcome.err.Error(), "query", location.Query, "event", event.Title)
+		return false
+	}
+
+	location.Latitude = outcome.entry.Latitude
+	location.Longitude = outcome.entry.Longitude
+	location.NeedsGeocoding = false
+	location.Geocoded = outcome.result
+	// another worker may have cached the query while this one waited
+	location.Quality = scraper.LocationCached
+	if outcome.result != nil {
+		location.Quality = scraper.LocationGeocoded
+	}
+	return true
+}
+
+// geocode looks a query up with the geocoder and caches the result. It runs
+// once per query, on behalf of every event waiting on it.
+func (p *pipeline) geocode(ctx context.Context, event *scraper.Event, location scraper.Location, normalizedQuery string, refresh bool) geocodeOutcome {
+	// another worker may have geocoded the query while this one waited
+	if cached, found := p.geocodeCache.get(normalizedQuery); found && !cached.NeedsRefresh(time.Now()) {
+		return geocodeOutcome{entry: cached}
+	}
+
+	// make request to geocode API for location
+	slog.Info("geocoding", "query", location.Query, "event", event.Title)
+	result, err := p.geocoder.Geocode(ctx, location.Query, p.cityCode)
+
+	miss := reportGeocode{Query: normalizedQuery, Event: event.Title, Refresh: refresh}
+	if err != nil {
+		miss.Error = err.Error()
+	} else {
+		miss.Latitude, miss.Longitude, miss.Confidence = result.Latitude, result.Longitude, result.Confidence()
+	}
+	p.report.addGeocodeMiss(miss)
+
+	if err != nil {
+		return geocodeOutcome{err: err}
+	}
+
+	confidence := result.Confidence()
+	if confidence == scraper.ConfidenceLow {
+		// keep the result but flag it; the short TTL means it is retried soon
+		slog.Warn("low confidence geocode", "query", location.Query, "granularity", result.Granularity, "types", result.Types, "event", event.Title)
+	}
+
+	// prevent from running geocode API twice in the same run
+	entry := scraper.GeoCodeCached{
+		Query:            normalizedQuery,
+		Latitude:         result.Latitude,
+		Longitude:        result.Longitude,
+		Provider:         result.Provider,
+		PlaceID:          result.PlaceID,
+		FormattedAddress: result.FormattedAddress,
+		Granularity:      result.Granularity,
+		Types:            result.Types,
+		Confidence:       confidence,
+		ExpiresAt:        time.Now().Add(confidence.TTL()),
+	}
+	p.geocodeCache.set(normalizedQuery, entry)
+
+	// append any starting location that need to be geocoded
+	location.Latitude = result.Latitude
+	location.Longitude = result.Longitude
+	location.NeedsGeocoding = false
+	location.Geocoded = &result
+	p.mu.Lock()
+	p.rideLocations = append(p.rideLocations, location)
+	p.mu.Unlock()
+
+	return geocodeOutcome{entry: entry, result: &result}
+}
+
+func (p *pipeline) useFallback(event *scraper.Event, location scraper.Location) {
+	p.fallbacks.Add(1)
+	location.Query = FALLBACK_QUERY
+	location.Latitude = p.city.CenterLat
+	location.Longitude = p.city.CenterLng
+	location.NeedsGeocoding = false
+	location.Quality = scraper.LocationFallback
+	event.Location = location
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+// dryRunReport is what a --dry-run scrape prints instead of writing to the DB.
+// Its methods are no-ops on a nil report, so a normal run can call them
+// without checking for --dry-run.
+type dryRunReport struct {
+	mu sync.Mutex
+
+	RunID         string             `json:"run_id"`
+	City          string             `json:"city"`
+	Since         string             `json:"since"`
+	Until         string             `json:"until"`
+	GeneratedAt   time.Time          `json:"generated_at"`
+	Fetched       int                `json:"fetched"`
+	New           []reportEvent      `json:"new"`
+	Changed       []reportEvent      `json:"changed"`
+	Removed       []reportEvent      `json:"removed"`
+	GeocodeMisses []reportGeocode    `json:"geocode_misses"`
+	Fallbacks     []reportFallback   `json:"fallbacks"`
+	Routes        []reportRouteFetch `json:"routes"`
+}
+
+type reportEvent struct {
+	CompositeEventID string                       `json:"composite_event_id"`
+	Source           string                       `json:"source"`
+	Title            string                       `json:"title,omitempty"`
+	Date             string                       `json:"date,omitempty"`
+	StartsAt         string                       `json:"starts_at,omitempty"`
+	Tags             []string                     `json:"tags,omitempty"`
+	ChangeType       scraper.ChangeType           `json:"change_type"`
+	Diff             map[string]scraper.FieldDiff `json:"diff,omitempty"`
+}
+
+// reportGeocode is a query that was not in the cache, or whose entry had
+// expired, and so went to the geocoder
+type reportGeocode struct {
+	Query      string             `json:"query"`
+	Event      string             `json:"event"`
+	Refresh    bool               `json:"refresh"`
+	Latitude   float64            `json:"lat,omitempty"`
+	Longitude  float64            `json:"lng,omitempty"`
+	Confidence scraper.Confidence `json:"confidence,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+type reportFallback struct {
+	CompositeEventID string `json:"composite_event_id"`
+	Event            string `json:"event"`
+	Query            string `json:"query,omitempty"`
+	Reason           string `json:"reason"`
+}
+
+type reportRouteFetch struct {
+	URL      string `json:"url"`
+	Source   string `json:"source"`
+	SourceID string `json:"source_id"`
+	Event    string `json:"event"`
+}
+
+func newDryRunReport(runID, cityCode string, window scraper.Window) *dryRunReport {
+	return &dryRunReport{
+		RunID:         runID,
+		City:          cityCode,
+		Since:         window.Start.Format("2006-01-02"),
+		Until:         window.End.Format("2006-01-02"),
+		GeneratedAt:   time.Now().UTC(),
+		New:           []reportEvent{},
+		Changed:       []reportEvent{},
+		Removed:       []reportEvent{},
+		GeocodeMisses: []reportGeocode{},
+		Fallbacks:     []reportFallback{},
+		Routes:        []reportRouteFetch{},
+	}
+}
+
+// addChanges sorts the run's changes into new, changed and removed rides
+func (r *dryRunReport) addChanges(changes []scraper.Change, events []scraper.Event) {
+	if r == nil {
+		return
+	}
+
+	byID := make(map[string]scraper.Event, len(events))
+	for _, event := range events {
+		byID[scraper.CompositeEventID(event)] = event
+	}
+
+	for _, change := range changes {
+		entry := reportEvent{
+			CompositeEventID: change.CompositeEventID,
+			Source:           change.Source,
+			ChangeType:       change.Type,
+			Diff:             change.Diff,
+		}
+		if event, ok := byID[change.CompositeEventID]; ok {
+			entry.Title = event.Title
+			entry.Date = event.Date
+			if times, err := event.Times(); err == nil {
+				entry.StartsAt = times.Start.Format(time.RFC3339)
+			}
+			entry.Tags = event.Tags
+		}
+
+		switch change.Type {
+		case scraper.ChangeCreate:
+			r.New = append(r.New, entry)
+		case scraper.ChangeRemove:
+			r.Removed = append(r.Removed, entry)
+		default:
+			r.Changed = append(r.Changed, entry)
+		}
+	}
+}
+
+func (r *dryRunReport) addGeocodeMiss(miss reportGeocode) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GeocodeMisses = append(r.GeocodeMisses, miss)
+}
+
+func (r *dryRunReport) addFallback(event *scraper.Event, query, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Fallbacks = append(r.Fallbacks, reportFallback{
+		CompositeEventID: scraper.CompositeEventID(*event),
+		Event:            event.Title,
+		Query:            query,
+		Reason:           reason,
+	})
+}
+
+func (r *dryRunReport) addRoute(route reportRouteFetch) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Routes = append(r.Routes, route)
+}
+
+func (r *dryRunReport) write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -8,7 +8,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"github.com/spacesedan/cyclescene/functions/internal/jobruns"
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
 )
 
@@ -41,6 +43,8 @@ func main() {
 	}
 	defer db.Close()
 
+	run := jobruns.Start(db, uuid.New().String(), jobruns.JobTokenCleaner, "")
+
 	startTime := time.Now()
 	nowStr := time.Now().Format(time.RFC3339)
 
@@ -48,10 +52,14 @@ func main() {
 		DELETE FROM submission_tokens
 		WHERE expires_at < ?;`
 
-	if _, err = db.Exec(query, nowStr); err != nil {
+	result, err := db.Exec(query, nowStr)
+	if err != nil {
 		slog.Error("Failed to remove expired submission_tokens", "time", nowStr, "error", err)
-		log.Fatalf("failed to removed expired submission tokens: %v", err)
+		run.Fatalf("failed to removed expired submission tokens: %v", err)
 	}
+	deleted, _ := result.RowsAffected()
+	run.SetDetail(fmt.Sprintf("deleted %d expired submission tokens", deleted))
+	run.Finish(nil)
 
 	endTime := time.Since(time.Now())
 	slog.Info("Cleared out all expired tokens!", "start_time", startTime, "end_time", endTime)
@@ -0,0 +1,124 @@
+package duplicates
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spacesedan/cyclescene/functions/internal/api/middleware"
+)
+
+type Handler struct {
+	service   *Service
+	adminKeys middleware.AdminKeyValidator
+}
+
+func NewHandler(service *Service, adminKeys middleware.AdminKeyValidator) *Handler {
+	return &Handler{
+		service:   service,
+		adminKeys: adminKeys,
+	}
+}
+
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/duplicates", func(r chi.Router) {
+		r.Use(middleware.RequireAdminKey(h.adminKeys))
+
+		r.Get("/", h.ListDuplicates)
+		r.Post("/detect", h.Detect)
+		r.Put("/{id}", h.Review)
+	})
+}
+
+// ListDuplicates lists candidate duplicate pairs, highest score first
+// GET /v1/admin/duplicates?city=pdx&status=pending&limit=50&offset=0
+func (h *Handler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := Filter{
+		City:   params.Get("city"),
+		Status: params.Get("status"),
+	}
+
+	var err error
+	if limit := params.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if offset := params.Get("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	duplicates, err := h.service.ListDuplicates(filter)
+	if err != nil {
+		slog.Error("Failed to list duplicate rides", "error", err)
+		http.Error(w, "Failed to fetch duplicate rides", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(duplicates)
+}
+
+// Review confirms or rejects a candidate pair
+// PUT /v1/admin/duplicates/{id}
+func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid duplicate ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	dup, err := h.service.Review(id, req)
+	if err != nil {
+		h.writeError(w, err, "Failed to review duplicate rides")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dup)
+}
+
+// Detect re-scores a city's upcoming rides without waiting for the next scrape
+// POST /v1/admin/duplicates/detect?city=pdx
+func (h *Handler) Detect(w http.ResponseWriter, r *http.Request) {
+	city := r.URL.Query().Get("city")
+	if city == "" {
+		http.Error(w, "Missing city parameter", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.service.Detect(city)
+	if err != nil {
+		h.writeError(w, err, "Failed to detect duplicate rides")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		slog.Error(message, "error", err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
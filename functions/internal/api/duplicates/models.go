@@ -0,0 +1,55 @@
+package duplicates
+
+// Duplicate is a candidate pair of a scraped ride and a user-submitted ride
+// that look like the same ride
+type Duplicate struct {
+	ID            int64    `json:"id"`
+	City          string   `json:"city"`
+	Date          string   `json:"date"`
+	Score         float64  `json:"score"`
+	TitleScore    float64  `json:"title_score"`
+	TimeScore     float64  `json:"time_score"`
+	DistanceScore float64  `json:"distance_score"`
+	DistanceM     *float64 `json:"distance_m,omitempty"`
+	Status        string   `json:"status"`
+	ReviewNotes   string   `json:"review_notes,omitempty"`
+	ReviewedAt    string   `json:"reviewed_at,omitempty"`
+	DetectedAt    string   `json:"detected_at"`
+	Scraped       Ride     `json:"scraped"`
+	Submitted     Ride     `json:"submitted"`
+}
+
+// Ride is one side of a pair, with enough detail to compare the two
+type Ride struct {
+	ID         string  `json:"id"`
+	Source     string  `json:"source"`
+	Title      string  `json:"title"`
+	StartTime  string  `json:"start_time"`
+	Venue      string  `json:"venue"`
+	Address    string  `json:"address"`
+	Latitude   float64 `json:"lat"`
+	Longitude  float64 `json:"lng"`
+	Organizer  string  `json:"organizer"`
+	IsLoopRide bool    `json:"is_loop_ride"`
+}
+
+// Filter narrows the duplicate listing
+type Filter struct {
+	City   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// ReviewRequest confirms or rejects a pair, or puts it back to pending
+type ReviewRequest struct {
+	Status string `json:"status"`
+	Notes  string `json:"notes"`
+}
+
+// DetectResponse is the result of re-scoring a city's upcoming rides
+type DetectResponse struct {
+	City       string `json:"city"`
+	Since      string `json:"since"`
+	Candidates int    `json:"candidates"`
+}
@@ -0,0 +1,144 @@
+package duplicates
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/dedup"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+const duplicateColumns = `
+	d.id, d.city, d.date, d.score, d.title_score, d.time_score, d.distance_score, d.distance_m,
+	d.status, COALESCE(d.review_notes, ''), COALESCE(d.reviewed_at, ''), d.detected_at,
+	s.composite_event_id, s.ridesource, s.title, s.starttime, s.venue, s.address, s.lat, s.lng,
+	s.organizer, s.loopride,
+	CAST(e.id AS TEXT), e.title,
+	COALESCE((SELECT MIN(o.start_time) FROM event_occurrences o WHERE o.event_id = e.id AND o.start_date = d.date), ''),
+	COALESCE(e.venue_name, ''), COALESCE(e.address, ''), COALESCE(e.latitude, 0), COALESCE(e.longitude, 0),
+	COALESCE(e.organizer_name, ''), e.is_loop_ride`
+
+const duplicateTables = `
+	ride_duplicates d
+	JOIN shift2bikes_events s ON s.composite_event_id = d.composite_event_id
+	JOIN events e ON e.id = d.event_id`
+
+func scanDuplicate(row interface{ Scan(...any) error }) (Duplicate, error) {
+	var dup Duplicate
+	var distance sql.NullFloat64
+	var scrapedLoop, submittedLoop int
+
+	err := row.Scan(
+		&dup.ID, &dup.City, &dup.Date, &dup.Score, &dup.TitleScore, &dup.TimeScore, &dup.DistanceScore, &distance,
+		&dup.Status, &dup.ReviewNotes, &dup.ReviewedAt, &dup.DetectedAt,
+		&dup.Scraped.ID, &dup.Scraped.Source, &dup.Scraped.Title, &dup.Scraped.StartTime, &dup.Scraped.Venue,
+		&dup.Scraped.Address, &dup.Scraped.Latitude, &dup.Scraped.Longitude, &dup.Scraped.Organizer, &scrapedLoop,
+		&dup.Submitted.ID, &dup.Submitted.Title, &dup.Submitted.StartTime, &dup.Submitted.Venue,
+		&dup.Submitted.Address, &dup.Submitted.Latitude, &dup.Submitted.Longitude, &dup.Submitted.Organizer, &submittedLoop,
+	)
+	if err != nil {
+		return dup, err
+	}
+
+	if distance.Valid {
+		dup.DistanceM = &distance.Float64
+	}
+	dup.Scraped.IsLoopRide = scrapedLoop == 1
+	dup.Submitted.Source = "user-submitted"
+	dup.Submitted.IsLoopRide = submittedLoop == 1
+	return dup, nil
+}
+
+// ListDuplicates returns pairs matching the filter, highest score first
+func (r *Repository) ListDuplicates(filter Filter) ([]Duplicate, error) {
+	var conditions []string
+	var args []any
+
+	if filter.City != "" {
+		conditions = append(conditions, "d.city = ?")
+		args = append(args, filter.City)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "d.status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := `SELECT ` + duplicateColumns + ` FROM ` + duplicateTables
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY d.score DESC, d.date, d.id LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	duplicates := []Duplicate{}
+	for rows.Next() {
+		dup, err := scanDuplicate(rows)
+		if err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, dup)
+	}
+
+	return duplicates, rows.Err()
+}
+
+// GetDuplicate returns a pair by ID, or nil if there is none
+func (r *Repository) GetDuplicate(id int64) (*Duplicate, error) {
+	dup, err := scanDuplicate(r.db.QueryRow(
+		`SELECT `+duplicateColumns+` FROM `+duplicateTables+` WHERE d.id = ?`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dup, nil
+}
+
+// SetStatus records an admin's review of a pair. It reports false if there is
+// no pair with the ID.
+func (r *Repository) SetStatus(id int64, status, notes string) (bool, error) {
+	var reviewedAt any
+	if status != dedup.StatusPending {
+		reviewedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE ride_duplicates SET status = ?, review_notes = ?, reviewed_at = ?
+		WHERE id = ?
+	`, status, nilIfEmpty(notes), reviewedAt, id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// Detect re-scores the city's rides from since onwards
+func (r *Repository) Detect(city, since string) (int, error) {
+	return dedup.Detect(r.db, city, since)
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
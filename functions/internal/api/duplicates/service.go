@@ -0,0 +1,85 @@
+package duplicates
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/dedup"
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid duplicate request")
+	ErrNotFound       = errors.New("duplicate not found")
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) ListDuplicates(filter Filter) ([]Duplicate, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	}
+	filter.Limit = min(filter.Limit, maxLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	return s.repo.ListDuplicates(filter)
+}
+
+// Review confirms or rejects a pair. Confirmed pairs hide the scraped ride
+// from public listings while the submitted ride is published.
+func (s *Service) Review(id int64, req ReviewRequest) (*Duplicate, error) {
+	switch req.Status {
+	case dedup.StatusConfirmed, dedup.StatusRejected, dedup.StatusPending:
+	default:
+		return nil, fmt.Errorf("%w: status must be %q, %q or %q", ErrInvalidRequest, dedup.StatusConfirmed, dedup.StatusRejected, dedup.StatusPending)
+	}
+
+	found, err := s.repo.SetStatus(id, req.Status, req.Notes)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	slog.Info("Reviewed duplicate rides", "id", id, "status", req.Status)
+
+	dup, err := s.repo.GetDuplicate(id)
+	if err != nil {
+		return nil, err
+	}
+	if dup == nil {
+		// one of the rides was deleted in the meantime
+		return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return dup, nil
+}
+
+// Detect re-scores the city's rides from today onwards, in the city's time zone
+func (s *Service) Detect(city string) (*DetectResponse, error) {
+	location, err := scraper.CityLocation(city)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	since := time.Now().In(location).Format("2006-01-02")
+
+	candidates, err := s.repo.Detect(city, since)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Detected duplicate rides", "city", city, "since", since, "candidates", candidates)
+
+	return &DetectResponse{City: city, Since: since, Candidates: candidates}, nil
+}
@@ -0,0 +1,152 @@
+package geocode
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spacesedan/cyclescene/functions/internal/api/middleware"
+)
+
+type Handler struct {
+	service   *Service
+	adminKeys middleware.AdminKeyValidator
+}
+
+func NewHandler(service *Service, adminKeys middleware.AdminKeyValidator) *Handler {
+	return &Handler{
+		service:   service,
+		adminKeys: adminKeys,
+	}
+}
+
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin/geocode", func(r chi.Router) {
+		r.Use(middleware.RequireAdminKey(h.adminKeys))
+
+		r.Get("/cache", h.ListCacheEntries)
+		r.Get("/fallbacks", h.ListFallbacks)
+		r.Put("/overrides", h.SetOverride)
+		r.Post("/overrides/apply", h.ApplyCorrection)
+	})
+}
+
+// ListCacheEntries lists geocode_cache entries with the number of rides using each
+// GET /v1/admin/geocode/cache?city=pdx&provider=manual&confidence=low&q=park&limit=50&offset=0
+func (h *Handler) ListCacheEntries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := CacheFilter{
+		City:       params.Get("city"),
+		Provider:   params.Get("provider"),
+		Confidence: params.Get("confidence"),
+		Search:     params.Get("q"),
+	}
+
+	var err error
+	if limit := params.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if offset := params.Get("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.service.ListCacheEntries(filter)
+	if err != nil {
+		slog.Error("Failed to list geocode cache", "error", err)
+		http.Error(w, "Failed to fetch geocode cache", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ListFallbacks lists upcoming rides that could not be geocoded
+// GET /v1/admin/geocode/fallbacks?city=pdx&since=2025-06-01
+func (h *Handler) ListFallbacks(w http.ResponseWriter, r *http.Request) {
+	city := r.URL.Query().Get("city")
+	if city == "" {
+		http.Error(w, "Missing city parameter", http.StatusBadRequest)
+		return
+	}
+
+	since := time.Now()
+	if sinceParam := r.URL.Query().Get("since"); sinceParam != "" {
+		parsed, err := time.Parse("2006-01-02", sinceParam)
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	fallbacks, err := h.service.ListFallbacks(city, since)
+	if err != nil {
+		slog.Error("Failed to list geocode fallbacks", "error", err, "city", city)
+		http.Error(w, "Failed to fetch geocode fallbacks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fallbacks)
+}
+
+// SetOverride pins a geocode query to manual coordinates
+// PUT /v1/admin/geocode/overrides
+func (h *Handler) SetOverride(w http.ResponseWriter, r *http.Request) {
+	var req OverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.service.SetOverride(req)
+	if err != nil {
+		h.writeError(w, err, "Failed to save geocode override")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ApplyCorrection moves every ride geocoded with a query to its cached coordinates
+// POST /v1/admin/geocode/overrides/apply
+func (h *Handler) ApplyCorrection(w http.ResponseWriter, r *http.Request) {
+	var req ApplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.ApplyCorrection(req.Query)
+	if err != nil {
+		h.writeError(w, err, "Failed to apply geocode correction")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		slog.Error(message, "error", err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
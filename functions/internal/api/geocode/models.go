@@ -0,0 +1,71 @@
+package geocode
+
+// CacheEntry is a geocode_cache row along with how many rides use it
+type CacheEntry struct {
+	Query            string  `json:"query"`
+	City             string  `json:"city"`
+	Latitude         float64 `json:"lat"`
+	Longitude        float64 `json:"lng"`
+	Provider         string  `json:"provider"`
+	PlaceID          string  `json:"place_id,omitempty"`
+	FormattedAddress string  `json:"formatted_address,omitempty"`
+	Granularity      string  `json:"granularity,omitempty"`
+	Confidence       string  `json:"confidence"`
+	ExpiresAt        string  `json:"expires_at,omitempty"`
+	LastUpdated      string  `json:"last_updated"`
+	RideCount        int     `json:"ride_count"`
+}
+
+// CacheFilter narrows the cache listing
+type CacheFilter struct {
+	City       string
+	Provider   string
+	Confidence string
+	// Search matches against the query and formatted address
+	Search string
+	Limit  int
+	Offset int
+}
+
+// Fallback is a ride that couldn't be located, so it sits on the city's
+// fallback coordinates or, for submitted rides, has none
+type Fallback struct {
+	// Query is the geocode_cache key to override, empty for rides without
+	// an address
+	Query     string  `json:"query"`
+	City      string  `json:"city"`
+	Source    string  `json:"source"`
+	RideID    string  `json:"ride_id"`
+	Title     string  `json:"title"`
+	Date      string  `json:"date,omitempty"`
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// OverrideRequest pins a geocode query to coordinates chosen by an admin
+type OverrideRequest struct {
+	Query            string  `json:"query"`
+	City             string  `json:"city"`
+	Latitude         float64 `json:"lat"`
+	Longitude        float64 `json:"lng"`
+	FormattedAddress string  `json:"formatted_address,omitempty"`
+	// Apply also moves every ride already geocoded with this query
+	Apply bool `json:"apply"`
+}
+
+// ApplyRequest re-applies a query's cached coordinates to its rides
+type ApplyRequest struct {
+	Query string `json:"query"`
+}
+
+// ApplyResult reports how many rides a correction moved
+type ApplyResult struct {
+	Query          string `json:"query"`
+	ScrapedRides   int64  `json:"scraped_rides"`
+	SubmittedRides int64  `json:"submitted_rides"`
+}
+
+type OverrideResponse struct {
+	Entry   *CacheEntry  `json:"entry"`
+	Applied *ApplyResult `json:"applied,omitempty"`
+}
@@ -0,0 +1,214 @@
+package geocode
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+const cacheEntryColumns = `
+	gc.location_key, gc.city, gc.lat, gc.lng, COALESCE(gc.provider, ''), COALESCE(gc.place_id, ''),
+	COALESCE(gc.formatted_address, ''), COALESCE(gc.granularity, ''), COALESCE(gc.confidence, 'unknown'),
+	COALESCE(gc.expires_at, ''), gc.last_updated,
+	(SELECT COUNT(*) FROM shift2bikes_events s WHERE s.geocode_query = gc.location_key) +
+	(SELECT COUNT(*) FROM events e WHERE e.geocode_query = gc.location_key)`
+
+func scanCacheEntry(row interface{ Scan(...any) error }) (CacheEntry, error) {
+	var entry CacheEntry
+	err := row.Scan(
+		&entry.Query, &entry.City, &entry.Latitude, &entry.Longitude, &entry.Provider, &entry.PlaceID,
+		&entry.FormattedAddress, &entry.Granularity, &entry.Confidence,
+		&entry.ExpiresAt, &entry.LastUpdated, &entry.RideCount,
+	)
+	return entry, err
+}
+
+// ListCacheEntries returns geocode_cache entries matching the filter, most
+// recently updated first
+func (r *Repository) ListCacheEntries(filter CacheFilter) ([]CacheEntry, error) {
+	var conditions []string
+	var args []any
+
+	if filter.City != "" {
+		conditions = append(conditions, "gc.city = ?")
+		args = append(args, filter.City)
+	}
+	if filter.Provider != "" {
+		conditions = append(conditions, "gc.provider = ?")
+		args = append(args, filter.Provider)
+	}
+	if filter.Confidence != "" {
+		conditions = append(conditions, "COALESCE(gc.confidence, 'unknown') = ?")
+		args = append(args, filter.Confidence)
+	}
+	if filter.Search != "" {
+		conditions = append(conditions, "(gc.location_key LIKE ? OR gc.formatted_address LIKE ?)")
+		pattern := "%" + strings.ToLower(filter.Search) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	query := `SELECT ` + cacheEntryColumns + ` FROM geocode_cache gc`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY gc.last_updated DESC, gc.location_key LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []CacheEntry{}
+	for rows.Next() {
+		entry, err := scanCacheEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetCacheEntry returns the entry for a normalized query, or nil if there is none
+func (r *Repository) GetCacheEntry(query string) (*CacheEntry, error) {
+	entry, err := scanCacheEntry(r.db.QueryRow(
+		`SELECT `+cacheEntryColumns+` FROM geocode_cache gc WHERE gc.location_key = ?`, query,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ListFallbacks returns upcoming rides whose location_quality is fallback,
+// meaning they couldn't be located. Rides without an address have no query
+// and can only be fixed upstream; the rest can be fixed with an override.
+func (r *Repository) ListFallbacks(city string, since time.Time) ([]Fallback, error) {
+	sinceDate := since.Format("2006-01-02")
+
+	rows, err := r.db.Query(`
+		SELECT COALESCE(s.geocode_query, ''), s.citycode, s.ridesource, s.composite_event_id, s.title, s.date, s.lat, s.lng
+		FROM shift2bikes_events s
+		WHERE s.location_quality = 'fallback'
+		  AND s.citycode = ?
+		  AND s.date >= ?
+		  AND s.removed_at IS NULL
+		UNION ALL
+		SELECT COALESCE(e.geocode_query, ''), e.city, 'submitted', CAST(e.id AS TEXT), e.title,
+		       COALESCE((SELECT MIN(o.start_date) FROM event_occurrences o WHERE o.event_id = e.id AND o.start_date >= ?), ''),
+		       COALESCE(e.latitude, 0), COALESCE(e.longitude, 0)
+		FROM events e
+		WHERE e.location_quality = 'fallback'
+		  AND e.city = ?
+		  AND EXISTS (SELECT 1 FROM event_occurrences o WHERE o.event_id = e.id AND o.start_date >= ?)
+		ORDER BY 1, 6
+	`, city, sinceDate, sinceDate, city, sinceDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fallbacks := []Fallback{}
+	for rows.Next() {
+		var fallback Fallback
+		if err := rows.Scan(
+			&fallback.Query, &fallback.City, &fallback.Source, &fallback.RideID, &fallback.Title,
+			&fallback.Date, &fallback.Latitude, &fallback.Longitude,
+		); err != nil {
+			return nil, err
+		}
+		fallbacks = append(fallbacks, fallback)
+	}
+
+	return fallbacks, rows.Err()
+}
+
+// UpsertOverride pins a query to manual coordinates. Manual entries have no
+// expiry and the scraper never overwrites them.
+func (r *Repository) UpsertOverride(req OverrideRequest) error {
+	_, err := r.db.Exec(`
+		INSERT INTO geocode_cache (
+			location_key, lat, lng, city, last_updated,
+			provider, place_id, formatted_address, granularity, types, viewport, confidence, expires_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, NULL, ?, NULL, NULL, NULL, ?, NULL)
+		ON CONFLICT(location_key) DO UPDATE SET
+			lat=excluded.lat,
+			lng=excluded.lng,
+			city=excluded.city,
+			last_updated=excluded.last_updated,
+			provider=excluded.provider,
+			place_id=excluded.place_id,
+			formatted_address=excluded.formatted_address,
+			granularity=excluded.granularity,
+			types=excluded.types,
+			viewport=excluded.viewport,
+			confidence=excluded.confidence,
+			expires_at=excluded.expires_at
+	`,
+		req.Query, req.Latitude, req.Longitude, req.City, time.Now().UTC().Format(time.RFC3339),
+		scraper.ManualProvider, nilIfEmpty(req.FormattedAddress), string(scraper.ConfidenceHigh),
+	)
+	return err
+}
+
+// ApplyCoordinates moves every scraped and submitted ride geocoded with query
+// to the given coordinates
+func (r *Repository) ApplyCoordinates(query string, lat, lng float64) (*ApplyResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	scraped, err := tx.Exec(`
+		UPDATE shift2bikes_events SET lat = ?, lng = ?, location_quality = 'cached' WHERE geocode_query = ?
+	`, lat, lng, query)
+	if err != nil {
+		return nil, err
+	}
+
+	submitted, err := tx.Exec(`
+		UPDATE events
+		SET latitude = ?, longitude = ?, location_quality = 'cached', updated_at = STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')
+		WHERE geocode_query = ?
+	`, lat, lng, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{Query: query}
+	result.ScrapedRides, _ = scraped.RowsAffected()
+	result.SubmittedRides, _ = submitted.RowsAffected()
+	return result, nil
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package geocode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid geocode request")
+	ErrNotFound       = errors.New("geocode cache entry not found")
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) ListCacheEntries(filter CacheFilter) ([]CacheEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	}
+	filter.Limit = min(filter.Limit, maxLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	return s.repo.ListCacheEntries(filter)
+}
+
+func (s *Service) ListFallbacks(city string, since time.Time) ([]Fallback, error) {
+	return s.repo.ListFallbacks(city, since)
+}
+
+// SetOverride pins a query to admin supplied coordinates, optionally moving
+// the rides that already use it
+func (s *Service) SetOverride(req OverrideRequest) (*OverrideResponse, error) {
+	req.Query = scraper.NormalizeGeocodeQuery(req.Query)
+	if req.Query == "" {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidRequest)
+	}
+
+	city, ok := scraper.GetCityDetails(req.City)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown city %q", ErrInvalidRequest, req.City)
+	}
+	if !city.RideBounds().Contains(req.Latitude, req.Longitude) {
+		return nil, fmt.Errorf("%w: coordinates %f,%f are outside %s", ErrInvalidRequest, req.Latitude, req.Longitude, city.CityName)
+	}
+
+	if err := s.repo.UpsertOverride(req); err != nil {
+		return nil, err
+	}
+	slog.Info("Pinned geocode override", "query", req.Query, "city", req.City, "lat", req.Latitude, "lng", req.Longitude)
+
+	entry, err := s.repo.GetCacheEntry(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &OverrideResponse{Entry: entry}
+	if req.Apply {
+		response.Applied, err = s.repo.ApplyCoordinates(req.Query, req.Latitude, req.Longitude)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// ApplyCorrection copies a query's cached coordinates onto every scraped and
+// submitted ride geocoded with it
+func (s *Service) ApplyCorrection(query string) (*ApplyResult, error) {
+	query = scraper.NormalizeGeocodeQuery(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidRequest)
+	}
+
+	entry, err := s.repo.GetCacheEntry(query)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+
+	result, err := s.repo.ApplyCoordinates(query, entry.Latitude, entry.Longitude)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Applied geocode correction", "query", query, "scraped_rides", result.ScrapedRides, "submitted_rides", result.SubmittedRides)
+
+	return result, nil
+}
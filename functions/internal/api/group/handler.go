@@ -93,6 +93,10 @@ func (h *Handler) RegisterGroup(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Group code already exists", http.StatusConflict)
 			return
 		}
+		if strings.Contains(err.Error(), "calendar URL") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		slog.Error("Failed to register group", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -157,6 +161,10 @@ func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Group not found", http.StatusNotFound)
 		return
 	}
+	if err != nil && strings.Contains(err.Error(), "calendar URL") {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to update group", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -7,7 +7,10 @@ type Registration struct {
 	City        string `json:"city"`
 	WebURL      string `json:"web_url"`
 	Email       string `json:"email"`
-	ImageUUID   string `json:"image_uuid"`
+	// ICalURL is nil when a request leaves it out, so an edit that doesn't
+	// send it keeps the group's feed
+	ICalURL   *string `json:"ical_url,omitempty"`
+	ImageUUID string  `json:"image_uuid"`
 }
 
 type Response struct {
@@ -63,20 +63,20 @@ func (r *Repository) CreateGroup(reg *Registration, editToken string) error {
 	marker := slugify(strings.ToUpper(reg.Code))
 
 	_, err := r.db.Exec(`
-		INSERT INTO ride_groups (id, code, name, description, city, web_url, email, edit_token, public_id, marker, is_active, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, CURRENT_TIMESTAMP)
-	`, groupID, strings.ToUpper(reg.Code), reg.Name, reg.Description, reg.City, reg.WebURL, reg.Email, editToken, publicID, marker)
+		INSERT INTO ride_groups (id, code, name, description, city, web_url, email, ical_url, edit_token, public_id, marker, is_active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, CURRENT_TIMESTAMP)
+	`, groupID, strings.ToUpper(reg.Code), reg.Name, reg.Description, reg.City, reg.WebURL, reg.Email, reg.ICalURL, editToken, publicID, marker)
 
 	return err
 }
 
 func (r *Repository) GetGroupByEditToken(token string) (*Registration, error) {
 	var reg Registration
-	var email sql.NullString
+	var email, icalURL sql.NullString
 	err := r.db.QueryRow(`
-		SELECT code, name, description, city, web_url, email
+		SELECT code, name, description, city, web_url, email, ical_url
 		FROM ride_groups WHERE edit_token = ?
-	`, token).Scan(&reg.Code, &reg.Name, &reg.Description, &reg.City, &reg.WebURL, &email)
+	`, token).Scan(&reg.Code, &reg.Name, &reg.Description, &reg.City, &reg.WebURL, &email, &icalURL)
 
 	if err != nil {
 		return nil, err
@@ -86,15 +86,19 @@ func (r *Repository) GetGroupByEditToken(token string) (*Registration, error) {
 		reg.Email = email.String
 	}
 
+	if icalURL.Valid && icalURL.String != "" {
+		reg.ICalURL = &icalURL.String
+	}
+
 	return &reg, nil
 }
 
 func (r *Repository) UpdateGroup(token string, reg *Registration) error {
 	result, err := r.db.Exec(`
 		UPDATE ride_groups SET
-			name = ?, description = ?, web_url = ?
+			name = ?, description = ?, web_url = ?, ical_url = COALESCE(?, ical_url)
 		WHERE edit_token = ?
-	`, reg.Name, reg.Description, reg.WebURL, token)
+	`, reg.Name, reg.Description, reg.WebURL, reg.ICalURL, token)
 
 	if err != nil {
 		return err
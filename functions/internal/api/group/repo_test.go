@@ -0,0 +1,65 @@
+package group
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpdateGroupICalURL(t *testing.T) {
+	feed := "https://example.com/rides.ics"
+
+	tests := []struct {
+		name string
+		// body is the JSON the edit form sends
+		body    string
+		wantArg driver.Value
+	}{
+		{
+			// The group edit form only sends name, description and web_url
+			name:    "edit without ical_url keeps the feed",
+			body:    `{"name":"Night Riders","description":"Weekly rides","web_url":"https://example.com"}`,
+			wantArg: nil,
+		},
+		{
+			name:    "edit with ical_url sets the feed",
+			body:    `{"name":"Night Riders","description":"Weekly rides","web_url":"https://example.com","ical_url":"` + feed + `"}`,
+			wantArg: feed,
+		},
+		{
+			name:    "edit with empty ical_url clears the feed",
+			body:    `{"name":"Night Riders","description":"Weekly rides","web_url":"https://example.com","ical_url":""}`,
+			wantArg: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to open mock database: %v", err)
+			}
+			defer db.Close()
+
+			var reg Registration
+			if err := json.Unmarshal([]byte(tt.body), &reg); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+
+			// A NULL ical_url leaves the stored feed as it is
+			mock.ExpectExec(regexp.QuoteMeta(`ical_url = COALESCE(?, ical_url)`)).
+				WithArgs("Night Riders", "Weekly rides", "https://example.com", tt.wantArg, "token").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			if err := NewRepository(db).UpdateGroup("token", &reg); err != nil {
+				t.Fatalf("UpdateGroup: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"strings"
 
 	"github.com/spacesedan/cyclescene/functions/internal/api/magiclink"
@@ -80,6 +81,10 @@ func (s *Service) RegisterGroup(reg *Registration) (*Response, error) {
 		return nil, errors.New("code must be exactly 4 characters")
 	}
 
+	if err := normalizeICalURL(reg); err != nil {
+		return nil, err
+	}
+
 	// Check if code is available
 	available, err := s.repo.CheckCodeAvailability(reg.Code)
 	if err != nil {
@@ -127,6 +132,10 @@ func (s *Service) GetGroupByEditToken(token string) (*Registration, error) {
 }
 
 func (s *Service) UpdateGroup(token string, reg *Registration) (*Response, error) {
+	if err := normalizeICalURL(reg); err != nil {
+		return nil, err
+	}
+
 	if err := s.repo.UpdateGroup(token, reg); err != nil {
 		return nil, err
 	}
@@ -137,6 +146,30 @@ func (s *Service) UpdateGroup(token string, reg *Registration) (*Response, error
 	}, nil
 }
 
+// normalizeICalURL checks that a registered calendar feed is a URL the scraper can fetch
+func normalizeICalURL(reg *Registration) error {
+	if reg.ICalURL == nil {
+		return nil
+	}
+	icalURL := strings.TrimSpace(*reg.ICalURL)
+	reg.ICalURL = &icalURL
+	if icalURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(icalURL)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid calendar URL")
+	}
+
+	switch u.Scheme {
+	case "http", "https", "webcal":
+		return nil
+	default:
+		return errors.New("invalid calendar URL: must be http, https or webcal")
+	}
+}
+
 func generateSecureToken(length int) (string, error) {
 	b := make([]byte, length)
 	if _, err := rand.Read(b); err != nil {
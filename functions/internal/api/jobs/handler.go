@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spacesedan/cyclescene/functions/internal/api/middleware"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Handler struct {
+	repo      *Repository
+	adminKeys middleware.AdminKeyValidator
+}
+
+func NewHandler(repo *Repository, adminKeys middleware.AdminKeyValidator) *Handler {
+	return &Handler{
+		repo:      repo,
+		adminKeys: adminKeys,
+	}
+}
+
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.With(middleware.RequireAdminKey(h.adminKeys)).Get("/admin/jobs", h.ListRuns)
+}
+
+// ListRuns returns recent runs of the scheduled jobs, newest first
+// GET /v1/admin/jobs?job=scraper&city=pdx&status=failed&limit=50
+func (h *Handler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := Filter{
+		Job:    params.Get("job"),
+		City:   params.Get("city"),
+		Status: params.Get("status"),
+		Limit:  defaultLimit,
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(parsed, maxLimit)
+	}
+
+	runs, err := h.repo.ListRuns(filter)
+	if err != nil {
+		slog.Error("Failed to list job runs", "error", err)
+		http.Error(w, "Failed to fetch job runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
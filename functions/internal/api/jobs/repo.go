@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// JobRun is a row of job_runs
+type JobRun struct {
+	ID             string   `json:"id"`
+	Job            string   `json:"job"`
+	City           string   `json:"city,omitempty"`
+	Status         string   `json:"status"`
+	StartedAt      string   `json:"started_at"`
+	FinishedAt     string   `json:"finished_at,omitempty"`
+	DurationMs     int64    `json:"duration_ms,omitempty"`
+	EventsSeen     int      `json:"events_seen"`
+	EventsUpserted int      `json:"events_upserted"`
+	EventsGeocoded int      `json:"events_geocoded"`
+	Fallbacks      int      `json:"fallbacks"`
+	RoutesCreated  int      `json:"routes_created"`
+	ErrorCount     int      `json:"error_count"`
+	Errors         []string `json:"errors,omitempty"`
+	Detail         string   `json:"detail,omitempty"`
+}
+
+// Filter narrows the run listing. Empty fields match everything.
+type Filter struct {
+	Job    string
+	City   string
+	Status string
+	Limit  int
+}
+
+// ListRuns returns job runs matching the filter, newest first
+func (r *Repository) ListRuns(filter Filter) ([]JobRun, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Job != "" {
+		conditions = append(conditions, "job = ?")
+		args = append(args, filter.Job)
+	}
+	if filter.City != "" {
+		conditions = append(conditions, "city = ?")
+		args = append(args, filter.City)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := `
+		SELECT id, job, COALESCE(city, ''), status, started_at, COALESCE(finished_at, ''),
+		       COALESCE(duration_ms, 0), events_seen, events_upserted, events_geocoded,
+		       fallbacks, routes_created, error_count, errors, COALESCE(detail, '')
+		FROM job_runs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY started_at DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var run JobRun
+		var errorsJSON sql.NullString
+
+		if err := rows.Scan(
+			&run.ID, &run.Job, &run.City, &run.Status, &run.StartedAt, &run.FinishedAt,
+			&run.DurationMs, &run.EventsSeen, &run.EventsUpserted, &run.EventsGeocoded,
+			&run.Fallbacks, &run.RoutesCreated, &run.ErrorCount, &errorsJSON, &run.Detail,
+		); err != nil {
+			return nil, err
+		}
+
+		if errorsJSON.Valid {
+			if err := json.Unmarshal([]byte(errorsJSON.String), &run.Errors); err != nil {
+				run.Errors = []string{errorsJSON.String}
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// AdminKeyValidator checks an admin API key against the admin_api_keys table
+type AdminKeyValidator interface {
+	ValidateAdminKey(apiKey string) (bool, error)
+}
+
+// RequireAdminKey returns a Chi middleware that rejects requests without a
+// valid X-Admin-Token header
+func RequireAdminKey(validator AdminKeyValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := r.Header.Get("X-Admin-Token")
+			if apiKey == "" {
+				http.Error(w, "Missing admin API key", http.StatusUnauthorized)
+				return
+			}
+
+			valid, err := validator.ValidateAdminKey(apiKey)
+			if err != nil {
+				slog.Error("Error validating admin key", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if !valid {
+				http.Error(w, "Invalid admin API key", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
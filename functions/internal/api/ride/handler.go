@@ -3,14 +3,18 @@ package ride
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/spacesedan/cyclescene/functions/internal/api/auth"
 	"github.com/spacesedan/cyclescene/functions/internal/api/events"
+	"github.com/spacesedan/cyclescene/functions/internal/tagging"
 )
 
 type Handler struct {
@@ -38,11 +42,14 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 		// Admin endpoints
 		r.Get("/admin/pending", h.GetPendingRides)
 		r.Patch("/admin/{id}/publish", h.PublishRide)
+		r.Get("/admin/changes", h.GetScrapeChanges)
 
 		// Scraped rides from Shift2Bikes
 		r.Get("/upcoming", h.GetUpcomingRides)
 		r.Get("/past", h.GetPastRides)
 		r.Get("/ics", h.GenerateICS)
+		r.Get("/tags", h.GetTags)
+		r.Get("/series", h.GetSeries)
 	})
 }
 
@@ -83,6 +90,10 @@ func (h *Handler) SubmitRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response, err := h.service.SubmitRide(&submission)
+	if errors.Is(err, ErrUnknownTag) || errors.Is(err, ErrInvalidRouteFile) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to submit ride", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -141,6 +152,10 @@ func (h *Handler) UpdateRide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response, err := h.service.UpdateRide(token, &submission)
+	if errors.Is(err, ErrUnknownTag) || errors.Is(err, ErrInvalidRouteFile) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err == sql.ErrNoRows {
 		http.Error(w, "Ride not found", http.StatusNotFound)
 		return
@@ -203,7 +218,7 @@ func (h *Handler) GetUpcomingRides(w http.ResponseWriter, r *http.Request) {
 		cityCode = "pdx"
 	}
 
-	rides, err := h.service.GetUpcomingRides(cityCode)
+	rides, err := h.service.GetUpcomingRides(cityCode, rideFilter(r))
 	if err != nil {
 		slog.Error("Failed to get upcoming rides", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -226,7 +241,7 @@ func (h *Handler) GetPastRides(w http.ResponseWriter, r *http.Request) {
 		cityCode = "pdx"
 	}
 
-	rides, err := h.service.GetPastRides(cityCode)
+	rides, err := h.service.GetPastRides(cityCode, rideFilter(r))
 	if err != nil {
 		slog.Error("Failed to get past rides", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -243,6 +258,67 @@ func (h *Handler) GetPastRides(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// rideFilter reads a listing's filters: tags, given as repeated or comma
+// separated tag parameters (?tag=gravel&tag=no-drop or ?tag=gravel,no-drop),
+// and an area code (?area=P)
+func rideFilter(r *http.Request) RideFilter {
+	filter := RideFilter{Area: strings.TrimSpace(r.URL.Query().Get("area"))}
+	for _, value := range r.URL.Query()["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = tagging.NormalizeTag(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+	return filter
+}
+
+// GetTags lists the tags rides in a city can carry, for filters and the
+// submission form
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	cityCode := r.URL.Query().Get("city")
+	if cityCode == "" {
+		cityCode = "pdx"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if err := json.NewEncoder(w).Encode(h.service.GetTags(cityCode)); err != nil {
+		slog.Error("Failed to encode tags to JSON", "error", err)
+	}
+}
+
+// GetSeries returns every date of a repeating ride, by the series_id rides
+// carry in listings
+func (h *Handler) GetSeries(w http.ResponseWriter, r *http.Request) {
+	seriesID := r.URL.Query().Get("id")
+	if seriesID == "" {
+		http.Error(w, "Series ID is required", http.StatusBadRequest)
+		return
+	}
+	cityCode := r.URL.Query().Get("city")
+	if cityCode == "" {
+		cityCode = "pdx"
+	}
+
+	rideSeries, err := h.service.GetSeries(cityCode, seriesID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to get ride series", "error", err, "city", cityCode, "seriesID", seriesID)
+		http.Error(w, "Failed to get ride series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(rideSeries); err != nil {
+		slog.Error("Failed to encode ride series to JSON", "error", err)
+	}
+}
+
 func (h *Handler) GenerateICS(w http.ResponseWriter, r *http.Request) {
 	rideID := r.URL.Query().Get("id")
 	cityCode := r.URL.Query().Get("city")
@@ -350,3 +426,41 @@ func (h *Handler) PublishRide(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Ride published successfully"})
 }
+
+// GetScrapeChanges lists what the scraper created, updated, cancelled or
+// removed in a city. since accepts RFC 3339 or YYYY-MM-DD and defaults to
+// the last 24 hours.
+func (h *Handler) GetScrapeChanges(w http.ResponseWriter, r *http.Request) {
+	if !h.validateAdminKey(w, r) {
+		return
+	}
+
+	city := r.URL.Query().Get("city")
+	if city == "" {
+		http.Error(w, "Missing city parameter", http.StatusBadRequest)
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	if sinceParam := r.URL.Query().Get("since"); sinceParam != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			parsed, err = time.Parse("2006-01-02", sinceParam)
+		}
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	changes, err := h.service.GetScrapeChanges(city, since)
+	if err != nil {
+		slog.Error("Failed to get scrape changes", "error", err, "city", city)
+		http.Error(w, "Failed to fetch scrape changes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
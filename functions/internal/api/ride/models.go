@@ -2,8 +2,13 @@ package ride
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
 )
 
 // User-submitted rides
@@ -41,8 +46,14 @@ type Submission struct {
 	// Group
 	GroupCode string `json:"group_code"`
 
-	// Route
-	RouteURL string `json:"route_url"`
+	// Route, as a RideWithGPS or Strava link or the image_uuid of a GPX, KML,
+	// TCX or FIT file uploaded for the "route" entity type. An upload takes
+	// precedence over a link.
+	RouteURL        string `json:"route_url"`
+	RouteUploadUUID string `json:"route_upload_uuid,omitempty"`
+
+	// Tags the submitter picked; the tagging rules add their own on top
+	Tags []string `json:"tags,omitempty"`
 
 	// City
 	City string `json:"city"`
@@ -59,6 +70,21 @@ type Occurrence struct {
 	EventTimeDetails     string `json:"event_time_details"`
 	IsCancelled          bool   `json:"is_cancelled,omitempty"`
 	Newsflash            string `json:"newsflash,omitempty"`
+	StartsAt             string `json:"starts_at,omitempty"`
+	EndsAt               string `json:"ends_at,omitempty"`
+}
+
+// setOccurrenceTimes fills in the ISO-8601 start and end of each occurrence of
+// a ride in city
+func setOccurrenceTimes(city string, occurrences []Occurrence) {
+	location, err := cityLocation(city)
+	if err != nil {
+		return
+	}
+	for i := range occurrences {
+		occ := &occurrences[i]
+		occ.StartsAt, occ.EndsAt = formatEventTimes(location, occ.StartDate, occ.StartTime, "", occ.EventDurationMinutes)
+	}
 }
 
 type SubmissionResponse struct {
@@ -90,71 +116,118 @@ type RideForAdmin struct {
 	Occurrences     []Occurrence `json:"occurrences"`
 }
 
+// ScrapeChange is an entry in the scraper's per-run change log
+type ScrapeChange struct {
+	ID               int64           `json:"id"`
+	RunID            string          `json:"run_id"`
+	CompositeEventID string          `json:"composite_event_id"`
+	Title            string          `json:"title"`
+	City             string          `json:"city"`
+	RideSource       string          `json:"ridesource"`
+	ChangeType       string          `json:"change_type"`
+	Diff             json.RawMessage `json:"diff,omitempty"`
+	ChangedAt        string          `json:"changed_at"`
+}
+
 // Scraped rides from Shift2Bikes
 type ScrapedRideFromDB struct {
-	ID            string         `json:"id"`
-	Title         string         `json:"title"`
-	Lat           float64        `json:"lat"`
-	Lng           float64        `json:"lng"`
-	Address       string         `json:"address"`
-	Audience      string         `json:"audience"`
-	Cancelled     int            `json:"cancelled"`
-	Date          string         `json:"date"`
-	StartTime     string         `json:"starttime"`
-	SafetyPlan    int            `json:"safetyplan"`
-	Details       string         `json:"details"`
-	Venue         string         `json:"venue"`
-	Organizer     string         `json:"organizer"`
-	LoopRide      int            `json:"loopride"`
-	Shareable     string         `json:"shareable"`
-	RideSource    string         `json:"ridesource"`
-	RouteID       sql.NullString `json:"route_id"`
-	EndTime       sql.NullString `json:"endtime"`
-	Email         sql.NullString `json:"email"`
-	EventDuration sql.NullInt32  `json:"eventduration"`
-	Image         sql.NullString `json:"image"`
-	LocDetails    sql.NullString `json:"locdetails"`
-	LocEnd        sql.NullString `json:"locend"`
-	NewsFlash     sql.NullString `json:"newsflash"`
-	TimeDetails   sql.NullString `json:"timedetails"`
-	WebURL        sql.NullString `json:"weburl"`
-	WebName       sql.NullString `json:"webname"`
-	GroupMarker   sql.NullString `json:"group_marker"`
+	ID              string          `json:"id"`
+	Title           string          `json:"title"`
+	Lat             float64         `json:"lat"`
+	Lng             float64         `json:"lng"`
+	EndLat          sql.NullFloat64 `json:"end_lat"`
+	EndLng          sql.NullFloat64 `json:"end_lng"`
+	Address         string          `json:"address"`
+	Audience        string          `json:"audience"`
+	Cancelled       int             `json:"cancelled"`
+	Date            string          `json:"date"`
+	StartTime       string          `json:"starttime"`
+	SafetyPlan      int             `json:"safetyplan"`
+	Details         string          `json:"details"`
+	Venue           string          `json:"venue"`
+	Organizer       string          `json:"organizer"`
+	LoopRide        int             `json:"loopride"`
+	Shareable       string          `json:"shareable"`
+	RideSource      string          `json:"ridesource"`
+	RouteID         sql.NullString  `json:"route_id"`
+	EndTime         sql.NullString  `json:"endtime"`
+	Email           sql.NullString  `json:"email"`
+	EventDuration   sql.NullInt32   `json:"eventduration"`
+	Image           sql.NullString  `json:"image"`
+	LocDetails      sql.NullString  `json:"locdetails"`
+	LocEnd          sql.NullString  `json:"locend"`
+	NewsFlash       sql.NullString  `json:"newsflash"`
+	TimeDetails     sql.NullString  `json:"timedetails"`
+	WebURL          sql.NullString  `json:"weburl"`
+	WebName         sql.NullString  `json:"webname"`
+	GroupMarker     sql.NullString  `json:"group_marker"`
+	Tags            sql.NullString  `json:"tags"`
+	Length          sql.NullString  `json:"length"`
+	Area            sql.NullString  `json:"area"`
+	Featured        int             `json:"featured"`
+	TinyTitle       sql.NullString  `json:"tinytitle"`
+	PrintDescr      sql.NullString  `json:"printdescr"`
+	DatesType       sql.NullString  `json:"datestype"`
+	CaldailyID      sql.NullString  `json:"caldaily_id"`
+	Status          sql.NullString  `json:"status"`
+	SeriesID        string          `json:"series_id"`
+	Recurrence      sql.NullString  `json:"recurrence"`
+	LocationQuality string          `json:"location_quality"`
 }
 
 type ScrapedRide struct {
-	ID            string  `json:"id"`
-	Title         string  `json:"title"`
-	Lat           float64 `json:"lat"`
-	Lng           float64 `json:"lng"`
-	Address       string  `json:"address"`
-	Audience      string  `json:"audience"`
-	Cancelled     int     `json:"cancelled"`
-	Date          string  `json:"date"`
-	StartTime     string  `json:"starttime"`
-	SafetyPlan    int     `json:"safetyplan"`
-	Details       string  `json:"details"`
-	Venue         string  `json:"venue"`
-	Organizer     string  `json:"organizer"`
-	LoopRide      int     `json:"loopride"`
-	Shareable     string  `json:"shareable"`
-	RideSource    string  `json:"ridesource"`
-	RouteID       string  `json:"route_id"`
-	EndTime       string  `json:"endtime"`
-	Email         string  `json:"email"`
-	EventDuration int32   `json:"eventduration"`
-	Image         string  `json:"image"`
-	ImageSrcSet   string  `json:"image_srcset"`
-	LocDetails    string  `json:"locdetails"`
-	LocEnd        string  `json:"locend"`
-	NewsFlash     string  `json:"newsflash"`
-	TimeDetails   string  `json:"timedetails"`
-	WebURL        string  `json:"weburl"`
-	WebName       string  `json:"webname"`
-	GroupMarker   string  `json:"group_marker"`
-}
-
-func (rdb *ScrapedRideFromDB) ToScrapedRide() ScrapedRide {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Lat           float64  `json:"lat"`
+	Lng           float64  `json:"lng"`
+	EndLat        float64  `json:"end_lat,omitempty"`
+	EndLng        float64  `json:"end_lng,omitempty"`
+	Address       string   `json:"address"`
+	Audience      string   `json:"audience"`
+	Cancelled     int      `json:"cancelled"`
+	Date          string   `json:"date"`
+	StartTime     string   `json:"starttime"`
+	StartsAt      string   `json:"starts_at,omitempty"`
+	EndsAt        string   `json:"ends_at,omitempty"`
+	SafetyPlan    int      `json:"safetyplan"`
+	Details       string   `json:"details"`
+	Venue         string   `json:"venue"`
+	Organizer     string   `json:"organizer"`
+	LoopRide      int      `json:"loopride"`
+	Shareable     string   `json:"shareable"`
+	RideSource    string   `json:"ridesource"`
+	RouteID       string   `json:"route_id"`
+	EndTime       string   `json:"endtime"`
+	Email         string   `json:"email"`
+	EventDuration int32    `json:"eventduration"`
+	Image         string   `json:"image"`
+	ImageSrcSet   string   `json:"image_srcset"`
+	LocDetails    string   `json:"locdetails"`
+	LocEnd        string   `json:"locend"`
+	NewsFlash     string   `json:"newsflash"`
+	TimeDetails   string   `json:"timedetails"`
+	WebURL        string   `json:"weburl"`
+	WebName       string   `json:"webname"`
+	GroupMarker   string   `json:"group_marker"`
+	Tags          []string `json:"tags"`
+	Length        string   `json:"length"`
+	Area          string   `json:"area"`
+	Featured      int      `json:"featured"`
+	TinyTitle     string   `json:"tinytitle"`
+	PrintDescr    string   `json:"printdescr"`
+	DatesType     string   `json:"datestype"`
+	CaldailyID    string   `json:"caldaily_id"`
+	Status        string   `json:"status"`
+	SeriesID      string   `json:"series_id"`
+	Recurrence    string   `json:"recurrence,omitempty"`
+	// LocationQuality says where lat/lng came from. Fallback rides couldn't
+	// be located, so their coordinates are only a placeholder.
+	LocationQuality string `json:"location_quality"`
+}
+
+// ToScrapedRide converts a stored ride for the API, resolving its local date
+// and times in location into ISO-8601 timestamps
+func (rdb *ScrapedRideFromDB) ToScrapedRide(location *time.Location) ScrapedRide {
 	r := ScrapedRide{
 		ID:         rdb.ID,
 		Title:      rdb.Title,
@@ -173,6 +246,8 @@ func (rdb *ScrapedRideFromDB) ToScrapedRide() ScrapedRide {
 		Shareable:  rdb.Shareable,
 		RideSource: rdb.RideSource,
 	}
+	r.EndLat = rdb.EndLat.Float64
+	r.EndLng = rdb.EndLng.Float64
 	r.EndTime = rdb.EndTime.String
 	r.Email = rdb.Email.String
 	if rdb.EventDuration.Valid {
@@ -194,9 +269,94 @@ func (rdb *ScrapedRideFromDB) ToScrapedRide() ScrapedRide {
 	r.WebName = rdb.WebName.String
 	r.GroupMarker = rdb.GroupMarker.String
 	r.RouteID = rdb.RouteID.String
+	r.Length = rdb.Length.String
+	r.Area = rdb.Area.String
+	r.Featured = rdb.Featured
+	r.TinyTitle = rdb.TinyTitle.String
+	r.PrintDescr = rdb.PrintDescr.String
+	r.DatesType = rdb.DatesType.String
+	r.CaldailyID = rdb.CaldailyID.String
+	r.Status = rdb.Status.String
+	r.SeriesID = rdb.SeriesID
+	r.Recurrence = rdb.Recurrence.String
+	r.LocationQuality = rdb.LocationQuality
+
+	// tags arrive comma separated from GROUP_CONCAT
+	r.Tags = []string{}
+	if rdb.Tags.String != "" {
+		r.Tags = strings.Split(rdb.Tags.String, ",")
+		slices.Sort(r.Tags)
+	}
+
+	r.StartsAt, r.EndsAt = formatEventTimes(location, r.Date, r.StartTime, r.EndTime, int(r.EventDuration))
 	return r
 }
 
+// formatEventTimes returns a ride's start and end as RFC 3339 timestamps with
+// the city's UTC offset, or empty strings for whichever can't be resolved
+func formatEventTimes(location *time.Location, date, startTime, endTime string, durationMinutes int) (string, string) {
+	times, err := scraper.ResolveEventTimes(location, date, startTime, endTime, durationMinutes)
+	if err != nil {
+		return "", ""
+	}
+
+	var endsAt string
+	if times.HasEnd() {
+		endsAt = times.End.Format(time.RFC3339)
+	}
+	return times.Start.Format(time.RFC3339), endsAt
+}
+
+// RideSeries is every date of a repeating ride, from either source
+type RideSeries struct {
+	ID     string `json:"id"`
+	City   string `json:"city"`
+	Source string `json:"ridesource"`
+	Title  string `json:"title"`
+	// Recurrence is once, daily, weekly, biweekly, monthly, weekday or irregular
+	Recurrence string `json:"recurrence"`
+	// Summary describes the recurrence, e.g. "Every Thursday"
+	Summary string `json:"summary"`
+	Weekday string `json:"weekday,omitempty"`
+	// Week is the week of the month of a monthly series, or -1 for the last
+	Week        int                `json:"week,omitempty"`
+	FirstDate   string             `json:"first_date"`
+	LastDate    string             `json:"last_date"`
+	Occurrences []SeriesOccurrence `json:"occurrences"`
+}
+
+// SeriesOccurrence is one date of a series. RideID is the id the ride has in
+// listings.
+type SeriesOccurrence struct {
+	RideID    string `json:"ride_id"`
+	Date      string `json:"date"`
+	StartTime string `json:"starttime"`
+	StartsAt  string `json:"starts_at,omitempty"`
+	EndsAt    string `json:"ends_at,omitempty"`
+	Cancelled bool   `json:"cancelled"`
+}
+
+// RideFilter narrows a ride listing. Zero values don't filter.
+type RideFilter struct {
+	// Tags the rides must all carry
+	Tags []string
+	// Area is a Shift2Bikes area code, such as "P" for Portland
+	Area string
+}
+
+// matches reports whether a ride passes the filter
+func (f RideFilter) matches(ride ScrapedRide) bool {
+	if f.Area != "" && !strings.EqualFold(ride.Area, f.Area) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(ride.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
 type ICSContent struct {
 	Filename string
 	Content  string
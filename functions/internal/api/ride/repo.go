@@ -3,11 +3,14 @@ package ride
 import (
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+	"github.com/spacesedan/cyclescene/functions/internal/series"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,7 +23,7 @@ func NewRepository(db *sql.DB) *Repository {
 }
 
 // User-submitted rides
-func (r *Repository) CreateRide(submission *Submission, editToken string, latitude, longitude float64) (int64, error) {
+func (r *Repository) CreateRide(submission *Submission, editToken string, latitude, longitude float64, locationQuality scraper.LocationQuality, geocodeQuery string, endLatitude, endLongitude float64, ruleTags []string) (int64, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return 0, err
@@ -35,8 +38,8 @@ func (r *Repository) CreateRide(submission *Submission, editToken string, latitu
 			venue_name, address, location_details, ending_location, is_loop_ride,
 			organizer_name, organizer_email, organizer_phone, web_url, web_name, newsflash,
 			hide_email, hide_phone, hide_contact_name, group_code, edit_token, city, is_published,
-			latitude, longitude
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?)
+			latitude, longitude, geocode_query, end_lat, end_lng, location_quality
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, ?)
 	`,
 		submission.Title, submission.TinyTitle, submission.Description, submission.ImageURL,
 		submission.Audience, submission.RideLength, submission.Area, submission.DateType,
@@ -44,7 +47,8 @@ func (r *Repository) CreateRide(submission *Submission, editToken string, latitu
 		boolToInt(submission.IsLoopRide), submission.OrganizerName, submission.OrganizerEmail,
 		submission.OrganizerPhone, submission.WebURL, submission.WebName, submission.Newsflash,
 		boolToInt(submission.HideEmail), boolToInt(submission.HidePhone), boolToInt(submission.HideContactName),
-		nilIfEmpty(submission.GroupCode), editToken, submission.City, latitude, longitude,
+		nilIfEmpty(submission.GroupCode), editToken, submission.City, latitude, longitude, nilIfEmpty(geocodeQuery),
+		nilIfZero(endLatitude), nilIfZero(endLongitude), string(locationQuality),
 	)
 
 	if err != nil {
@@ -71,6 +75,10 @@ func (r *Repository) CreateRide(submission *Submission, editToken string, latitu
 		}
 	}
 
+	if err := replaceEventTags(tx, eventID, submission.Tags, ruleTags); err != nil {
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
@@ -134,6 +142,23 @@ func (r *Repository) GetRideByEditToken(token string) (*Submission, bool, error)
 		occ.IsCancelled = isCancelled == 1
 		submission.Occurrences = append(submission.Occurrences, occ)
 	}
+	setOccurrenceTimes(submission.City, submission.Occurrences)
+
+	// only the submitter's own tags, so saving the form doesn't pin rule tags
+	tagRows, err := r.db.Query(`
+		SELECT tag FROM event_tags WHERE event_id = ? AND source = 'submitter' ORDER BY tag
+	`, id)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var tag string
+		if err := tagRows.Scan(&tag); err != nil {
+			return nil, false, err
+		}
+		submission.Tags = append(submission.Tags, tag)
+	}
 
 	// Generate SrcSet if image is present and is an optimized WebP
 	if submission.ImageURL != "" && strings.HasSuffix(submission.ImageURL, "_optimized.webp") {
@@ -144,7 +169,7 @@ func (r *Repository) GetRideByEditToken(token string) (*Submission, bool, error)
 	return &submission, isPublished == 1, nil
 }
 
-func (r *Repository) UpdateRide(token string, submission *Submission, latitude, longitude float64) error {
+func (r *Repository) UpdateRide(token string, submission *Submission, latitude, longitude float64, locationQuality scraper.LocationQuality, geocodeQuery string, endLatitude, endLongitude float64, ruleTags []string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
@@ -161,7 +186,7 @@ func (r *Repository) UpdateRide(token string, submission *Submission, latitude,
 			organizer_name = ?, organizer_email = ?, organizer_phone = ?,
 			web_url = ?, web_name = ?, newsflash = ?,
 			hide_email = ?, hide_phone = ?, hide_contact_name = ?,
-			group_code = ?, latitude = ?, longitude = ?, updated_at = STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')
+			group_code = ?, latitude = ?, longitude = ?, geocode_query = ?, end_lat = ?, end_lng = ?, location_quality = ?, updated_at = STRFTIME('%Y-%m-%d %H:%M:%f', 'NOW')
 		WHERE edit_token = ?
 	`,
 		submission.Title, submission.TinyTitle, submission.Description, submission.ImageURL,
@@ -170,7 +195,8 @@ func (r *Repository) UpdateRide(token string, submission *Submission, latitude,
 		boolToInt(submission.IsLoopRide), submission.OrganizerName, submission.OrganizerEmail,
 		submission.OrganizerPhone, submission.WebURL, submission.WebName, submission.Newsflash,
 		boolToInt(submission.HideEmail), boolToInt(submission.HidePhone), boolToInt(submission.HideContactName),
-		nilIfEmpty(submission.GroupCode), latitude, longitude, token,
+		nilIfEmpty(submission.GroupCode), latitude, longitude, nilIfEmpty(geocodeQuery),
+		nilIfZero(endLatitude), nilIfZero(endLongitude), string(locationQuality), token,
 	)
 
 	if err != nil {
@@ -210,9 +236,40 @@ func (r *Repository) UpdateRide(token string, submission *Submission, latitude,
 		}
 	}
 
+	if err := replaceEventTags(tx, eventID, submission.Tags, ruleTags); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
+// replaceEventTags replaces a submitted ride's tags with the ones its
+// submitter picked and the ones the tagging rules gave it. A tag that is both
+// is kept as the submitter's.
+func replaceEventTags(tx *sql.Tx, eventID int64, submitterTags, ruleTags []string) error {
+	if _, err := tx.Exec(`DELETE FROM event_tags WHERE event_id = ?`, eventID); err != nil {
+		return err
+	}
+
+	for _, tag := range submitterTags {
+		if _, err := tx.Exec(`
+			INSERT INTO event_tags (event_id, tag, source) VALUES (?, ?, 'submitter')
+			ON CONFLICT(event_id, tag) DO NOTHING
+		`, eventID, tag); err != nil {
+			return err
+		}
+	}
+	for _, tag := range ruleTags {
+		if _, err := tx.Exec(`
+			INSERT INTO event_tags (event_id, tag, source) VALUES (?, ?, 'rule')
+			ON CONFLICT(event_id, tag) DO NOTHING
+		`, eventID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // UpdateOccurrence updates a single occurrence's time, details, and newsflash
 func (r *Repository) UpdateOccurrence(token string, occurrenceID int64, startTime string, eventDurationMinutes int, eventTimeDetails string, newsflash string, isCancelled bool) error {
 	_, err := r.db.Exec(`
@@ -223,10 +280,9 @@ func (r *Repository) UpdateOccurrence(token string, occurrenceID int64, startTim
 	return err
 }
 
-// Scraped rides from Shift2Bikes (PDX only) + Published user-submitted rides
+// Scraped rides + Published user-submitted rides
 func (r *Repository) GetUpcomingRides(city string) ([]ScrapedRideFromDB, error) {
-	tzStr := getTimeZone(city)
-	tz, err := time.LoadLocation(tzStr)
+	tz, err := cityLocation(city)
 	if err != nil {
 		slog.Error("failed to load timezone", "error", err.Error())
 		return nil, err
@@ -234,104 +290,80 @@ func (r *Repository) GetUpcomingRides(city string) ([]ScrapedRideFromDB, error)
 	now := time.Now().In(tz)
 	todayStr := now.Format("2006-01-02")
 
-	var query string
-	var args []any
-
-	// For PDX, include both shift2bikes_events and user-submitted events
-	// For other cities, only include user-submitted events
-	if city == "pdx" {
-		query = `
-			SELECT composite_event_id, title, lat, lng, address, audience, cancelled, date, starttime,
-			       safetyplan, details, venue, organizer, loopride, shareable, ridesource,
-			       route_id, endtime,
-			       email, eventduration, image, locdetails, locend, newsflash, timedetails, webname, weburl,
-			       NULL as group_marker
-			FROM shift2bikes_events
-			WHERE citycode = ? AND date >= ?
-			UNION ALL
-			SELECT
-				CAST(e.id AS TEXT) as composite_event_id,
-				e.title,
-				e.latitude as lat,
-				e.longitude as lng,
-				e.address,
-				e.audience,
-				eo.is_cancelled as cancelled,
-				eo.start_date as date,
-				eo.start_time as starttime,
-				0 as safetyplan,
-				e.description as details,
-				e.venue_name as venue,
-				e.organizer_name as organizer,
-				e.is_loop_ride as loopride,
-				'' as shareable,
-				'user-submitted' as ridesource,
-				e.route_id,
-				'' as endtime,
-				e.organizer_email as email,
-				eo.event_duration_minutes as eventduration,
-				e.image_url as image,
-				e.location_details as locdetails,
-				e.ending_location as locend,
-				e.newsflash,
-				eo.event_time_details as timedetails,
-				e.web_name as webname,
-				e.web_url as weburl,
-				rg.marker as group_marker
-			FROM events e
-			JOIN event_occurrences eo ON e.id = eo.event_id
-			LEFT JOIN ride_groups rg ON e.group_code = rg.code
-			WHERE e.city = ? AND e.is_published = 1 AND eo.start_date >= ?
-			ORDER BY date ASC, starttime ASC
-		`
-		args = []any{city, todayStr, city, todayStr}
-	} else {
-		// For non-PDX cities, only query user-submitted events
-		query = `
-			SELECT
-				CAST(e.id AS TEXT) as composite_event_id,
-				e.title,
-				e.latitude as lat,
-				e.longitude as lng,
-				e.address,
-				e.audience,
-				eo.is_cancelled as cancelled,
-				eo.start_date as date,
-				eo.start_time as starttime,
-				0 as safetyplan,
-				e.description as details,
-				e.venue_name as venue,
-				e.organizer_name as organizer,
-				e.is_loop_ride as loopride,
-				'' as shareable,
-				'user-submitted' as ridesource,
-				e.route_id,
-				'' as endtime,
-				e.organizer_email as email,
-				eo.event_duration_minutes as eventduration,
-				e.image_url as image,
-				e.location_details as locdetails,
-				e.ending_location as locend,
-				e.newsflash,
-				eo.event_time_details as timedetails,
-				e.web_name as webname,
-				e.web_url as weburl,
-				rg.marker as group_marker
-			FROM events e
-			JOIN event_occurrences eo ON e.id = eo.event_id
-			LEFT JOIN ride_groups rg ON e.group_code = rg.code
-			WHERE e.city = ? AND e.is_published = 1 AND eo.start_date >= ?
-			ORDER BY date ASC, starttime ASC
-		`
-		args = []any{city, todayStr}
-	}
+	// Scraped rides from every source plus published user-submitted rides
+	query := `
+		SELECT composite_event_id, title, lat, lng, end_lat, end_lng, address, audience, cancelled, date, starttime,
+		       safetyplan, details, venue, organizer, loopride, shareable, ridesource,
+		       route_id, endtime,
+		       email, eventduration,
+		       COALESCE((SELECT im.optimized_url FROM image_mirrors im WHERE im.source_url = shift2bikes_events.image AND im.status = 'done'), image) as image,
+		       locdetails, locend, newsflash, timedetails, webname, weburl,
+		       (SELECT rg.marker FROM ride_groups rg WHERE rg.code = shift2bikes_events.group_code) as group_marker,
+		       (SELECT GROUP_CONCAT(t.tag) FROM shift2bikes_event_tags t WHERE t.composite_event_id = shift2bikes_events.composite_event_id) as tags,
+		       length, area, featured, tinytitle, printdescr, datestype, caldaily_id, status,
+		       ridesource || ':' || id as series_id,
+		       (SELECT rs.summary FROM ride_series rs WHERE rs.series_id = shift2bikes_events.ridesource || ':' || shift2bikes_events.id) as recurrence,
+		       location_quality
+		FROM shift2bikes_events
+		WHERE citycode = ? AND date >= ? AND removed_at IS NULL
+		  AND composite_event_id NOT IN (` + confirmedDuplicates + `)
+		UNION ALL
+		SELECT
+			CAST(e.id AS TEXT) as composite_event_id,
+			e.title,
+			e.latitude as lat,
+			e.longitude as lng,
+			e.end_lat,
+			e.end_lng,
+			e.address,
+			e.audience,
+			eo.is_cancelled as cancelled,
+			eo.start_date as date,
+			eo.start_time as starttime,
+			0 as safetyplan,
+			e.description as details,
+			e.venue_name as venue,
+			e.organizer_name as organizer,
+			e.is_loop_ride as loopride,
+			'' as shareable,
+			'user-submitted' as ridesource,
+			e.route_id,
+			'' as endtime,
+			e.organizer_email as email,
+			eo.event_duration_minutes as eventduration,
+			e.image_url as image,
+			e.location_details as locdetails,
+			e.ending_location as locend,
+			e.newsflash,
+			eo.event_time_details as timedetails,
+			e.web_name as webname,
+			e.web_url as weburl,
+			rg.marker as group_marker,
+			(SELECT GROUP_CONCAT(t.tag) FROM event_tags t WHERE t.event_id = e.id) as tags,
+			e.ride_length as length,
+			e.area,
+			e.is_featured as featured,
+			e.tinytitle,
+			NULL as printdescr,
+			e.date_type as datestype,
+			NULL as caldaily_id,
+			NULL as status,
+			'user-submitted:' || e.id as series_id,
+			(SELECT rs.summary FROM ride_series rs WHERE rs.series_id = 'user-submitted:' || e.id) as recurrence,
+			e.location_quality as location_quality
+		FROM events e
+		JOIN event_occurrences eo ON e.id = eo.event_id
+		LEFT JOIN ride_groups rg ON e.group_code = rg.code
+		WHERE e.city = ? AND e.is_published = 1 AND eo.start_date >= ?
+		ORDER BY date ASC, starttime ASC
+	`
+	args := []any{city, todayStr, city, todayStr}
 
 	return r.scanScrapedRides(query, args...)
 }
 
 func (r *Repository) GetPastRides(city string) ([]ScrapedRideFromDB, error) {
-	tzStr := getTimeZone(city)
-	tz, err := time.LoadLocation(tzStr)
+	tz, err := cityLocation(city)
 	if err != nil {
 		slog.Error("failed to load timezone", "error", err.Error())
 		return nil, err
@@ -341,110 +373,102 @@ func (r *Repository) GetPastRides(city string) ([]ScrapedRideFromDB, error) {
 	todayStr := now.Format("2006-01-02")
 	sevenDaysAgoStr := sevenDaysAgo.Format("2006-01-02")
 
-	var query string
-	var args []any
-
-	// For PDX, include both shift2bikes_events and user-submitted events
-	// For other cities, only include user-submitted events
-	if city == "pdx" {
-		query = `
-			SELECT composite_event_id, title, lat, lng, address, audience, cancelled, date, starttime,
-			       safetyplan, details, venue, organizer, loopride, shareable, ridesource,
-			       route_id, endtime,
-			       email, eventduration, image, locdetails, locend, newsflash, timedetails, webname, weburl,
-			       NULL as group_marker
-			FROM shift2bikes_events
-			WHERE citycode = ? AND date BETWEEN ? AND ?
-			UNION ALL
-			SELECT
-				CAST(e.id AS TEXT) as composite_event_id,
-				e.title,
-				e.latitude as lat,
-				e.longitude as lng,
-				e.address,
-				e.audience,
-				eo.is_cancelled as cancelled,
-				eo.start_date as date,
-				eo.start_time as starttime,
-				0 as safetyplan,
-				e.description as details,
-				e.venue_name as venue,
-				e.organizer_name as organizer,
-				e.is_loop_ride as loopride,
-				'' as shareable,
-				'user-submitted' as ridesource,
-				e.route_id,
-				'' as endtime,
-				e.organizer_email as email,
-				eo.event_duration_minutes as eventduration,
-				e.image_url as image,
-				e.location_details as locdetails,
-				e.ending_location as locend,
-				e.newsflash,
-				eo.event_time_details as timedetails,
-				e.web_name as webname,
-				e.web_url as weburl,
-				rg.marker as group_marker
-			FROM events e
-			JOIN event_occurrences eo ON e.id = eo.event_id
-			LEFT JOIN ride_groups rg ON e.group_code = rg.code
-			WHERE e.city = ? AND e.is_published = 1 AND eo.start_date BETWEEN ? AND ?
-			ORDER BY date DESC, starttime DESC
-		`
-		args = []any{city, sevenDaysAgoStr, todayStr, city, sevenDaysAgoStr, todayStr}
-	} else {
-		// For non-PDX cities, only query user-submitted events
-		query = `
-			SELECT
-				CAST(e.id AS TEXT) as composite_event_id,
-				e.title,
-				e.latitude as lat,
-				e.longitude as lng,
-				e.address,
-				e.audience,
-				eo.is_cancelled as cancelled,
-				eo.start_date as date,
-				eo.start_time as starttime,
-				0 as safetyplan,
-				e.description as details,
-				e.venue_name as venue,
-				e.organizer_name as organizer,
-				e.is_loop_ride as loopride,
-				'' as shareable,
-				'user-submitted' as ridesource,
-				e.route_id,
-				'' as endtime,
-				e.organizer_email as email,
-				eo.event_duration_minutes as eventduration,
-				e.image_url as image,
-				e.location_details as locdetails,
-				e.ending_location as locend,
-				e.newsflash,
-				eo.event_time_details as timedetails,
-				e.web_name as webname,
-				e.web_url as weburl,
-				rg.marker as group_marker
-			FROM events e
-			JOIN event_occurrences eo ON e.id = eo.event_id
-			LEFT JOIN ride_groups rg ON e.group_code = rg.code
-			WHERE e.city = ? AND e.is_published = 1 AND eo.start_date BETWEEN ? AND ?
-			ORDER BY date DESC, starttime DESC
-		`
-		args = []any{city, sevenDaysAgoStr, todayStr}
-	}
+	// Scraped rides from every source plus published user-submitted rides
+	query := `
+		SELECT composite_event_id, title, lat, lng, end_lat, end_lng, address, audience, cancelled, date, starttime,
+		       safetyplan, details, venue, organizer, loopride, shareable, ridesource,
+		       route_id, endtime,
+		       email, eventduration,
+		       COALESCE((SELECT im.optimized_url FROM image_mirrors im WHERE im.source_url = shift2bikes_events.image AND im.status = 'done'), image) as image,
+		       locdetails, locend, newsflash, timedetails, webname, weburl,
+		       (SELECT rg.marker FROM ride_groups rg WHERE rg.code = shift2bikes_events.group_code) as group_marker,
+		       (SELECT GROUP_CONCAT(t.tag) FROM shift2bikes_event_tags t WHERE t.composite_event_id = shift2bikes_events.composite_event_id) as tags,
+		       length, area, featured, tinytitle, printdescr, datestype, caldaily_id, status,
+		       ridesource || ':' || id as series_id,
+		       (SELECT rs.summary FROM ride_series rs WHERE rs.series_id = shift2bikes_events.ridesource || ':' || shift2bikes_events.id) as recurrence,
+		       location_quality
+		FROM shift2bikes_events
+		WHERE citycode = ? AND date BETWEEN ? AND ? AND removed_at IS NULL
+		  AND composite_event_id NOT IN (` + confirmedDuplicates + `)
+		UNION ALL
+		SELECT
+			CAST(e.id AS TEXT) as composite_event_id,
+			e.title,
+			e.latitude as lat,
+			e.longitude as lng,
+			e.end_lat,
+			e.end_lng,
+			e.address,
+			e.audience,
+			eo.is_cancelled as cancelled,
+			eo.start_date as date,
+			eo.start_time as starttime,
+			0 as safetyplan,
+			e.description as details,
+			e.venue_name as venue,
+			e.organizer_name as organizer,
+			e.is_loop_ride as loopride,
+			'' as shareable,
+			'user-submitted' as ridesource,
+			e.route_id,
+			'' as endtime,
+			e.organizer_email as email,
+			eo.event_duration_minutes as eventduration,
+			e.image_url as image,
+			e.location_details as locdetails,
+			e.ending_location as locend,
+			e.newsflash,
+			eo.event_time_details as timedetails,
+			e.web_name as webname,
+			e.web_url as weburl,
+			rg.marker as group_marker,
+			(SELECT GROUP_CONCAT(t.tag) FROM event_tags t WHERE t.event_id = e.id) as tags,
+			e.ride_length as length,
+			e.area,
+			e.is_featured as featured,
+			e.tinytitle,
+			NULL as printdescr,
+			e.date_type as datestype,
+			NULL as caldaily_id,
+			NULL as status,
+			'user-submitted:' || e.id as series_id,
+			(SELECT rs.summary FROM ride_series rs WHERE rs.series_id = 'user-submitted:' || e.id) as recurrence,
+			e.location_quality as location_quality
+		FROM events e
+		JOIN event_occurrences eo ON e.id = eo.event_id
+		LEFT JOIN ride_groups rg ON e.group_code = rg.code
+		WHERE e.city = ? AND e.is_published = 1 AND eo.start_date BETWEEN ? AND ?
+		ORDER BY date DESC, starttime DESC
+	`
+	args := []any{city, sevenDaysAgoStr, todayStr, city, sevenDaysAgoStr, todayStr}
 
 	return r.scanScrapedRides(query, args...)
 }
 
+// confirmedDuplicates selects scraped rides an admin confirmed are the same as
+// a published, user-submitted ride, so listings show that ride only once
+const confirmedDuplicates = `
+	SELECT d.composite_event_id
+	FROM ride_duplicates d
+	JOIN events de ON de.id = d.event_id
+	WHERE d.status = 'confirmed' AND de.is_published = 1`
+
 func (r *Repository) GetRide(city, rideID string) ([]ScrapedRideFromDB, error) {
 	query := `
-		SELECT composite_event_id, title, lat, lng, address, audience, cancelled, date, starttime,
+		SELECT composite_event_id, title, lat, lng, end_lat, end_lng, address, audience, cancelled, date, starttime,
 		       safetyplan, details, venue, organizer, loopride, shareable, ridesource,
 		       route_id, endtime,
-		       email, eventduration, image, locdetails, locend, newsflash, timedetails, webname, weburl,
-		       NULL as group_marker
+		       email, eventduration,
+		       COALESCE((SELECT im.optimized_url FROM image_mirrors im WHERE im.source_url = shift2bikes_events.image AND im.status = 'done'), image) as image,
+		       locdetails, locend, newsflash, timedetails, webname, weburl,
+		       (SELECT rg.marker FROM ride_groups rg WHERE rg.code = shift2bikes_events.group_code) as group_marker,
+		       (SELECT GROUP_CONCAT(t.tag) FROM shift2bikes_event_tags t WHERE t.composite_event_id = shift2bikes_events.composite_event_id) as tags,
+		       length, area, featured, tinytitle, printdescr, datestype, caldaily_id, status,
+		       ridesource || ':' || id as series_id,
+		       (SELECT rs.summary FROM ride_series rs WHERE rs.series_id = shift2bikes_events.ridesource || ':' || shift2bikes_events.id) as recurrence,
+		       location_quality
 		FROM shift2bikes_events
-		WHERE composite_event_id = ? AND citycode = ?
+		WHERE composite_event_id = ? AND citycode = ? AND removed_at IS NULL
 	`
 	return r.scanScrapedRides(query, rideID, city)
 }
@@ -460,13 +484,16 @@ func (r *Repository) scanScrapedRides(query string, args ...any) ([]ScrapedRideF
 	for rows.Next() {
 		var ride ScrapedRideFromDB
 		if err := rows.Scan(
-			&ride.ID, &ride.Title, &ride.Lat, &ride.Lng, &ride.Address,
+			&ride.ID, &ride.Title, &ride.Lat, &ride.Lng, &ride.EndLat, &ride.EndLng, &ride.Address,
 			&ride.Audience, &ride.Cancelled, &ride.Date, &ride.StartTime,
 			&ride.SafetyPlan, &ride.Details, &ride.Venue, &ride.Organizer,
 			&ride.LoopRide, &ride.Shareable, &ride.RideSource, &ride.RouteID, &ride.EndTime,
 			&ride.Email, &ride.EventDuration, &ride.Image, &ride.LocDetails,
 			&ride.LocEnd, &ride.NewsFlash, &ride.TimeDetails, &ride.WebName, &ride.WebURL,
-			&ride.GroupMarker,
+			&ride.GroupMarker, &ride.Tags,
+			&ride.Length, &ride.Area, &ride.Featured, &ride.TinyTitle, &ride.PrintDescr,
+			&ride.DatesType, &ride.CaldailyID, &ride.Status, &ride.SeriesID, &ride.Recurrence,
+			&ride.LocationQuality,
 		); err != nil {
 			return nil, err
 		}
@@ -475,15 +502,92 @@ func (r *Repository) scanScrapedRides(query string, args ...any) ([]ScrapedRideF
 	return rides, nil
 }
 
-func getTimeZone(city string) string {
-	timezones := map[string]string{
-		"pdx": "America/Los_Angeles",
-		"slc": "America/Denver",
+// GetSeries returns a ride series in a city and every date still listed for
+// it, oldest first. Series of submitted rides are only returned once the ride
+// is published.
+func (r *Repository) GetSeries(city, seriesID string) (*RideSeries, error) {
+	var rs RideSeries
+	var weekday, week sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT series_id, citycode, ridesource, title, recurrence, weekday, week, summary, first_date, last_date
+		FROM ride_series
+		WHERE series_id = ? AND citycode = ?
+	`, seriesID, city).Scan(&rs.ID, &rs.City, &rs.Source, &rs.Title, &rs.Recurrence, &weekday, &week,
+		&rs.Summary, &rs.FirstDate, &rs.LastDate)
+	if err != nil {
+		return nil, err
 	}
-	if tz, ok := timezones[city]; ok {
-		return tz
+	if weekday.Valid {
+		rs.Weekday = time.Weekday(weekday.Int64).String()
 	}
-	return "America/Los_Angeles" // default
+	if week.Valid {
+		rs.Week = int(week.Int64)
+	}
+
+	upstreamID := strings.TrimPrefix(rs.ID, rs.Source+":")
+	var rows *sql.Rows
+	if rs.Source == series.SubmittedSource {
+		rows, err = r.db.Query(`
+			SELECT CAST(e.id AS TEXT), eo.start_date, eo.start_time, '', COALESCE(eo.event_duration_minutes, 0), eo.is_cancelled
+			FROM events e
+			JOIN event_occurrences eo ON e.id = eo.event_id
+			WHERE e.id = ? AND e.city = ? AND e.is_published = 1
+			ORDER BY eo.start_date ASC, eo.start_time ASC
+		`, upstreamID, city)
+	} else {
+		rows, err = r.db.Query(`
+			SELECT composite_event_id, date, starttime, COALESCE(endtime, ''), COALESCE(eventduration, 0), cancelled
+			FROM shift2bikes_events
+			WHERE citycode = ? AND ridesource = ? AND id = ? AND removed_at IS NULL
+			ORDER BY date ASC, starttime ASC
+		`, city, rs.Source, upstreamID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	location, err := cityLocation(city)
+	if err != nil {
+		return nil, err
+	}
+
+	rs.Occurrences = []SeriesOccurrence{}
+	for rows.Next() {
+		var occ SeriesOccurrence
+		var endTime string
+		var duration, cancelled int
+		if err := rows.Scan(&occ.RideID, &occ.Date, &occ.StartTime, &endTime, &duration, &cancelled); err != nil {
+			return nil, err
+		}
+		occ.Cancelled = cancelled == 1
+		occ.StartsAt, occ.EndsAt = formatEventTimes(location, occ.Date, occ.StartTime, endTime, duration)
+		rs.Occurrences = append(rs.Occurrences, occ)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(rs.Occurrences) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return &rs, nil
+}
+
+// SyncSeries rebuilds the series of a submitted ride from its occurrences
+func (r *Repository) SyncSeries(eventID int64) error {
+	return series.SyncSubmitted(r.db, eventID)
+}
+
+// defaultTimeZone is used for cities missing from cities.json
+const defaultTimeZone = "America/Los_Angeles"
+
+// cityLocation returns the time zone a city's rides are scheduled in
+func cityLocation(city string) (*time.Location, error) {
+	if location, err := scraper.CityLocation(city); err == nil {
+		return location, nil
+	}
+	return time.LoadLocation(defaultTimeZone)
 }
 
 func boolToInt(b bool) int {
@@ -500,6 +604,13 @@ func nilIfEmpty(s string) any {
 	return s
 }
 
+func nilIfZero(f float64) any {
+	if f == 0 {
+		return nil
+	}
+	return f
+}
+
 // GetPendingRides returns all rides that are not yet published
 func (r *Repository) GetPendingRides() ([]RideForAdmin, error) {
 	rows, err := r.db.Query(`
@@ -556,6 +667,42 @@ func (r *Repository) PublishRide(rideID int, moderationNotes string) error {
 	return err
 }
 
+// GetScrapeChanges returns the scraper's changes for a city since the given time, newest first
+func (r *Repository) GetScrapeChanges(city string, since time.Time) ([]ScrapeChange, error) {
+	rows, err := r.db.Query(`
+		SELECT sc.id, sc.run_id, sc.composite_event_id, COALESCE(s.title, ''), sc.citycode,
+		       sc.ridesource, sc.change_type, sc.diff, sc.changed_at
+		FROM scrape_changes sc
+		LEFT JOIN shift2bikes_events s ON s.composite_event_id = sc.composite_event_id
+		WHERE sc.citycode = ? AND sc.changed_at >= ?
+		ORDER BY sc.changed_at DESC, sc.id DESC
+	`, city, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []ScrapeChange{}
+	for rows.Next() {
+		var change ScrapeChange
+		var diff sql.NullString
+
+		if err := rows.Scan(
+			&change.ID, &change.RunID, &change.CompositeEventID, &change.Title, &change.City,
+			&change.RideSource, &change.ChangeType, &diff, &change.ChangedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if diff.Valid {
+			change.Diff = json.RawMessage(diff.String)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
 // ValidateAdminKey checks if an API key is valid and not revoked
 func (r *Repository) ValidateAdminKey(apiKey string) (bool, error) {
 	// Decode the provided key from base64 to get raw bytes
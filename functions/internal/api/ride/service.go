@@ -4,23 +4,41 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/spacesedan/cyclescene/functions/internal/api/magiclink"
+	"github.com/spacesedan/cyclescene/functions/internal/api/storage"
 	"github.com/spacesedan/cyclescene/functions/internal/routes"
 	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+	"github.com/spacesedan/cyclescene/functions/internal/tagging"
 )
 
+// geocodeTimeout bounds a submitted address lookup, cache included
+const geocodeTimeout = 15 * time.Second
+
+// ErrUnknownTag is returned when a submission carries a tag that isn't
+// configured
+var ErrUnknownTag = errors.New("unknown tag")
+
+// ErrInvalidRouteFile is returned when a submission's uploaded route file is
+// missing, too large, or not a route the converter can read
+var ErrInvalidRouteFile = errors.New("invalid route file")
+
 type Service struct {
 	repo            *Repository
 	magicLinkSvc    *magiclink.Service
 	editLinkBaseURL string
 	routeFetcher    *routes.RouteFetcher
 	routeRepository *routes.Repository
+	routeFiles      *storage.Service
+	geocoder        scraper.Geocoder
+	tagger          *tagging.Tagger
 }
 
 func NewService(repo *Repository) *Service {
@@ -40,6 +58,96 @@ func (s *Service) SetRouteServices(fetcher *routes.RouteFetcher, routeRepo *rout
 	s.routeRepository = routeRepo
 }
 
+// SetRouteFiles sets where route files organizers upload are read from.
+// Without it, submissions can only attach routes by link.
+func (s *Service) SetRouteFiles(files *storage.Service) {
+	s.routeFiles = files
+}
+
+func (s *Service) SetGeocoder(geocoder scraper.Geocoder) {
+	s.geocoder = geocoder
+}
+
+// SetTagger sets the rules submitted rides are tagged with. Without one,
+// submitted rides keep only the tags their submitter picked.
+func (s *Service) SetTagger(tagger *tagging.Tagger) {
+	s.tagger = tagger
+}
+
+// tag normalizes the submitter's tags, rejecting unknown ones, and returns the
+// tags the rules give the ride
+func (s *Service) tag(submission *Submission) ([]string, error) {
+	var submitterTags []string
+	for _, tag := range submission.Tags {
+		tag = tagging.NormalizeTag(tag)
+		if tag == "" || slices.Contains(submitterTags, tag) {
+			continue
+		}
+		if s.tagger != nil && !s.tagger.Known(tag) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTag, tag)
+		}
+		submitterTags = append(submitterTags, tag)
+	}
+	submission.Tags = submitterTags
+
+	if s.tagger == nil {
+		return nil, nil
+	}
+	var startTime string
+	if len(submission.Occurrences) > 0 {
+		startTime = submission.Occurrences[0].StartTime
+	}
+	return s.tagger.Tag(tagging.Input{
+		City:      submission.City,
+		Audience:  submission.Audience,
+		StartTime: startTime,
+		Text:      []string{submission.Title, submission.Description, submission.RideLength},
+	}), nil
+}
+
+// GetTags returns the tags rides in a city can carry
+func (s *Service) GetTags(city string) []tagging.Tag {
+	if s.tagger == nil {
+		return []tagging.Tag{}
+	}
+	return s.tagger.TagsFor(city)
+}
+
+// geocode resolves a submitted address and reports where its coordinates
+// came from, returning 0,0 when no geocoder is configured or the lookup fails
+// so the ride can still be saved
+func (s *Service) geocode(query, city string) (float64, float64, scraper.LocationQuality) {
+	if s.geocoder == nil {
+		slog.Warn("No geocoder configured, skipping geocoding", "query", query, "city", city)
+		return 0.0, 0.0, scraper.LocationFallback
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), geocodeTimeout)
+	defer cancel()
+
+	result, err := s.geocoder.Geocode(ctx, query, city)
+	if err != nil {
+		slog.Warn("Failed to geocode address", "geocodequery", query, "city", city, "provider", s.geocoder.Name(), "error", err)
+		return 0.0, 0.0, scraper.LocationFallback
+	}
+
+	slog.Info("Successfully geocoded address", "geocodequery", query, "lat", result.Latitude, "lng", result.Longitude, "provider", result.Provider, "cached", result.Cached)
+	if result.Cached {
+		return result.Latitude, result.Longitude, scraper.LocationCached
+	}
+	return result.Latitude, result.Longitude, scraper.LocationGeocoded
+}
+
+// geocodeEnd resolves a submitted ride's ending location, returning 0,0 for
+// loop rides and when it can't be found
+func (s *Service) geocodeEnd(submission *Submission) (float64, float64) {
+	if submission.IsLoopRide || strings.TrimSpace(submission.EndingLocation) == "" {
+		return 0.0, 0.0
+	}
+	lat, lng, _ := s.geocode(submission.EndingLocation, submission.City)
+	return lat, lng
+}
+
 // User-submitted rides
 func (s *Service) SubmitRide(submission *Submission) (*SubmissionResponse, error) {
 	// Generate edit token
@@ -48,37 +156,36 @@ func (s *Service) SubmitRide(submission *Submission) (*SubmissionResponse, error
 		return nil, err
 	}
 
+	ruleTags, err := s.tag(submission)
+	if err != nil {
+		return nil, err
+	}
+
 	// Geocode the address to get latitude and longitude
 	var lat, lng float64
+	var geocodeQuery string
+	quality := scraper.LocationFallback
 	if submission.Address != "" {
-		geocodeQuery := fmt.Sprintf("%s %s", submission.VenueName, submission.Address)
-		lat, lng, err = scraper.GeocodeQuery(geocodeQuery, submission.City)
-		if err != nil {
-			slog.Warn("Failed to geocode address", "geocodequery", geocodeQuery, "city", submission.City, "error", err)
-			// Continue with 0,0 coordinates if geocoding fails
-			lat, lng = 0.0, 0.0
-		} else {
-			slog.Info("Successfully geocoded address", "address", submission.Address, "lat", lat, "lng", lng)
-		}
+		geocodeQuery = fmt.Sprintf("%s %s", submission.VenueName, submission.Address)
+		lat, lng, quality = s.geocode(geocodeQuery, submission.City)
 	}
 
+	// Geocode where the ride finishes, unless it's a loop
+	endLat, endLng := s.geocodeEnd(submission)
+
 	// Process route if provided
-	var routeID *string
-	if submission.RouteURL != "" && s.routeFetcher != nil && s.routeRepository != nil {
-		routeID, err = s.processRoute(context.Background(), submission.RouteURL, submission.City)
-		if err != nil {
-			slog.Warn("Failed to process route", "error", err, "routeURL", submission.RouteURL)
-			// Continue without route if processing fails
-		} else if routeID != nil {
-			slog.Info("Route processed successfully", "routeID", *routeID, "routeURL", submission.RouteURL)
-		}
+	routeID, err := s.attachRoute(submission)
+	if err != nil {
+		return nil, err
 	}
 
-	eventID, err := s.repo.CreateRide(submission, editToken, lat, lng)
+	eventID, err := s.repo.CreateRide(submission, editToken, lat, lng, quality, scraper.NormalizeGeocodeQuery(geocodeQuery), endLat, endLng, ruleTags)
 	if err != nil {
 		return nil, err
 	}
 
+	s.syncSeries(eventID)
+
 	// Link route to ride if route was processed
 	if routeID != nil {
 		if err := s.repo.LinkRouteToRide(eventID, *routeID); err != nil {
@@ -126,36 +233,37 @@ func (s *Service) UpdateOccurrence(token string, occurrenceID int64, startTime s
 }
 
 func (s *Service) UpdateRide(token string, submission *Submission) (*SubmissionResponse, error) {
+	ruleTags, err := s.tag(submission)
+	if err != nil {
+		return nil, err
+	}
+
 	// Geocode the address to get latitude and longitude
 	var lat, lng float64
+	var geocodeQuery string
+	quality := scraper.LocationFallback
 	if submission.Address != "" {
-		var err error
-		lat, lng, err = scraper.GeocodeQuery(submission.Address, submission.City)
-		if err != nil {
-			slog.Warn("Failed to geocode address", "address", submission.Address, "city", submission.City, "error", err)
-			// Continue with 0,0 coordinates if geocoding fails
-			lat, lng = 0.0, 0.0
-		} else {
-			slog.Info("Successfully geocoded address", "address", submission.Address, "lat", lat, "lng", lng)
-		}
+		geocodeQuery = submission.Address
+		lat, lng, quality = s.geocode(geocodeQuery, submission.City)
 	}
 
+	// Geocode where the ride finishes, unless it's a loop
+	endLat, endLng := s.geocodeEnd(submission)
+
 	// Process route if provided
-	var routeID *string
-	if submission.RouteURL != "" && s.routeFetcher != nil && s.routeRepository != nil {
-		routeID, err := s.processRoute(context.Background(), submission.RouteURL, submission.City)
-		if err != nil {
-			slog.Warn("Failed to process route", "error", err, "routeURL", submission.RouteURL)
-			// Continue without route if processing fails
-		} else if routeID != nil {
-			slog.Info("Route processed successfully", "routeID", *routeID, "routeURL", submission.RouteURL)
-		}
+	routeID, err := s.attachRoute(submission)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.UpdateRide(token, submission, lat, lng); err != nil {
+	if err := s.repo.UpdateRide(token, submission, lat, lng, quality, scraper.NormalizeGeocodeQuery(geocodeQuery), endLat, endLng, ruleTags); err != nil {
 		return nil, err
 	}
 
+	if eventID, err := s.repo.GetEventIDByEditToken(token); err == nil {
+		s.syncSeries(eventID)
+	}
+
 	// Link route to ride if route was processed
 	if routeID != nil {
 		// Get event ID from token
@@ -173,6 +281,84 @@ func (s *Service) UpdateRide(token string, submission *Submission) (*SubmissionR
 	}, nil
 }
 
+// syncSeries refreshes the series of a submitted ride after its dates change.
+// Failures are only logged; the series catches up on the next edit.
+func (s *Service) syncSeries(eventID int64) {
+	if err := s.repo.SyncSeries(eventID); err != nil {
+		slog.Warn("Failed to sync ride series", "error", err, "eventID", eventID)
+	}
+}
+
+// GetSeries returns a repeating ride and all of its dates
+func (s *Service) GetSeries(city, seriesID string) (*RideSeries, error) {
+	return s.repo.GetSeries(city, seriesID)
+}
+
+// attachRoute stores the route a submission carries and returns its id, or
+// nil when it has none. A route file that can't be used rejects the
+// submission; any other failure is logged and the ride is saved without its
+// route.
+func (s *Service) attachRoute(submission *Submission) (*string, error) {
+	ctx := context.Background()
+
+	switch {
+	case submission.RouteUploadUUID != "" && s.routeFiles != nil && s.routeRepository != nil:
+		routeID, err := s.processRouteUpload(ctx, submission.RouteUploadUUID, submission.City)
+		if errors.Is(err, ErrInvalidRouteFile) {
+			return nil, err
+		}
+		if err != nil {
+			slog.Warn("Failed to process route upload", "error", err, "uploadUUID", submission.RouteUploadUUID)
+			return nil, nil
+		}
+		slog.Info("Route upload processed successfully", "routeID", *routeID, "uploadUUID", submission.RouteUploadUUID)
+		return routeID, nil
+
+	case submission.RouteURL != "" && s.routeFetcher != nil && s.routeRepository != nil:
+		routeID, err := s.processRoute(ctx, submission.RouteURL, submission.City)
+		if err != nil {
+			slog.Warn("Failed to process route", "error", err, "routeURL", submission.RouteURL)
+			// Continue without route if processing fails
+			return nil, nil
+		}
+		slog.Info("Route processed successfully", "routeID", *routeID, "routeURL", submission.RouteURL)
+		return routeID, nil
+	}
+
+	return nil, nil
+}
+
+// processRouteUpload validates and converts a route file an organizer
+// uploaded, and stores it as an "upload" route keyed by its upload UUID
+func (s *Service) processRouteUpload(ctx context.Context, uploadUUID string, city string) (*string, error) {
+	data, err := s.routeFiles.ReadRouteFile(ctx, uploadUUID)
+	if errors.Is(err, storage.ErrRouteFileNotFound) || errors.Is(err, storage.ErrRouteFileTooLarge) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRouteFile, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	feature, format, err := routes.ConvertToGeoJSON(data)
+	if err == nil {
+		err = routes.ValidateFeature(feature)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRouteFile, err)
+	}
+
+	distanceKm, distanceMi := featureDistance(feature)
+
+	// Uploads have no page to link back to
+	routeID, err := s.routeRepository.CreateRoute(ctx, "upload", uploadUUID, "", city, feature, distanceKm, distanceMi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create route: %w", err)
+	}
+
+	slog.Info("Route file converted", "routeID", routeID, "format", format, "distanceKm", distanceKm)
+	return &routeID, nil
+}
+
 // processRoute fetches, converts, and deduplicates a route
 func (s *Service) processRoute(ctx context.Context, routeURL string, city string) (*string, error) {
 	// Fetch and convert route
@@ -181,14 +367,7 @@ func (s *Service) processRoute(ctx context.Context, routeURL string, city string
 		return nil, fmt.Errorf("failed to fetch route: %w", err)
 	}
 
-	// Extract distance from properties
-	var distanceKm, distanceMi float64
-	if km, ok := feature.Properties["distance_km"].(float64); ok {
-		distanceKm = km
-	}
-	if mi, ok := feature.Properties["distance_mi"].(float64); ok {
-		distanceMi = mi
-	}
+	distanceKm, distanceMi := featureDistance(feature)
 
 	// Parse source and source ID from URL
 	source, sourceID, err := routes.ParseRouteURL(routeURL)
@@ -205,17 +384,36 @@ func (s *Service) processRoute(ctx context.Context, routeURL string, city string
 	return &routeID, nil
 }
 
+// featureDistance extracts a converted route's distance from its properties
+func featureDistance(feature routes.GeoJSONFeature) (distanceKm, distanceMi float64) {
+	if km, ok := feature.Properties["distance_km"].(float64); ok {
+		distanceKm = km
+	}
+	if mi, ok := feature.Properties["distance_mi"].(float64); ok {
+		distanceMi = mi
+	}
+	return distanceKm, distanceMi
+}
+
 // Scraped rides from Shift2Bikes
-func (s *Service) GetUpcomingRides(city string) ([]ScrapedRide, error) {
+func (s *Service) GetUpcomingRides(city string, filter RideFilter) ([]ScrapedRide, error) {
 	storedRides, err := s.repo.GetUpcomingRides(city)
 	if err != nil {
 		slog.Error("Failed to query upcoming rides", "error", err)
 		return nil, err
 	}
 
+	location, err := cityLocation(city)
+	if err != nil {
+		return nil, err
+	}
+
 	var rides []ScrapedRide
 	for i := range storedRides {
-		rides = append(rides, storedRides[i].ToScrapedRide())
+		ride := storedRides[i].ToScrapedRide(location)
+		if filter.matches(ride) {
+			rides = append(rides, ride)
+		}
 	}
 
 	if rides == nil {
@@ -225,16 +423,24 @@ func (s *Service) GetUpcomingRides(city string) ([]ScrapedRide, error) {
 	return rides, nil
 }
 
-func (s *Service) GetPastRides(city string) ([]ScrapedRide, error) {
+func (s *Service) GetPastRides(city string, filter RideFilter) ([]ScrapedRide, error) {
 	storedRides, err := s.repo.GetPastRides(city)
 	if err != nil {
 		slog.Error("Failed to query past rides", "error", err)
 		return nil, err
 	}
 
+	location, err := cityLocation(city)
+	if err != nil {
+		return nil, err
+	}
+
 	var rides []ScrapedRide
 	for i := range storedRides {
-		rides = append(rides, storedRides[i].ToScrapedRide())
+		ride := storedRides[i].ToScrapedRide(location)
+		if filter.matches(ride) {
+			rides = append(rides, ride)
+		}
 	}
 
 	if rides == nil {
@@ -256,19 +462,23 @@ func (s *Service) GenerateICSFromRide(city, rideID string) (ICSContent, error) {
 		return ICSContent{}, fmt.Errorf("ride not found")
 	}
 
-	ride := storedRide[0].ToScrapedRide()
+	location, err := cityLocation(city)
+	if err != nil {
+		return ICSContent{}, err
+	}
+	ride := storedRide[0].ToScrapedRide(location)
 
-	startTimeStr := fmt.Sprintf("%s %s", ride.Date, ride.StartTime)
-	start, err := time.Parse("2006-01-02 15:04:05", startTimeStr)
+	// the stored times are local to the city, so resolve them there before
+	// converting to UTC
+	times, err := scraper.ResolveEventTimes(location, ride.Date, ride.StartTime, ride.EndTime, int(ride.EventDuration))
 	if err != nil {
 		return ICSContent{}, err
 	}
 
+	start := times.Start
 	end := start.Add(2 * time.Hour)
-	if ride.EndTime != "" {
-		if endTime, err := time.Parse("2006-01-02 15:04:05", fmt.Sprintf("%s %s", ride.Date, ride.EndTime)); err == nil {
-			end = endTime
-		}
+	if times.HasEnd() {
+		end = times.End
 	}
 
 	formatICS := func(t time.Time) string {
@@ -323,6 +533,11 @@ func (s *Service) PublishRide(rideID int, moderationNotes string) error {
 	return s.repo.PublishRide(rideID, moderationNotes)
 }
 
+// GetScrapeChanges returns what the scraper changed in a city since the given time
+func (s *Service) GetScrapeChanges(city string, since time.Time) ([]ScrapeChange, error) {
+	return s.repo.GetScrapeChanges(city, since)
+}
+
 // ValidateAdminKey checks if an API key is valid
 func (s *Service) ValidateAdminKey(apiKey string) (bool, error) {
 	return s.repo.ValidateAdminKey(apiKey)
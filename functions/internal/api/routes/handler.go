@@ -1,9 +1,17 @@
 package routes
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spacesedan/cyclescene/functions/internal/routes"
 )
 
 type Handler struct {
@@ -52,10 +60,129 @@ func (h *Handler) GetAllRoutes(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetRoute exports a route as GeoJSON, GPX 1.1, KML or a Google encoded
+// polyline. The format comes from a suffix on the id, the format parameter,
+// or the Accept header, in that order, and defaults to GeoJSON.
+// GET /v1/routes/{id}, /v1/routes/{id}.gpx, /v1/routes/{id}?format=kml
+func (h *Handler) GetRoute(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	format, ok := routes.FormatGeoJSON, true
+	if i := strings.LastIndex(id, "."); i > 0 {
+		format, ok = routes.ParseExportFormat(id[i+1:])
+		id = id[:i]
+	} else if name := r.URL.Query().Get("format"); name != "" {
+		format, ok = routes.ParseExportFormat(name)
+	} else {
+		format = negotiateFormat(r.Header.Get("Accept"))
+	}
+	if !ok {
+		writeError(w, http.StatusBadRequest, "format must be geojson, gpx, kml or polyline")
+		return
+	}
+
+	route, err := h.repo.GetRouteExport(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Route not found")
+			return
+		}
+		slog.Error("[Routes] Failed to fetch route", "error", err, "id", id)
+		writeError(w, http.StatusInternalServerError, "Failed to fetch route")
+		return
+	}
+
+	meta := exportMetadata(route)
+	filename := meta.Filename("route-"+route.ID, format)
+
+	var body bytes.Buffer
+	switch format {
+	case routes.FormatGPX:
+		err = routes.ExportGPX(&body, route.Feature, meta)
+	case routes.FormatKML:
+		err = routes.ExportKML(&body, route.Feature, meta)
+	case routes.FormatPolyline:
+		err = json.NewEncoder(&body).Encode(map[string]any{
+			"id":          route.ID,
+			"name":        meta.Name,
+			"polyline":    routes.EncodePolyline(route.Feature.Geometry.Coordinates),
+			"distance_km": route.Feature.Properties["distance_km"],
+			"distance_mi": route.Feature.Properties["distance_mi"],
+		})
+	default:
+		err = json.NewEncoder(&body).Encode(route.Feature)
+	}
+	if err != nil {
+		slog.Error("[Routes] Failed to export route", "error", err, "id", id, "format", format)
+		writeError(w, http.StatusInternalServerError, "Failed to export route")
+		return
+	}
+
+	disposition := "inline"
+	if format == routes.FormatGPX || format == routes.FormatKML {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filename))
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		slog.Error("[Routes] Failed to write route export", "error", err)
+	}
+}
+
+// negotiateFormat picks the first export format the Accept header lists.
+// Polylines have no media type, so they are only served by name.
+func negotiateFormat(accept string) routes.ExportFormat {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/gpx+xml":
+			return routes.FormatGPX
+		case "application/vnd.google-earth.kml+xml":
+			return routes.FormatKML
+		case "application/geo+json", "application/json":
+			return routes.FormatGeoJSON
+		}
+	}
+	return routes.FormatGeoJSON
+}
+
+// exportMetadata names a route after its own name, or the ride it belongs to
+func exportMetadata(route *RouteExport) routes.ExportMetadata {
+	meta := routes.ExportMetadata{URL: route.SourceURL}
+
+	if name, ok := route.Feature.Properties["name"].(string); ok && strings.TrimSpace(name) != "" {
+		meta.Name = strings.TrimSpace(name)
+	} else if route.RideTitle != "" {
+		meta.Name = route.RideTitle
+	} else {
+		meta.Name = "Route " + route.ID
+	}
+
+	if km, ok := route.Feature.Properties["distance_km"].(float64); ok && km > 0 {
+		mi, _ := route.Feature.Properties["distance_mi"].(float64)
+		meta.Description = fmt.Sprintf("%.1f km (%.1f mi), from %s", km, mi, route.Source)
+	}
+
+	if created, err := time.Parse("2006-01-02 15:04:05.000", route.CreatedAt); err == nil {
+		meta.Time = created
+	}
+
+	return meta
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
 // RegisterRoutes registers all route handlers
 func (h *Handler) RegisterRoutes(r interface {
 	Get(string, http.HandlerFunc)
 }) {
 	slog.Info("[Routes] Registering routes handler")
 	r.Get("/routes", h.GetAllRoutes)
+	r.Get("/routes/{id}", h.GetRoute)
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+
+	"github.com/spacesedan/cyclescene/functions/internal/routes"
 )
 
 type Repository struct {
@@ -65,3 +68,44 @@ func (r *Repository) GetAllRoutes(ctx context.Context, city string) ([]Route, er
 	slog.Info("[Routes Repo] Retrieved routes", "count", len(routes), "city", city)
 	return routes, nil
 }
+
+// RouteExport is a route with what its exports need to describe it
+type RouteExport struct {
+	ID        string
+	Source    string
+	SourceURL string
+	// RideTitle is the title of a ride the route is attached to, if any
+	RideTitle string
+	CreatedAt string
+	Feature   routes.GeoJSONFeature
+}
+
+// GetRouteExport retrieves a route by its ID, with the title of a ride using
+// it. Returns sql.ErrNoRows when there is no such route.
+func (r *Repository) GetRouteExport(ctx context.Context, id string) (*RouteExport, error) {
+	query := `
+		SELECT id, source, source_url, created_at, geojson,
+		       COALESCE(
+		         (SELECT e.title FROM events e WHERE e.route_id = routes.id AND e.is_published = 1 LIMIT 1),
+		         (SELECT s.title FROM shift2bikes_events s WHERE s.route_id = routes.id ORDER BY s.date DESC LIMIT 1),
+		         ''
+		       )
+		FROM routes
+		WHERE id = ?
+	`
+
+	var route RouteExport
+	var geoJSON string
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&route.ID, &route.Source, &route.SourceURL, &route.CreatedAt, &geoJSON, &route.RideTitle,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(geoJSON), &route.Feature); err != nil {
+		return nil, fmt.Errorf("failed to parse route %s: %w", id, err)
+	}
+
+	return &route, nil
+}
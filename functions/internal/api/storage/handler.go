@@ -32,6 +32,9 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 //	  "entity_type": "ride"
 //	}
 //
+//	Route files use "entity_type": "route" and a GPX, KML, TCX or FIT file;
+//	the returned image_uuid is then sent as route_upload_uuid with the ride.
+//
 //	Response: {
 //	  "success": true,
 //	  "signed_url": "https://storage.googleapis.com/...",
@@ -69,8 +72,21 @@ func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate file type (only allow image types)
-	if !isAllowedMimeType(req.FileType) {
+	// Validate file type (only allow route files for routes, images otherwise)
+	if req.EntityType == "route" {
+		if _, ok := routeFileExtension(req.FileType, req.FileName); !ok {
+			slog.Warn("invalid route file type", "file_type", req.FileType, "file_name", req.FileName)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode(SignedURLResponse{
+				Success: false,
+				Error:   "only route files (GPX, KML, TCX, FIT) are allowed",
+			}); err != nil {
+				slog.Error("failed to encode error response", "error", err)
+			}
+			return
+		}
+	} else if !isAllowedMimeType(req.FileType) {
 		slog.Warn("invalid file type", "file_type", req.FileType)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
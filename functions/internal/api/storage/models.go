@@ -7,7 +7,7 @@ type SignedURLRequest struct {
 	FileName   string `json:"file_name"`
 	FileType   string `json:"file_type"`   // MIME type, e.g., "image/jpeg"
 	CityCode   string `json:"city_code"`   // City code (e.g., "pdx", "slc")
-	EntityType string `json:"entity_type"` // "ride" or "group"
+	EntityType string `json:"entity_type"` // "ride", "group" or "route"
 }
 
 // SignedURLResponse represents the response containing a signed URL for upload
@@ -15,7 +15,7 @@ type SignedURLResponse struct {
 	Success    bool      `json:"success"`
 	SignedURL  string    `json:"signed_url"`
 	ObjectName string    `json:"object_name"` // Path in bucket (without gs://)
-	ImageUUID  string    `json:"image_uuid"`  // UUID of the uploaded image
+	ImageUUID  string    `json:"image_uuid"`  // UUID of the uploaded image or route file
 	ExpiresAt  time.Time `json:"expires_at"`
 	BucketName string    `json:"bucket_name"`
 	Error      string    `json:"error,omitempty"`
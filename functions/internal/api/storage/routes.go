@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+)
+
+// MaxRouteFileBytes caps the size of an uploaded route file
+const MaxRouteFileBytes = 20 << 20
+
+var (
+	// ErrRouteFileNotFound is returned when nothing was uploaded under a route
+	// upload UUID
+	ErrRouteFileNotFound = errors.New("route file not found")
+	// ErrRouteFileTooLarge is returned for route files over MaxRouteFileBytes
+	ErrRouteFileTooLarge = errors.New("route file is too large")
+)
+
+// routeFileExtensions are the route file formats organizers can upload
+var routeFileExtensions = []string{".gpx", ".kml", ".tcx", ".fit"}
+
+// routeObjectName is where a route file is uploaded in the staging bucket,
+// apart from the images the optimizer picks up
+func routeObjectName(uploadUUID, ext string) string {
+	return fmt.Sprintf("routes/%s%s", uploadUUID, ext)
+}
+
+// routeFileExtension returns the extension of an uploaded route file from its
+// MIME type, or from its name when the browser only knows a generic type
+func routeFileExtension(mimeType, fileName string) (string, bool) {
+	switch mimeType {
+	case "application/gpx+xml":
+		return ".gpx", true
+	case "application/vnd.google-earth.kml+xml":
+		return ".kml", true
+	case "application/vnd.garmin.tcx+xml":
+		return ".tcx", true
+	case "application/vnd.ant.fit":
+		return ".fit", true
+	case "application/octet-stream", "application/xml", "text/xml":
+		ext := strings.ToLower(path.Ext(fileName))
+		for _, allowed := range routeFileExtensions {
+			if ext == allowed {
+				return ext, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ReadRouteFile reads back a route file uploaded through a signed URL for the
+// "route" entity type
+func (s *Service) ReadRouteFile(ctx context.Context, uploadUUID string) ([]byte, error) {
+	parsed, err := uuid.Parse(uploadUUID)
+	if err != nil {
+		return nil, ErrRouteFileNotFound
+	}
+
+	bucket := s.client.Bucket(s.bucketName)
+	for _, ext := range routeFileExtensions {
+		object := bucket.Object(routeObjectName(parsed.String(), ext))
+
+		attrs, err := object.Attrs(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up route file: %v", err)
+		}
+		if attrs.Size > MaxRouteFileBytes {
+			return nil, ErrRouteFileTooLarge
+		}
+
+		reader, err := object.NewReader(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open route file: %v", err)
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(io.LimitReader(reader, MaxRouteFileBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read route file: %v", err)
+		}
+		if len(data) > MaxRouteFileBytes {
+			return nil, ErrRouteFileTooLarge
+		}
+		return data, nil
+	}
+
+	return nil, ErrRouteFileNotFound
+}
@@ -78,6 +78,15 @@ func (s *Service) GenerateSignedURL(ctx context.Context, req *SignedURLRequest)
 	// Create object name: {uuid}.{ext}
 	objectName := fmt.Sprintf("%s%s", imageUUID, ext)
 
+	// Route files go under routes/, keeping the extension of their format
+	if req.EntityType == "route" {
+		routeExt, ok := routeFileExtension(req.FileType, req.FileName)
+		if !ok {
+			return nil, fmt.Errorf("unsupported route file type %s", req.FileType)
+		}
+		objectName = routeObjectName(imageUUID, routeExt)
+	}
+
 	slog.Info("generating signed URL", "object", objectName, "bucket", s.bucketName, "duration", s.signedURLDuration, "imageUUID", imageUUID, "cityCode", req.CityCode, "entityType", req.EntityType)
 
 	// Generate signed URL with metadata
@@ -0,0 +1,262 @@
+// Package dedup finds rides that were posted both to a scraped source, such as
+// Shift2Bikes, and submitted directly, and records them as candidate pairs in
+// the ride_duplicates table for an admin to confirm or reject.
+package dedup
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusRejected  = "rejected"
+
+	// MinScore is the lowest score a pair is recorded with
+	MinScore = 0.6
+
+	titleWeight    = 0.5
+	timeWeight     = 0.25
+	distanceWeight = 0.25
+
+	// start times this far apart, or further, score 0
+	maxTimeGap = 90 * time.Minute
+	// start points this far apart, or further, score 0
+	maxDistanceMeters = 1500.0
+	// the distance score used when either ride has no coordinates
+	unknownDistanceScore = 0.5
+)
+
+// Ride is the part of a scraped or submitted ride that pairs are scored on
+type Ride struct {
+	ID        string
+	Title     string
+	Date      string
+	StartTime string
+	Lat       float64
+	Lng       float64
+}
+
+// Score is how alike two rides are. Each part is between 0 and 1, and Total
+// is their weighted sum.
+type Score struct {
+	Title     float64
+	Time      float64
+	Distance  float64
+	DistanceM *float64
+	Total     float64
+}
+
+// Compare scores a pair of rides held on the same date
+func Compare(a, b Ride) Score {
+	var score Score
+	score.Title = TitleSimilarity(a.Title, b.Title)
+	score.Time = timeScore(a.StartTime, b.StartTime)
+
+	score.Distance = unknownDistanceScore
+	if hasCoordinates(a) && hasCoordinates(b) {
+		meters := haversineMeters(a.Lat, a.Lng, b.Lat, b.Lng)
+		score.DistanceM = &meters
+		score.Distance = math.Max(0, 1-meters/maxDistanceMeters)
+	}
+
+	score.Total = titleWeight*score.Title + timeWeight*score.Time + distanceWeight*score.Distance
+	return score
+}
+
+// TitleSimilarity is the Sørensen–Dice coefficient of the character bigrams of
+// the two titles, ignoring case, punctuation and spacing
+func TitleSimilarity(a, b string) float64 {
+	aBigrams, bBigrams := bigrams(normalizeTitle(a)), bigrams(normalizeTitle(b))
+	total := len(aBigrams) + len(bBigrams)
+	if total == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int, len(aBigrams))
+	for _, bigram := range aBigrams {
+		counts[bigram]++
+	}
+	shared := 0
+	for _, bigram := range bBigrams {
+		if counts[bigram] > 0 {
+			counts[bigram]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func bigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 2 {
+		return nil
+	}
+	out := make([]string, 0, len(runes)-1)
+	for i := 0; i < len(runes)-1; i++ {
+		out = append(out, string(runes[i:i+2]))
+	}
+	return out
+}
+
+// timeScore falls linearly from 1 for the same start time to 0 at maxTimeGap.
+// A ride without a parseable start time scores 0.
+func timeScore(a, b string) float64 {
+	aTime, aOK := scraper.ParseWallClock(a)
+	bTime, bOK := scraper.ParseWallClock(b)
+	if !aOK || !bOK {
+		return 0
+	}
+
+	gap := aTime - bTime
+	if gap < 0 {
+		gap = -gap
+	}
+	return math.Max(0, 1-float64(gap)/float64(maxTimeGap))
+}
+
+func hasCoordinates(r Ride) bool {
+	return r.Lat != 0 || r.Lng != 0
+}
+
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusMeters = 6371000.0
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// Detect scores every scraped ride against every published, submitted ride in
+// the city held on the same day, from since onwards, and records the pairs that
+// score at least MinScore. Pairs an admin has already reviewed keep their
+// status. It returns the number of pairs scored at or above MinScore.
+func Detect(db *sql.DB, city, since string) (int, error) {
+	scraped, err := loadRides(db, `
+		SELECT composite_event_id, title, date, starttime, lat, lng
+		FROM shift2bikes_events
+		WHERE citycode = ? AND date >= ? AND removed_at IS NULL
+	`, city, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load scraped rides: %w", err)
+	}
+
+	submitted, err := loadRides(db, `
+		SELECT DISTINCT CAST(e.id AS TEXT), e.title, eo.start_date, eo.start_time,
+		       COALESCE(e.latitude, 0), COALESCE(e.longitude, 0)
+		FROM events e
+		JOIN event_occurrences eo ON e.id = eo.event_id
+		WHERE e.city = ? AND e.is_published = 1 AND eo.start_date >= ?
+	`, city, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load submitted rides: %w", err)
+	}
+
+	byDate := make(map[string][]Ride)
+	for _, ride := range submitted {
+		byDate[ride.Date] = append(byDate[ride.Date], ride)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// pending pairs are re-detected from scratch, so ones that no longer
+	// score high enough drop out
+	if _, err := tx.Exec(`
+		DELETE FROM ride_duplicates WHERE city = ? AND date >= ? AND status = ?
+	`, city, since, StatusPending); err != nil {
+		return 0, fmt.Errorf("failed to clear pending duplicates: %w", err)
+	}
+
+	// a submitted ride held twice on the same day keeps its best score
+	stmt, err := tx.Prepare(`
+		INSERT INTO ride_duplicates (
+			composite_event_id, event_id, city, date, score, title_score, time_score,
+			distance_score, distance_m, status, detected_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(composite_event_id, event_id) DO UPDATE SET
+			date = excluded.date,
+			score = excluded.score,
+			title_score = excluded.title_score,
+			time_score = excluded.time_score,
+			distance_score = excluded.distance_score,
+			distance_m = excluded.distance_m,
+			detected_at = excluded.detected_at
+		WHERE excluded.detected_at > ride_duplicates.detected_at OR excluded.score > ride_duplicates.score
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare duplicate upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	detectedAt := time.Now().UTC().Format(time.RFC3339)
+	recorded := 0
+	for _, s := range scraped {
+		for _, candidate := range byDate[s.Date] {
+			score := Compare(s, candidate)
+			if score.Total < MinScore {
+				continue
+			}
+
+			var distance any
+			if score.DistanceM != nil {
+				distance = *score.DistanceM
+			}
+			if _, err := stmt.Exec(
+				s.ID, candidate.ID, city, s.Date, score.Total, score.Title, score.Time,
+				score.Distance, distance, StatusPending, detectedAt,
+			); err != nil {
+				return 0, fmt.Errorf("failed to record duplicate %s / %s: %w", s.ID, candidate.ID, err)
+			}
+			recorded++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return recorded, nil
+}
+
+func loadRides(db *sql.DB, query string, args ...any) ([]Ride, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rides []Ride
+	for rows.Next() {
+		var ride Ride
+		if err := rows.Scan(&ride.ID, &ride.Title, &ride.Date, &ride.StartTime, &ride.Lat, &ride.Lng); err != nil {
+			return nil, err
+		}
+		rides = append(rides, ride)
+	}
+	return rides, rows.Err()
+}
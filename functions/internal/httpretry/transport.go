@@ -0,0 +1,223 @@
+// Package httpretry provides an http.RoundTripper that rate limits requests
+// per host and retries throttled or failed requests with exponential backoff.
+package httpretry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultMaxRetries     = 4
+	defaultBaseDelay      = 500 * time.Millisecond
+	defaultMaxDelay       = 10 * time.Second
+	defaultAttemptTimeout = 30 * time.Second
+)
+
+// Limit allows one request every Interval, with bursts of up to Burst requests
+type Limit struct {
+	Interval time.Duration
+	Burst    int
+}
+
+func (l Limit) limiter() *rate.Limiter {
+	burst := max(l.Burst, 1)
+	if l.Interval <= 0 {
+		return rate.NewLimiter(rate.Inf, burst)
+	}
+	return rate.NewLimiter(rate.Every(l.Interval), burst)
+}
+
+// Transport wraps another RoundTripper. Each host gets its own limiter, using
+// the limit set for it (or a parent domain) with SetLimit, or the default.
+// Responses with status 429 or 5xx, and network errors, are retried with
+// exponential backoff, honouring Retry-After.
+//
+// Each attempt has its own timeout, which starts once the limiter lets it
+// through and runs until its response body is closed. Time spent waiting for
+// a turn or backing off doesn't count against it, so clients using a Transport
+// should leave http.Client.Timeout unset and bound the whole request with its
+// context instead.
+type Transport struct {
+	base           http.RoundTripper
+	defaultLimit   Limit
+	maxRetries     int
+	baseDelay      time.Duration
+	maxDelay       time.Duration
+	attemptTimeout time.Duration
+
+	mu       sync.Mutex
+	limits   map[string]Limit
+	limiters map[string]*rate.Limiter
+}
+
+// NewTransport returns a Transport around base, or http.DefaultTransport if
+// base is nil
+func NewTransport(base http.RoundTripper, defaultLimit Limit) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:           base,
+		defaultLimit:   defaultLimit,
+		maxRetries:     defaultMaxRetries,
+		baseDelay:      defaultBaseDelay,
+		maxDelay:       defaultMaxDelay,
+		attemptTimeout: defaultAttemptTimeout,
+		limits:         make(map[string]Limit),
+		limiters:       make(map[string]*rate.Limiter),
+	}
+}
+
+// SetLimit sets the limit shared by a host and all of its subdomains
+func (t *Transport) SetLimit(host string, limit Limit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	host = strings.ToLower(host)
+	t.limits[host] = limit
+	delete(t.limiters, host)
+}
+
+// limiter returns the limiter for the most specific configured domain that
+// host belongs to, or a per-host limiter with the default limit
+func (t *Transport) limiter(host string) *rate.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	host = strings.ToLower(host)
+	key, limit := host, t.defaultLimit
+	for domain := host; domain != ""; {
+		if configured, ok := t.limits[domain]; ok {
+			key, limit = domain, configured
+			break
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+
+	limiter, ok := t.limiters[key]
+	if !ok {
+		limiter = limit.limiter()
+		t.limiters[key] = limiter
+	}
+	return limiter
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	limiter := t.limiter(req.URL.Hostname())
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, t.attemptTimeout)
+		attemptReq := req.WithContext(attemptCtx)
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			attemptReq = req.Clone(attemptCtx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.maxRetries || !retryable(req, resp, err) || ctx.Err() != nil {
+			if resp == nil {
+				cancel()
+				return resp, err
+			}
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			// drain so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		cancel()
+		slog.Warn("retrying request", "host", req.URL.Host, "attempt", attempt+1, "status", status, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// cancelOnClose ends an attempt's timeout once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable reports whether a request should be sent again. Requests with a
+// body that can't be replayed are never retried.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff doubles the delay on every attempt, with jitter so concurrent
+// workers don't retry in lockstep. A longer Retry-After wins.
+func (t *Transport) backoff(attempt int, resp *http.Response) time.Duration {
+	delay := min(t.baseDelay<<attempt, t.maxDelay)
+	delay = delay/2 + rand.N(delay/2+1)
+
+	if resp != nil {
+		if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > delay {
+			delay = min(retryAfter, t.maxDelay)
+		}
+	}
+	return delay
+}
+
+// parseRetryAfter accepts both forms of the header: a number of seconds or an
+// HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
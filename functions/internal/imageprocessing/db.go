@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 )
 
 // DBConnector handles database connections
@@ -117,6 +118,61 @@ func (d *DBConnector) SetGroupMarkerAndPublicID(groupCode, publicID, markerKey s
 	return nil
 }
 
+// maxMirrorAttempts is how many times a failing image is tried before it is
+// left for an admin to look at
+const maxMirrorAttempts = 3
+
+// PendingImageMirrors returns up to limit queued external images for a city,
+// oldest first, including failed ones with attempts left
+func (d *DBConnector) PendingImageMirrors(cityCode string, limit int) ([]string, error) {
+	rows, err := d.db.Query(`
+		SELECT source_url FROM image_mirrors
+		WHERE citycode = ? AND (status = 'pending' OR (status = 'failed' AND attempts < ?))
+		ORDER BY requested_at ASC
+		LIMIT ?
+	`, cityCode, maxMirrorAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending image mirrors: %v", err)
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var sourceURL string
+		if err := rows.Scan(&sourceURL); err != nil {
+			return nil, fmt.Errorf("failed to scan pending image mirror: %v", err)
+		}
+		urls = append(urls, sourceURL)
+	}
+	return urls, rows.Err()
+}
+
+// CompleteImageMirror records the optimized URL of a mirrored image
+func (d *DBConnector) CompleteImageMirror(sourceURL, optimizedURL string) error {
+	_, err := d.db.Exec(`
+		UPDATE image_mirrors
+		SET status = 'done', optimized_url = ?, attempts = attempts + 1, last_error = NULL, updated_at = ?
+		WHERE source_url = ?
+	`, optimizedURL, time.Now().UTC().Format(time.RFC3339), sourceURL)
+	if err != nil {
+		return fmt.Errorf("failed to complete image mirror: %v", err)
+	}
+	return nil
+}
+
+// FailImageMirror records why an image couldn't be mirrored
+func (d *DBConnector) FailImageMirror(sourceURL string, mirrorErr error) error {
+	_, err := d.db.Exec(`
+		UPDATE image_mirrors
+		SET status = 'failed', attempts = attempts + 1, last_error = ?, updated_at = ?
+		WHERE source_url = ?
+	`, mirrorErr.Error(), time.Now().UTC().Format(time.RFC3339), sourceURL)
+	if err != nil {
+		return fmt.Errorf("failed to record image mirror failure: %v", err)
+	}
+	return nil
+}
+
 // Close closes the database connection
 func (d *DBConnector) Close() error {
 	if d.db != nil {
@@ -0,0 +1,95 @@
+package imageprocessing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/netguard"
+)
+
+// maxMirrorBytes caps the size of an image fetched for mirroring
+const maxMirrorBytes = 25 << 20
+
+// newMirrorClient returns the client used to fetch images hosted elsewhere.
+// Image URLs come from scraped rides, so connections to internal addresses
+// are refused.
+func newMirrorClient() *http.Client {
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: netguard.NewTransport(),
+	}
+}
+
+// MirrorKey is the object id of an external image's optimized variants,
+// derived from its URL so the same image always lands in the same place
+func MirrorKey(sourceURL string) string {
+	sum := sha256.Sum256([]byte(sourceURL))
+	return hex.EncodeToString(sum[:])[:20]
+}
+
+// MirrorImage fetches an image hosted elsewhere, such as a Shift2Bikes ride
+// photo, and writes the same WebP variants submitted ride images get under
+// {cityCode}/mirrors/{key}/. It returns the public URL of the _optimized.webp
+// variant.
+func (p *ImageProcessor) MirrorImage(ctx context.Context, sourceURL, cityCode string) (string, error) {
+	parsed, err := url.Parse(sourceURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid image URL %q", sourceURL)
+	}
+
+	slog.Info("fetching external image", "sourceURL", sourceURL, "cityCode", cityCode)
+	imageData, err := p.fetchImage(ctx, sourceURL)
+	if err != nil {
+		return "", err
+	}
+
+	fullImg, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	publicURL, err := p.uploadVariants(ctx, fullImg, cityCode, "mirrors", MirrorKey(sourceURL))
+	if err != nil {
+		return "", err
+	}
+
+	slog.Info("image mirror complete", "sourceURL", sourceURL, "publicURL", publicURL)
+	return publicURL, nil
+}
+
+// fetchImage downloads an image with the processor's HTTP client
+func (p *ImageProcessor) fetchImage(ctx context.Context, sourceURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image request: %v", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image host returned status %d", resp.StatusCode)
+	}
+
+	imageData, err := io.ReadAll(io.LimitReader(resp.Body, maxMirrorBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+	if len(imageData) > maxMirrorBytes {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxMirrorBytes)
+	}
+
+	return imageData, nil
+}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"log/slog"
 	"math"
+	"net/http"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -169,6 +170,7 @@ type ImageProcessor struct {
 	stagingBucket   string
 	optimizedBucket string
 	storageClient   *storage.Client
+	httpClient      *http.Client
 	db              *sql.DB
 }
 
@@ -182,6 +184,7 @@ func NewImageProcessor(ctx context.Context, stagingBucket, optimizedBucket strin
 		stagingBucket:   stagingBucket,
 		optimizedBucket: optimizedBucket,
 		storageClient:   client,
+		httpClient:      newMirrorClient(),
 	}, nil
 }
 
@@ -247,9 +250,29 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageUUID, cityCode,
 		return "", fmt.Errorf("failed to decode full image: %v", err)
 	}
 
+	mainPublicURL, err := p.uploadVariants(ctx, fullImg, cityCode, entityType+"s", entityID)
+	if err != nil {
+		return "", err
+	}
+
+	// Delete staging file
+	slog.Info("deleting staging file", "bucket", p.stagingBucket, "object", stagingObjectName)
+	if err := p.deleteFromGCS(ctx, p.stagingBucket, stagingObjectName); err != nil {
+		slog.Warn("failed to delete staging file, continuing anyway", "error", err)
+		// Don't fail the operation if deletion fails
+	}
+
+	// Return the public URL for the optimized image
+	slog.Info("image optimization complete", "publicURL", mainPublicURL)
+	return mainPublicURL, nil
+}
+
+// uploadVariants writes the 400, 800 and 1200 pixel wide WebP variants of an
+// image under {cityCode}/{entityTypePlural}/{entityID}/, and returns the
+// public URL of the _optimized.webp copy of the largest
+func (p *ImageProcessor) uploadVariants(ctx context.Context, fullImg image.Image, cityCode, entityTypePlural, entityID string) (string, error) {
 	// Define sizes to generate
 	sizes := []int{400, 800, 1200}
-	entityTypePlural := entityType + "s"
 	var mainPublicURL string
 
 	for _, width := range sizes {
@@ -280,15 +303,6 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageUUID, cityCode,
 		}
 	}
 
-	// Delete staging file
-	slog.Info("deleting staging file", "bucket", p.stagingBucket, "object", stagingObjectName)
-	if err := p.deleteFromGCS(ctx, p.stagingBucket, stagingObjectName); err != nil {
-		slog.Warn("failed to delete staging file, continuing anyway", "error", err)
-		// Don't fail the operation if deletion fails
-	}
-
-	// Return the public URL for the optimized image
-	slog.Info("image optimization complete", "publicURL", mainPublicURL)
 	return mainPublicURL, nil
 }
 
@@ -0,0 +1,151 @@
+// Package jobruns records each run of a scheduled job in the job_runs table,
+// so failures show up in the admin dashboard rather than only in Cloud Run logs.
+package jobruns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	JobScraper      = "scraper"
+	JobBackfill     = "scraper-backfill"
+	JobTokenCleaner = "token-cleaner"
+	JobDBBackups    = "db-backups"
+
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	// maxErrors caps how many errors a single run keeps
+	maxErrors = 50
+)
+
+// Counts are the totals a job reports when it finishes. Jobs other than the
+// scraper leave the ones that don't apply at zero.
+type Counts struct {
+	EventsSeen     int
+	EventsUpserted int
+	EventsGeocoded int
+	Fallbacks      int
+	RoutesCreated  int
+}
+
+// Run is a job run in progress. Recording is best effort: if the job_runs
+// table can't be written the job carries on and only logs the problem. A nil
+// *Run is valid and records nothing.
+type Run struct {
+	db        *sql.DB
+	id        string
+	job       string
+	startedAt time.Time
+
+	mu     sync.Mutex
+	counts Counts
+	detail string
+	errors []string
+}
+
+// Start inserts a running row for the job and returns the run
+func Start(db *sql.DB, id, job, city string) *Run {
+	run := &Run{db: db, id: id, job: job, startedAt: time.Now().UTC()}
+
+	_, err := db.Exec(`
+		INSERT INTO job_runs (id, job, city, status, started_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, id, job, nilIfEmpty(city), StatusRunning, run.startedAt.Format(time.RFC3339))
+	if err != nil {
+		slog.Error("failed to record job run start", "job", job, "run_id", id, "error", err)
+	}
+
+	return run
+}
+
+// SetCounts replaces the run's counts
+func (r *Run) SetCounts(counts Counts) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts = counts
+}
+
+// SetDetail records a short summary of what the run did, such as the name of
+// the backup it wrote
+func (r *Run) SetDetail(detail string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detail = detail
+}
+
+// AddError records a problem that didn't stop the run
+func (r *Run) AddError(err error) {
+	if r == nil || err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errors) < maxErrors {
+		r.errors = append(r.errors, err.Error())
+	}
+}
+
+// Finish marks the run succeeded, or failed if err is not nil
+func (r *Run) Finish(err error) {
+	if r == nil {
+		return
+	}
+	status := StatusSucceeded
+	if err != nil {
+		status = StatusFailed
+		r.AddError(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errorsJSON any
+	if len(r.errors) > 0 {
+		encoded, _ := json.Marshal(r.errors)
+		errorsJSON = string(encoded)
+	}
+
+	finishedAt := time.Now().UTC()
+	_, dbErr := r.db.Exec(`
+		UPDATE job_runs SET
+			status = ?, finished_at = ?, duration_ms = ?,
+			events_seen = ?, events_upserted = ?, events_geocoded = ?, fallbacks = ?, routes_created = ?,
+			error_count = ?, errors = ?, detail = ?
+		WHERE id = ?
+	`,
+		status, finishedAt.Format(time.RFC3339), finishedAt.Sub(r.startedAt).Milliseconds(),
+		r.counts.EventsSeen, r.counts.EventsUpserted, r.counts.EventsGeocoded, r.counts.Fallbacks, r.counts.RoutesCreated,
+		len(r.errors), errorsJSON, nilIfEmpty(r.detail), r.id,
+	)
+	if dbErr != nil {
+		slog.Error("failed to record job run result", "job", r.job, "run_id", r.id, "status", status, "error", dbErr)
+	}
+}
+
+// Fatalf marks the run failed and exits like log.Fatalf
+func (r *Run) Fatalf(format string, args ...any) {
+	err := fmt.Errorf(format, args...)
+	r.Finish(err)
+	log.Fatal(err)
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+// Package netguard keeps requests to URLs that come from outside, such as
+// scraped ride images and group calendar feeds, from reaching internal
+// services.
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// NewTransport returns a transport that checks every connection, redirects
+// included, after DNS resolution and refuses it if it would reach a loopback,
+// private, link-local, multicast or unspecified address. It ignores proxy
+// settings, since the check only sees the address it dials.
+func NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: RejectInternalAddress,
+	}
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// RejectInternalAddress is a net.Dialer Control hook that refuses connections
+// to addresses outside the public internet
+func RejectInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid host address %q: %v", address, err)
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("invalid host address %q: %v", address, err)
+	}
+
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("host address %s is not public", ip)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -21,6 +22,90 @@ type GeoJSONGeometry struct {
 	Coordinates [][]float64 `json:"coordinates"`
 }
 
+// FileFormat is a route file format the converter reads
+type FileFormat string
+
+const (
+	FileGPX FileFormat = "gpx"
+	FileKML FileFormat = "kml"
+	FileTCX FileFormat = "tcx"
+	FileFIT FileFormat = "fit"
+)
+
+// DetectFormat sniffs the format of a route file from its contents rather
+// than its name: FIT files by their header, and XML formats by their root
+// element
+func DetectFormat(data []byte) (FileFormat, error) {
+	if isFIT(data) {
+		return FileFIT, nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(trimBOM(data)))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("unrecognized route file")
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "gpx":
+				return FileGPX, nil
+			case "kml":
+				return FileKML, nil
+			case "TrainingCenterDatabase":
+				return FileTCX, nil
+			}
+			return "", fmt.Errorf("unsupported route file with root element <%s>", start.Name.Local)
+		}
+	}
+}
+
+// ConvertToGeoJSON converts a route file in any format DetectFormat
+// recognizes, and reports which format it was
+func ConvertToGeoJSON(data []byte) (GeoJSONFeature, FileFormat, error) {
+	format, err := DetectFormat(data)
+	if err != nil {
+		return GeoJSONFeature{}, "", err
+	}
+
+	var feature GeoJSONFeature
+	switch format {
+	case FileFIT:
+		feature, err = ConvertFITtoGeoJSON(bytes.NewReader(data))
+	case FileKML:
+		feature, err = ConvertKMLtoGeoJSON(bytes.NewReader(trimBOM(data)))
+	case FileTCX:
+		feature, err = ConvertTCXtoGeoJSON(bytes.NewReader(trimBOM(data)))
+	default:
+		feature, err = ConvertGPXtoGeoJSON(bytes.NewReader(trimBOM(data)))
+	}
+	return feature, format, err
+}
+
+// ValidateFeature checks a converted route is a line a map can draw: at
+// least two points, all of them on the globe, covering some distance
+func ValidateFeature(feature GeoJSONFeature) error {
+	coords := feature.Geometry.Coordinates
+	if len(coords) < 2 {
+		return fmt.Errorf("route needs at least two points, found %d", len(coords))
+	}
+	for i, coord := range coords {
+		if len(coord) < 2 || coord[0] < -180 || coord[0] > 180 || coord[1] < -90 || coord[1] > 90 {
+			return fmt.Errorf("route point %d is not a valid coordinate", i)
+		}
+	}
+	if calculateDistance(coords) == 0 {
+		return fmt.Errorf("route does not cover any distance")
+	}
+	return nil
+}
+
+// trimBOM drops the byte order mark some tools write before XML, which the
+// XML decoder rejects
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+}
+
 // GPXTrack represents the structure of a GPX file (simplified)
 type GPXTrack struct {
 	XMLName xml.Name `xml:"gpx"`
@@ -0,0 +1,316 @@
+package routes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a file format a route can be exported as
+type ExportFormat string
+
+const (
+	FormatGeoJSON  ExportFormat = "geojson"
+	FormatGPX      ExportFormat = "gpx"
+	FormatKML      ExportFormat = "kml"
+	FormatPolyline ExportFormat = "polyline"
+)
+
+// ParseExportFormat reads a format name or file extension such as "gpx" or
+// ".kml"
+func ParseExportFormat(name string) (ExportFormat, bool) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), ".")) {
+	case "geojson", "json":
+		return FormatGeoJSON, true
+	case "gpx":
+		return FormatGPX, true
+	case "kml":
+		return FormatKML, true
+	case "polyline":
+		return FormatPolyline, true
+	}
+	return "", false
+}
+
+// ContentType is the media type an export is served as
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case FormatGPX:
+		return "application/gpx+xml"
+	case FormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	case FormatPolyline:
+		return "application/json"
+	default:
+		return "application/geo+json"
+	}
+}
+
+// Extension is the file extension of an export, without the dot
+func (f ExportFormat) Extension() string {
+	if f == FormatPolyline {
+		return "json"
+	}
+	return string(f)
+}
+
+// ExportMetadata describes a route in the files it is exported to
+type ExportMetadata struct {
+	Name        string
+	Description string
+	// URL links back to the route's page or source
+	URL  string
+	Time time.Time
+}
+
+// Filename is a file name for the route in format, derived from its name
+func (m ExportMetadata) Filename(fallback string, format ExportFormat) string {
+	name := filenameUnsafe.ReplaceAllString(strings.ToLower(m.Name), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 60 {
+		name = strings.TrimRight(name[:60], "-")
+	}
+	if name == "" {
+		name = fallback
+	}
+	return name + "." + format.Extension()
+}
+
+var filenameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+type gpxFile struct {
+	XMLName        xml.Name     `xml:"gpx"`
+	Version        string       `xml:"version,attr"`
+	Creator        string       `xml:"creator,attr"`
+	Xmlns          string       `xml:"xmlns,attr"`
+	XmlnsXsi       string       `xml:"xmlns:xsi,attr"`
+	SchemaLocation string       `xml:"xsi:schemaLocation,attr"`
+	Metadata       gpxMetadata  `xml:"metadata"`
+	Track          gpxExportTrk `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name        string   `xml:"name,omitempty"`
+	Description string   `xml:"desc,omitempty"`
+	Link        *gpxLink `xml:"link,omitempty"`
+	Time        string   `xml:"time,omitempty"`
+}
+
+type gpxLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type gpxExportTrk struct {
+	Name    string          `xml:"name,omitempty"`
+	Type    string          `xml:"type"`
+	Segment gpxExportTrkSeg `xml:"trkseg"`
+}
+
+type gpxExportTrkSeg struct {
+	Points []gpxExportPoint `xml:"trkpt"`
+}
+
+type gpxExportPoint struct {
+	Lat float64  `xml:"lat,attr"`
+	Lon float64  `xml:"lon,attr"`
+	Ele *float64 `xml:"ele,omitempty"`
+}
+
+// ExportGPX writes a route as a GPX 1.1 track, which Garmin and Wahoo head
+// units import as a course. Elevation is written when the route has any.
+func ExportGPX(w io.Writer, feature GeoJSONFeature, meta ExportMetadata) error {
+	coords := feature.Geometry.Coordinates
+	if len(coords) == 0 {
+		return fmt.Errorf("route has no coordinates")
+	}
+
+	file := gpxFile{
+		Version:        "1.1",
+		Creator:        "Cycle Scene",
+		Xmlns:          "http://www.topografix.com/GPX/1/1",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd",
+		Metadata: gpxMetadata{
+			Name:        meta.Name,
+			Description: meta.Description,
+		},
+		Track: gpxExportTrk{Name: meta.Name, Type: "cycling"},
+	}
+	if meta.URL != "" {
+		file.Metadata.Link = &gpxLink{Href: meta.URL}
+	}
+	if !meta.Time.IsZero() {
+		file.Metadata.Time = meta.Time.UTC().Format(time.RFC3339)
+	}
+
+	withElevation := hasElevation(coords)
+	file.Track.Segment.Points = make([]gpxExportPoint, 0, len(coords))
+	for _, coord := range coords {
+		if len(coord) < 2 {
+			continue
+		}
+		point := gpxExportPoint{Lat: coord[1], Lon: coord[0]}
+		if withElevation && len(coord) > 2 {
+			ele := coord[2]
+			point.Ele = &ele
+		}
+		file.Track.Segment.Points = append(file.Track.Segment.Points, point)
+	}
+
+	return writeXML(w, file)
+}
+
+type kmlFile struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name        string       `xml:"name,omitempty"`
+	Description string       `xml:"description,omitempty"`
+	Placemark   kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string        `xml:"name,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	TimeStamp   *kmlTimeStamp `xml:"TimeStamp,omitempty"`
+	LineString  kmlLineString `xml:"LineString"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlLineString struct {
+	Tessellate   int    `xml:"tessellate"`
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+// ExportKML writes a route as a KML 2.2 line. Elevation is written when the
+// route has any, and is relative to sea level; otherwise the line follows the
+// ground.
+func ExportKML(w io.Writer, feature GeoJSONFeature, meta ExportMetadata) error {
+	coords := feature.Geometry.Coordinates
+	if len(coords) == 0 {
+		return fmt.Errorf("route has no coordinates")
+	}
+
+	description := meta.Description
+	if meta.URL != "" {
+		description = strings.TrimSpace(description + "\n" + meta.URL)
+	}
+
+	withElevation := hasElevation(coords)
+	altitudeMode := "clampToGround"
+	if withElevation {
+		altitudeMode = "absolute"
+	}
+
+	var b strings.Builder
+	for _, coord := range coords {
+		if len(coord) < 2 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(formatCoord(coord[0]))
+		b.WriteByte(',')
+		b.WriteString(formatCoord(coord[1]))
+		if withElevation && len(coord) > 2 {
+			b.WriteByte(',')
+			b.WriteString(formatCoord(coord[2]))
+		}
+	}
+
+	placemark := kmlPlacemark{
+		Name:        meta.Name,
+		Description: description,
+		LineString: kmlLineString{
+			Tessellate:   1,
+			AltitudeMode: altitudeMode,
+			Coordinates:  b.String(),
+		},
+	}
+	if !meta.Time.IsZero() {
+		placemark.TimeStamp = &kmlTimeStamp{When: meta.Time.UTC().Format(time.RFC3339)}
+	}
+
+	return writeXML(w, kmlFile{
+		Xmlns: "http://www.opengis.net/kml/2.2",
+		Document: kmlDocument{
+			Name:        meta.Name,
+			Description: description,
+			Placemark:   placemark,
+		},
+	})
+}
+
+// EncodePolyline encodes [lon, lat] coordinates with the Google polyline
+// algorithm at 5 decimal places, the inverse of decodePolyline. Polylines
+// have no elevation.
+// https://developers.google.com/maps/documentation/utilities/polylinealgorithm
+func EncodePolyline(coords [][]float64) string {
+	var b strings.Builder
+	var prevLat, prevLng int64
+	for _, coord := range coords {
+		if len(coord) < 2 {
+			continue
+		}
+		lat := int64(math.Round(coord[1] * 1e5))
+		lng := int64(math.Round(coord[0] * 1e5))
+		encodePolylineValue(&b, lat-prevLat)
+		encodePolylineValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodePolylineValue(b *strings.Builder, value int64) {
+	shifted := value << 1
+	if value < 0 {
+		shifted = ^shifted
+	}
+	for shifted >= 0x20 {
+		b.WriteByte(byte((0x20 | (shifted & 0x1f)) + 63))
+		shifted >>= 5
+	}
+	b.WriteByte(byte(shifted + 63))
+}
+
+// hasElevation reports whether any coordinate has a non-zero elevation.
+// Routes without elevation data are stored with zeros, which shouldn't be
+// exported as if the route were at sea level.
+func hasElevation(coords [][]float64) bool {
+	for _, coord := range coords {
+		if len(coord) > 2 && coord[2] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func formatCoord(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode route: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -6,7 +6,6 @@ import (
 	"log/slog"
 	"net/http"
 	"regexp"
-	"time"
 )
 
 // RouteFetcher handles fetching routes from external sources
@@ -79,9 +78,12 @@ func (f *RouteFetcher) fetchRideWithGPSRoute(routeURL string) (GeoJSONFeature, e
 		req.Header.Set("x-rwgps-auth-token", f.rwgpsAuthToken)
 	}
 
-	// Create a client that doesn't follow redirects past a certain point
+	// Create a client that doesn't follow redirects past a certain point,
+	// sharing the fetcher's transport (and any rate limiting it applies) and
+	// timeout
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: f.httpClient.Transport,
+		Timeout:   f.httpClient.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return fmt.Errorf("too many redirects")
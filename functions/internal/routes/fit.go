@@ -0,0 +1,254 @@
+package routes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// FIT is Garmin's binary format for activities and courses, written by most
+// bike computers. Only the messages a route needs are decoded: record messages
+// for the track and the course message for its name.
+// https://developer.garmin.com/fit/protocol/
+
+const (
+	fitMesgRecord = 20
+	fitMesgCourse = 31
+
+	fitFieldPositionLat      = 0
+	fitFieldPositionLong     = 1
+	fitFieldAltitude         = 2
+	fitFieldDistance         = 5
+	fitFieldEnhancedAltitude = 78
+	fitFieldCourseName       = 5
+
+	// fitSemicircles converts FIT positions to degrees
+	fitSemicircles = 180.0 / (1 << 31)
+)
+
+// fitCRCTable is the nibble table of the FIT checksum, CRC-16 with the
+// polynomial 0xA001
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitDefinition describes the layout of the data messages of a local message
+// type
+type fitDefinition struct {
+	global uint16
+	order  binary.ByteOrder
+	fields []fitField
+	// size is the length of a data message, developer fields included
+	size int
+}
+
+type fitField struct {
+	num  byte
+	size int
+}
+
+// isFIT reports whether data starts with a FIT file header
+func isFIT(data []byte) bool {
+	return len(data) >= 12 && (data[0] == 12 || data[0] == 14) && string(data[8:12]) == ".FIT"
+}
+
+// ConvertFITtoGeoJSON parses a FIT course or activity and returns a GeoJSON
+// Feature with elevation data. The distance the file recorded is used when it
+// has one. Only the first file of a chained FIT file is read.
+func ConvertFITtoGeoJSON(fitData io.Reader) (GeoJSONFeature, error) {
+	data, err := io.ReadAll(fitData)
+	if err != nil {
+		return GeoJSONFeature{}, fmt.Errorf("failed to read FIT: %w", err)
+	}
+	if !isFIT(data) {
+		return GeoJSONFeature{}, fmt.Errorf("failed to parse FIT: missing file header")
+	}
+
+	headerSize := int(data[0])
+	end := headerSize + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end+2 > len(data) || end < headerSize {
+		return GeoJSONFeature{}, fmt.Errorf("failed to parse FIT: file is truncated")
+	}
+	if fitCRC(data[:end]) != binary.LittleEndian.Uint16(data[end:end+2]) {
+		return GeoJSONFeature{}, fmt.Errorf("failed to parse FIT: checksum mismatch")
+	}
+
+	var definitions [16]*fitDefinition
+	var coords [][]float64
+	var recordedMeters float64
+	var name string
+
+	for pos := headerSize; pos < end; {
+		header := data[pos]
+		pos++
+
+		var local byte
+		switch {
+		case header&0x80 != 0:
+			// Compressed timestamp header, always a data message
+			local = (header >> 5) & 0x03
+		case header&0x40 != 0:
+			definition, n, err := parseFITDefinition(data[pos:end], header&0x20 != 0)
+			if err != nil {
+				return GeoJSONFeature{}, fmt.Errorf("failed to parse FIT: %w", err)
+			}
+			definitions[header&0x0f] = definition
+			pos += n
+			continue
+		default:
+			local = header & 0x0f
+		}
+
+		definition := definitions[local]
+		if definition == nil {
+			return GeoJSONFeature{}, fmt.Errorf("failed to parse FIT: data message for undefined local type %d", local)
+		}
+		if pos+definition.size > end {
+			return GeoJSONFeature{}, fmt.Errorf("failed to parse FIT: file is truncated")
+		}
+		message := data[pos : pos+definition.size]
+		pos += definition.size
+
+		switch definition.global {
+		case fitMesgRecord:
+			coord, distance, ok := decodeFITRecord(definition, message)
+			if ok {
+				coords = append(coords, coord)
+			}
+			if distance > recordedMeters {
+				recordedMeters = distance
+			}
+		case fitMesgCourse:
+			if name == "" {
+				name = decodeFITCourseName(definition, message)
+			}
+		}
+	}
+
+	if len(coords) == 0 {
+		return GeoJSONFeature{}, fmt.Errorf("no valid coordinates found in FIT")
+	}
+
+	return lineFeature(coords, recordedMeters/1000, name), nil
+}
+
+// parseFITDefinition reads a definition message and returns how many bytes
+// it took up
+func parseFITDefinition(data []byte, hasDeveloperFields bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("definition message is truncated")
+	}
+
+	definition := &fitDefinition{order: binary.LittleEndian}
+	if data[1] == 1 {
+		definition.order = binary.BigEndian
+	}
+	definition.global = definition.order.Uint16(data[2:4])
+
+	fieldCount := int(data[4])
+	n := 5 + fieldCount*3
+	if len(data) < n {
+		return nil, 0, fmt.Errorf("definition message is truncated")
+	}
+	for i := 0; i < fieldCount; i++ {
+		field := fitField{num: data[5+i*3], size: int(data[6+i*3])}
+		definition.fields = append(definition.fields, field)
+		definition.size += field.size
+	}
+
+	// Developer fields are skipped, but count towards the message size
+	if hasDeveloperFields {
+		if len(data) < n+1 {
+			return nil, 0, fmt.Errorf("definition message is truncated")
+		}
+		devCount := int(data[n])
+		n++
+		if len(data) < n+devCount*3 {
+			return nil, 0, fmt.Errorf("definition message is truncated")
+		}
+		for i := 0; i < devCount; i++ {
+			definition.size += int(data[n+i*3+1])
+		}
+		n += devCount * 3
+	}
+
+	return definition, n, nil
+}
+
+// decodeFITRecord reads a record's position and altitude as a [lon, lat,
+// elevation] coordinate, and the distance covered so far in meters. Records
+// logged without a GPS fix have no position.
+func decodeFITRecord(definition *fitDefinition, message []byte) ([]float64, float64, bool) {
+	var lat, lon, altitude, enhancedAltitude, distance float64
+	hasLat, hasLon, hasEnhancedAltitude := false, false, false
+
+	offset := 0
+	for _, field := range definition.fields {
+		value := message[offset : offset+field.size]
+		offset += field.size
+
+		switch {
+		case field.num == fitFieldPositionLat && field.size == 4:
+			if v := int32(definition.order.Uint32(value)); v != math.MaxInt32 {
+				lat, hasLat = float64(v)*fitSemicircles, true
+			}
+		case field.num == fitFieldPositionLong && field.size == 4:
+			if v := int32(definition.order.Uint32(value)); v != math.MaxInt32 {
+				lon, hasLon = float64(v)*fitSemicircles, true
+			}
+		case field.num == fitFieldAltitude && field.size == 2:
+			if v := definition.order.Uint16(value); v != math.MaxUint16 {
+				altitude = float64(v)/5 - 500
+			}
+		case field.num == fitFieldEnhancedAltitude && field.size == 4:
+			if v := definition.order.Uint32(value); v != math.MaxUint32 {
+				enhancedAltitude, hasEnhancedAltitude = float64(v)/5-500, true
+			}
+		case field.num == fitFieldDistance && field.size == 4:
+			if v := definition.order.Uint32(value); v != math.MaxUint32 {
+				distance = float64(v) / 100
+			}
+		}
+	}
+
+	if !hasLat || !hasLon {
+		return nil, distance, false
+	}
+	// Altitude is left at zero when the record has none, as in other formats
+	if hasEnhancedAltitude {
+		altitude = enhancedAltitude
+	}
+	return []float64{lon, lat, altitude}, distance, true
+}
+
+// decodeFITCourseName reads the name of a course message
+func decodeFITCourseName(definition *fitDefinition, message []byte) string {
+	offset := 0
+	for _, field := range definition.fields {
+		value := message[offset : offset+field.size]
+		offset += field.size
+		if field.num == fitFieldCourseName {
+			name, _, _ := strings.Cut(string(value), "\x00")
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// fitCRC computes the FIT checksum of data
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0x0f]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ fitCRCTable[b&0x0f]
+
+		tmp = fitCRCTable[crc&0x0f]
+		crc = (crc >> 4) & 0x0fff
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0x0f]
+	}
+	return crc
+}
@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ConvertKMLtoGeoJSON parses KML data and returns a GeoJSON Feature with
+// elevation data. Every LineString and gx:Track in the file is joined into one
+// line; points and polygons are ignored.
+func ConvertKMLtoGeoJSON(kmlData io.Reader) (GeoJSONFeature, error) {
+	decoder := xml.NewDecoder(kmlData)
+
+	var stack []string
+	var name string
+	var coords [][]float64
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return GeoJSONFeature{}, fmt.Errorf("failed to parse KML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) < 2 {
+				continue
+			}
+			element, parent := stack[len(stack)-1], stack[len(stack)-2]
+			switch {
+			case element == "coordinates" && parent == "LineString":
+				// Tuples of lon,lat[,alt] separated by whitespace
+				for _, tuple := range strings.Fields(string(t)) {
+					coord, err := parseKMLCoord(strings.Split(tuple, ","))
+					if err != nil {
+						return GeoJSONFeature{}, err
+					}
+					coords = append(coords, coord)
+				}
+			case element == "coord" && parent == "Track":
+				// gx:coord is a single "lon lat alt" tuple
+				if fields := strings.Fields(string(t)); len(fields) > 0 {
+					coord, err := parseKMLCoord(fields)
+					if err != nil {
+						return GeoJSONFeature{}, err
+					}
+					coords = append(coords, coord)
+				}
+			case element == "name" && name == "" && (parent == "Document" || parent == "Placemark"):
+				name = strings.TrimSpace(string(t))
+			}
+		}
+	}
+
+	if len(coords) == 0 {
+		return GeoJSONFeature{}, fmt.Errorf("no valid coordinates found in KML")
+	}
+
+	return lineFeature(coords, 0, name), nil
+}
+
+// parseKMLCoord reads a KML coordinate tuple as [lon, lat, elevation]
+func parseKMLCoord(values []string) ([]float64, error) {
+	if len(values) < 2 || len(values) > 3 {
+		return nil, fmt.Errorf("invalid KML coordinate %q", strings.Join(values, ","))
+	}
+
+	coord := []float64{0, 0, 0}
+	for i, value := range values {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KML coordinate %q", strings.Join(values, ","))
+		}
+		coord[i] = parsed
+	}
+	return coord, nil
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TCXDatabase represents the structure of a Garmin Training Center (TCX)
+// file (simplified). Courses hold planned routes and activities hold
+// recorded rides; both are a list of trackpoints.
+type TCXDatabase struct {
+	XMLName xml.Name `xml:"TrainingCenterDatabase"`
+	Courses []struct {
+		Name   string          `xml:"Name"`
+		Points []tcxTrackpoint `xml:"Track>Trackpoint"`
+	} `xml:"Courses>Course"`
+	Activities []struct {
+		Laps []struct {
+			Points []tcxTrackpoint `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+type tcxTrackpoint struct {
+	Position *struct {
+		Lat float64 `xml:"LatitudeDegrees"`
+		Lon float64 `xml:"LongitudeDegrees"`
+	} `xml:"Position"`
+	Ele      float64  `xml:"AltitudeMeters"`
+	Distance *float64 `xml:"DistanceMeters"`
+}
+
+// ConvertTCXtoGeoJSON parses TCX data and returns a GeoJSON Feature with
+// elevation data. Courses take precedence over activities, and the distance
+// the file recorded is used when it has one.
+func ConvertTCXtoGeoJSON(tcxData io.Reader) (GeoJSONFeature, error) {
+	var tcx TCXDatabase
+	if err := xml.NewDecoder(tcxData).Decode(&tcx); err != nil {
+		return GeoJSONFeature{}, fmt.Errorf("failed to parse TCX: %w", err)
+	}
+
+	var name string
+	var points []tcxTrackpoint
+	for _, course := range tcx.Courses {
+		if name == "" {
+			name = strings.TrimSpace(course.Name)
+		}
+		points = append(points, course.Points...)
+	}
+
+	// If no courses, process activities
+	if len(points) == 0 {
+		for _, activity := range tcx.Activities {
+			for _, lap := range activity.Laps {
+				points = append(points, lap.Points...)
+			}
+		}
+	}
+
+	var coords [][]float64
+	var recordedMeters float64
+	for _, point := range points {
+		// Trackpoints recorded without a GPS fix have no position
+		if point.Position == nil {
+			continue
+		}
+		coords = append(coords, []float64{point.Position.Lon, point.Position.Lat, point.Ele})
+		if point.Distance != nil && *point.Distance > recordedMeters {
+			recordedMeters = *point.Distance
+		}
+	}
+
+	if len(coords) == 0 {
+		return GeoJSONFeature{}, fmt.Errorf("no valid coordinates found in TCX")
+	}
+
+	return lineFeature(coords, recordedMeters/1000, name), nil
+}
+
+// lineFeature builds a LineString feature from coordinates, using the
+// distance a route file recorded when it has one and measuring it otherwise
+func lineFeature(coords [][]float64, recordedKm float64, name string) GeoJSONFeature {
+	distanceKm := recordedKm
+	if distanceKm <= 0 {
+		distanceKm = calculateDistance(coords)
+	}
+	distanceMi := distanceKm * 0.621371
+
+	properties := map[string]any{
+		"distance_km": distanceKm,
+		"distance_mi": distanceMi,
+	}
+	if name != "" {
+		properties["name"] = name
+	}
+
+	return GeoJSONFeature{
+		Type: "Feature",
+		Geometry: GeoJSONGeometry{
+			Type:        "LineString",
+			Coordinates: coords,
+		},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,85 @@
+package scraper
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CityDetails is a city's entry in cities.json. The NE/SW corners bias
+// geocoding towards the city itself, while Region covers the wider area rides
+// can start from and is used to validate coordinates found in ride text. The
+// center is downtown, which short Plus Codes are read relative to.
+type CityDetails struct {
+	CityName     string       `json:"cityName"`
+	State        string       `json:"state"`
+	NearbyStates []string     `json:"nearbyStates"`
+	Timezone     string       `json:"timezone"`
+	CenterLat    float64      `json:"centerLat"`
+	CenterLng    float64      `json:"centerLng"`
+	NELat        float64      `json:"neLat"`
+	NELng        float64      `json:"neLng"`
+	SWLat        float64      `json:"swLat"`
+	SWLng        float64      `json:"swLng"`
+	Region       *BoundingBox `json:"region"`
+}
+
+// BoundingBox is a lat/lng box
+type BoundingBox struct {
+	NELat float64 `json:"neLat"`
+	NELng float64 `json:"neLng"`
+	SWLat float64 `json:"swLat"`
+	SWLng float64 `json:"swLng"`
+}
+
+// Contains reports whether a coordinate falls inside the box
+func (b BoundingBox) Contains(lat, lng float64) bool {
+	return lat >= b.SWLat && lat <= b.NELat && lng >= b.SWLng && lng <= b.NELng
+}
+
+// RideBounds returns the area a ride in the city may start from, falling back
+// to the geocoding bounds when no region is configured
+func (c CityDetails) RideBounds() BoundingBox {
+	if c.Region != nil {
+		return *c.Region
+	}
+	return BoundingBox{NELat: c.NELat, NELng: c.NELng, SWLat: c.SWLat, SWLng: c.SWLng}
+}
+
+// States returns the city's state followed by any neighbouring states its rides
+// are commonly held in
+func (c CityDetails) States() []string {
+	return append([]string{c.State}, c.NearbyStates...)
+}
+
+//go:embed cities.json
+var citiesJSON []byte
+
+var cityMap map[string]CityDetails
+
+func init() {
+	if err := json.Unmarshal(citiesJSON, &cityMap); err != nil {
+		panic(fmt.Sprintf("failed to parse cities.json: %v", err))
+	}
+}
+
+// GetCityDetails returns the configuration for a city code from cities.json
+func GetCityDetails(cityCode string) (CityDetails, bool) {
+	details, ok := cityMap[cityCode]
+	return details, ok
+}
+
+// CityLocation returns the time zone a city's rides are scheduled in
+func CityLocation(cityCode string) (*time.Location, error) {
+	details, ok := cityMap[cityCode]
+	if !ok || details.Timezone == "" {
+		return nil, fmt.Errorf("no timezone configured for city %q", cityCode)
+	}
+
+	location, err := time.LoadLocation(details.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone location: %w", err)
+	}
+	return location, nil
+}
@@ -5,12 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 )
 
 func GetGeocodeCache(db *sql.DB) (map[string]GeoCodeCached, error) {
-	rows, err := db.Query("SELECT location_key, lat, lng FROM geocode_cache")
+	rows, err := db.Query(`
+		SELECT location_key, lat, lng, COALESCE(provider, ''), COALESCE(place_id, ''),
+		       COALESCE(formatted_address, ''), COALESCE(granularity, ''), COALESCE(types, ''),
+		       COALESCE(confidence, ''), COALESCE(expires_at, '')
+		FROM geocode_cache
+	`)
 	if err != nil {
 		slog.Error("Something went wrong when calling for geocode cache", "error", err.Error())
 		return nil, fmt.Errorf("failed to query geocode cache: %w", err)
@@ -21,13 +27,19 @@ func GetGeocodeCache(db *sql.DB) (map[string]GeoCodeCached, error) {
 	cachedAddresses := make(map[string]GeoCodeCached)
 
 	for rows.Next() {
-		var key string
-		var lat, lng float64
-		if err := rows.Scan(&key, &lat, &lng); err != nil {
+		var key, types, confidence, expiresAt string
+		var cached GeoCodeCached
+		if err := rows.Scan(
+			&key, &cached.Latitude, &cached.Longitude, &cached.Provider, &cached.PlaceID,
+			&cached.FormattedAddress, &cached.Granularity, &types, &confidence, &expiresAt,
+		); err != nil {
 			slog.Error("Unalble to scan row", "error", err.Error())
 			continue
 		}
-		cachedAddresses[strings.ToLower(key)] = GeoCodeCached{Latitude: lat, Longitude: lng}
+
+		cached.Query = strings.ToLower(key)
+		cached.setMetadata(types, confidence, expiresAt)
+		cachedAddresses[cached.Query] = cached
 	}
 	if err = rows.Err(); err != nil {
 		slog.Error("Something went wrong while iterating though stored rows", "error", err.Error())
@@ -55,30 +67,70 @@ func BulkUpsertGeocodeData(db *sql.DB, locations []Location) error {
 	}()
 
 	stmt, err := tx.Prepare(`
-        INSERT INTO geocode_cache (location_key, lat, lng, city, last_updated)
-        VALUES (?, ?, ?, ?, ?)
+        INSERT INTO geocode_cache (
+            location_key, lat, lng, city, last_updated,
+            provider, place_id, formatted_address, granularity, types, viewport, confidence, expires_at
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
         ON CONFLICT(location_key) DO UPDATE SET
 						city=excluded.city,
             lat=excluded.lat,
             lng=excluded.lng,
-            last_updated=excluded.last_updated;
+            last_updated=excluded.last_updated,
+            provider=excluded.provider,
+            place_id=excluded.place_id,
+            formatted_address=excluded.formatted_address,
+            granularity=excluded.granularity,
+            types=excluded.types,
+            viewport=excluded.viewport,
+            confidence=excluded.confidence,
+            expires_at=excluded.expires_at
+        WHERE geocode_cache.provider IS NOT 'manual';
         `)
 	if err != nil {
 		return fmt.Errorf("failed to prepare geocode cache upsert statement: %v", err)
 	}
 	defer stmt.Close()
 
-	now := time.Now().Format(time.RFC3339)
+	now := time.Now().UTC()
 
 	for i := range locations {
 		loc := locations[i]
 
+		var provider, placeID, formattedAddress, granularity, types, viewport interface{}
+		confidence := ConfidenceUnknown
+		if result := loc.Geocoded; result != nil {
+			provider = nilIfEmpty(result.Provider)
+			placeID = nilIfEmpty(result.PlaceID)
+			formattedAddress = nilIfEmpty(result.FormattedAddress)
+			granularity = nilIfEmpty(result.Granularity)
+			if len(result.Types) > 0 {
+				if encoded, marshalErr := json.Marshal(result.Types); marshalErr == nil {
+					types = string(encoded)
+				}
+			}
+			if result.Viewport != nil {
+				if encoded, marshalErr := json.Marshal(result.Viewport); marshalErr == nil {
+					viewport = string(encoded)
+				}
+			}
+			confidence = result.Confidence()
+		}
+
 		_, err := stmt.Exec(
 			strings.ToLower(loc.Query),
 			loc.Latitude,
 			loc.Longitude,
 			loc.City,
-			now,
+			now.Format(time.RFC3339),
+			provider,
+			placeID,
+			formattedAddress,
+			granularity,
+			types,
+			viewport,
+			string(confidence),
+			now.Add(confidence.TTL()).Format(time.RFC3339),
 		)
 		if err != nil {
 			slog.Error("Failed to upsert single location in batch", "loc", loc, "error", err.Error())
@@ -93,7 +145,7 @@ func BulkUpsertGeocodeData(db *sql.DB, locations []Location) error {
 	return nil
 }
 
-func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
+func BulkUpsertRideData(db *sql.DB, rideData []Event) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin bulk transaction: %v", err)
@@ -139,9 +191,23 @@ func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
 						citycode,
 						ridesource,
 						source_data,
-						route_id
+						route_id,
+						group_code,
+						source_hash,
+						geocode_query,
+						end_lat,
+						end_lng,
+						length,
+						area,
+						featured,
+						tinytitle,
+						printdescr,
+						datestype,
+						caldaily_id,
+						status,
+						location_quality
         )
-        VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+        VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
         ON CONFLICT(composite_event_id) DO UPDATE SET
             id=excluded.id,
             address=excluded.address,
@@ -171,7 +237,22 @@ func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
 						citycode=excluded.citycode,
 						ridesource=excluded.ridesource,
             source_data=excluded.source_data,
-            route_id=excluded.route_id;
+            route_id=excluded.route_id,
+            group_code=excluded.group_code,
+            source_hash=excluded.source_hash,
+            geocode_query=excluded.geocode_query,
+            end_lat=excluded.end_lat,
+            end_lng=excluded.end_lng,
+            length=excluded.length,
+            area=excluded.area,
+            featured=excluded.featured,
+            tinytitle=excluded.tinytitle,
+            printdescr=excluded.printdescr,
+            datestype=excluded.datestype,
+            caldaily_id=excluded.caldaily_id,
+            status=excluded.status,
+            location_quality=excluded.location_quality,
+            removed_at=NULL;
         `)
 	if err != nil {
 		return fmt.Errorf("failed to prepare ride data upsert statement: %v", err)
@@ -181,7 +262,7 @@ func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
 	for i := range rideData {
 		ride := rideData[i]
 
-		compositeKey := fmt.Sprintf("%s_%s", ride.ID, ride.Date)
+		compositeKey := CompositeEventID(ride)
 
 		sourceData, marshalErr := json.Marshal(ride)
 		if marshalErr != nil {
@@ -209,6 +290,23 @@ func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
 			routeID = ride.RouteID
 		}
 
+		var groupCode interface{}
+		if ride.GroupCode != "" {
+			groupCode = ride.GroupCode
+		}
+
+		locationQuality := ride.Location.Quality
+		if locationQuality == "" {
+			locationQuality = LocationUnknown
+		}
+
+		// NULL end coordinates when the ride has no ending location
+		var endLat, endLng interface{}
+		if ride.EndLocation.Latitude != 0 || ride.EndLocation.Longitude != 0 {
+			endLat = ride.EndLocation.Latitude
+			endLng = ride.EndLocation.Longitude
+		}
+
 		_, err = stmt.Exec(
 			compositeKey,
 			ride.ID,
@@ -240,6 +338,20 @@ func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
 			ride.SourcedFrom,
 			string(sourceData),
 			routeID,
+			groupCode,
+			HashEvent(ride),
+			nilIfEmpty(ride.GeocodeQuery),
+			endLat,
+			endLng,
+			nilIfEmpty(ride.Length),
+			nilIfEmpty(ride.Area),
+			boolToInt(ride.Featured),
+			nilIfEmpty(ride.Tinytitle),
+			nilIfEmpty(ride.Printdescr),
+			nilIfEmpty(ride.Datestype),
+			nilIfEmpty(ride.CaldailyID),
+			nilIfEmpty(ride.Status),
+			string(locationQuality),
 		)
 		if err != nil {
 			slog.Error("Failed to upsert single location in batch", "key", compositeKey, "error", err.Error())
@@ -253,3 +365,315 @@ func BulkUpsertRideData(db *sql.DB, rideData []Shift2BikeEvent) error {
 
 	return nil
 }
+
+// GetGroupCalendars returns the active ride groups in a city that have registered an iCalendar feed
+func GetGroupCalendars(db *sql.DB, cityCode string) ([]GroupCalendar, error) {
+	rows, err := db.Query(`
+		SELECT code, name, ical_url
+		FROM ride_groups
+		WHERE LOWER(city) = ? AND is_active = 1 AND ical_url IS NOT NULL AND ical_url != ''
+	`, strings.ToLower(cityCode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group calendars: %w", err)
+	}
+	defer rows.Close()
+
+	var calendars []GroupCalendar
+	for rows.Next() {
+		calendar := GroupCalendar{CityCode: cityCode}
+		if err := rows.Scan(&calendar.GroupCode, &calendar.GroupName, &calendar.URL); err != nil {
+			return nil, fmt.Errorf("failed to scan group calendar: %w", err)
+		}
+		calendars = append(calendars, calendar)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return calendars, nil
+}
+
+// GetStoredEvents returns the stored rides for a city that fall within the
+// window, keyed by composite_event_id
+func GetStoredEvents(db *sql.DB, cityCode string, window Window) (map[string]StoredEvent, error) {
+	rows, err := db.Query(`
+		SELECT composite_event_id, ridesource, COALESCE(group_code, ''), date, COALESCE(source_hash, ''), source_data,
+		       COALESCE(removed_at, ''), COALESCE(geocode_query, '')
+		FROM shift2bikes_events
+		WHERE citycode = ? AND date BETWEEN ? AND ?
+	`, cityCode, window.Start.Format("2006-01-02"), window.End.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored events: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]StoredEvent)
+	for rows.Next() {
+		event := StoredEvent{CityCode: cityCode}
+		if err := rows.Scan(&event.CompositeEventID, &event.Source, &event.GroupCode, &event.Date, &event.SourceHash, &event.SourceData, &event.RemovedAt, &event.GeocodeQuery); err != nil {
+			return nil, fmt.Errorf("failed to scan stored event: %w", err)
+		}
+		stored[event.CompositeEventID] = event
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return stored, nil
+}
+
+// SyncEventTags makes the stored tags of each event match its Tags, writing
+// only the events whose tags differ. Tags are compared for every fetched
+// event, not only changed ones, so edits to the tagging rules reach rides that
+// haven't changed upstream. It returns the number of events retagged.
+func SyncEventTags(db *sql.DB, cityCode string, window Window, events []Event) (int, error) {
+	rows, err := db.Query(`
+		SELECT t.composite_event_id, t.tag
+		FROM shift2bikes_event_tags t
+		JOIN shift2bikes_events e ON e.composite_event_id = t.composite_event_id
+		WHERE e.citycode = ? AND e.date BETWEEN ? AND ?
+	`, cityCode, window.Start.Format("2006-01-02"), window.End.Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query stored tags: %w", err)
+	}
+	stored := make(map[string][]string)
+	for rows.Next() {
+		var key, tag string
+		if err := rows.Scan(&key, &tag); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan stored tag: %w", err)
+		}
+		stored[key] = append(stored[key], tag)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	var retag []Event
+	for _, event := range events {
+		current := stored[CompositeEventID(event)]
+		slices.Sort(current)
+		if !slices.Equal(current, event.Tags) {
+			retag = append(retag, event)
+		}
+	}
+	if len(retag) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tag transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, event := range retag {
+		key := CompositeEventID(event)
+		if _, err = tx.Exec(`DELETE FROM shift2bikes_event_tags WHERE composite_event_id = ?`, key); err != nil {
+			return 0, fmt.Errorf("failed to clear tags for %s: %w", key, err)
+		}
+		for _, tag := range event.Tags {
+			if _, err = tx.Exec(`INSERT INTO shift2bikes_event_tags (composite_event_id, tag) VALUES (?, ?)`, key, tag); err != nil {
+				return 0, fmt.Errorf("failed to tag %s: %w", key, err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tags: %w", err)
+	}
+
+	return len(retag), nil
+}
+
+// MarkEventsRemoved tombstones rides that are no longer returned upstream.
+// The rows are kept so a ride that reappears is restored by the next upsert.
+func MarkEventsRemoved(db *sql.DB, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin remove transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.Prepare(`
+		UPDATE shift2bikes_events SET removed_at = ?
+		WHERE composite_event_id = ? AND removed_at IS NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare remove statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, change := range changes {
+		if _, err = stmt.Exec(now, change.CompositeEventID); err != nil {
+			return fmt.Errorf("failed to mark %s removed: %w", change.CompositeEventID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit removed events: %w", err)
+	}
+
+	return nil
+}
+
+// RecordScrapeChanges appends a run's changes to the scrape_changes log
+func RecordScrapeChanges(db *sql.DB, runID string, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin scrape change transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO scrape_changes (run_id, composite_event_id, citycode, ridesource, change_type, diff, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare scrape change statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, change := range changes {
+		var diff interface{}
+		if len(change.Diff) > 0 {
+			encoded, marshalErr := json.Marshal(change.Diff)
+			if marshalErr != nil {
+				slog.Warn("failed to encode scrape change diff", "key", change.CompositeEventID, "error", marshalErr)
+			} else {
+				diff = string(encoded)
+			}
+		}
+
+		_, err = stmt.Exec(runID, change.CompositeEventID, change.CityCode, change.Source, string(change.Type), diff, now)
+		if err != nil {
+			return fmt.Errorf("failed to record %s change for %s: %w", change.Type, change.CompositeEventID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit scrape changes: %w", err)
+	}
+
+	return nil
+}
+
+// ArchiveEvents upserts rides into ride_archive, the long-term history the
+// backfill builds. Rides already archived are overwritten with what upstream
+// returns now.
+func ArchiveEvents(db *sql.DB, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin archive transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO ride_archive (
+			composite_event_id, id, citycode, ridesource, title, date, starttime,
+			endtime, eventduration, venue, address, organizer, audience, length,
+			area, loopride, cancelled, source_data, archived_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(composite_event_id) DO UPDATE SET
+			id = excluded.id,
+			citycode = excluded.citycode,
+			ridesource = excluded.ridesource,
+			title = excluded.title,
+			date = excluded.date,
+			starttime = excluded.starttime,
+			endtime = excluded.endtime,
+			eventduration = excluded.eventduration,
+			venue = excluded.venue,
+			address = excluded.address,
+			organizer = excluded.organizer,
+			audience = excluded.audience,
+			length = excluded.length,
+			area = excluded.area,
+			loopride = excluded.loopride,
+			cancelled = excluded.cancelled,
+			source_data = excluded.source_data,
+			archived_at = excluded.archived_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare archive statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, event := range events {
+		sourceData, marshalErr := json.Marshal(event)
+		if marshalErr != nil {
+			sourceData = []byte("{}")
+		}
+
+		var duration interface{}
+		if event.Eventduration > 0 {
+			duration = event.Eventduration
+		}
+
+		_, err = stmt.Exec(
+			CompositeEventID(event), event.ID, event.CityCode, event.SourcedFrom, event.Title,
+			event.Date, event.Time, nilIfEmpty(event.Endtime), duration, event.Venue,
+			event.Address, event.Organizer, event.Audience, nilIfEmpty(event.Length),
+			nilIfEmpty(event.Area), boolToInt(event.Loopride), boolToInt(event.Cancelled),
+			string(sourceData), now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", CompositeEventID(event), err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archived rides: %w", err)
+	}
+
+	return nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func nilIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
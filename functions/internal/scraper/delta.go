@@ -0,0 +1,218 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ChangeType is the kind of change a scrape made to a stored ride
+type ChangeType string
+
+const (
+	ChangeCreate ChangeType = "create"
+	ChangeUpdate ChangeType = "update"
+	ChangeCancel ChangeType = "cancel"
+	ChangeRemove ChangeType = "remove"
+)
+
+// FieldDiff is the before and after value of a single upstream field
+type FieldDiff struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Change is a row of the scrape_changes log
+type Change struct {
+	CompositeEventID string
+	CityCode         string
+	Source           string
+	Type             ChangeType
+	Diff             map[string]FieldDiff
+}
+
+// StoredEvent is the part of a stored ride needed to decide whether a freshly
+// scraped event has changed
+type StoredEvent struct {
+	CompositeEventID string
+	CityCode         string
+	Source           string
+	GroupCode        string
+	Date             string
+	SourceHash       string
+	SourceData       string
+	RemovedAt        string
+	// GeocodeQuery is the geocode_cache key the ride's start was looked up with
+	GeocodeQuery string
+}
+
+// CompositeEventID is the primary key of an event's shift2bikes_events row
+func CompositeEventID(event Event) string {
+	return fmt.Sprintf("%s_%s", event.ID, event.Date)
+}
+
+// HashEvent fingerprints the upstream payload of an event, which is what is
+// stored as source_data
+func HashEvent(event Event) string {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// DetectChanges compares scraped events with their stored rows and returns
+// only the events that need to be written, along with the changes to log.
+// Rows stored before hashing was introduced are rewritten to record their
+// hash, but only logged if their payload actually differs.
+func DetectChanges(events []Event, stored map[string]StoredEvent) ([]Event, []Change) {
+	var changed []Event
+	var changes []Change
+
+	for _, event := range events {
+		key := CompositeEventID(event)
+		hash := HashEvent(event)
+
+		existing, found := stored[key]
+		if !found {
+			changed = append(changed, event)
+			changes = append(changes, Change{
+				CompositeEventID: key,
+				CityCode:         event.CityCode,
+				Source:           event.SourcedFrom,
+				Type:             ChangeCreate,
+			})
+			continue
+		}
+
+		// A ride that was removed upstream and has come back is restored
+		if existing.RemovedAt != "" {
+			changed = append(changed, event)
+			changes = append(changes, Change{
+				CompositeEventID: key,
+				CityCode:         event.CityCode,
+				Source:           event.SourcedFrom,
+				Type:             ChangeUpdate,
+				Diff: map[string]FieldDiff{
+					"removed_at": {Old: existing.RemovedAt, New: nil},
+				},
+			})
+			continue
+		}
+
+		if existing.SourceHash == hash {
+			continue
+		}
+		changed = append(changed, event)
+
+		diff := diffSourceData(existing.SourceData, event)
+		if len(diff) == 0 {
+			continue
+		}
+
+		changeType := ChangeUpdate
+		if cancelled, ok := diff["cancelled"]; ok && cancelled.New == true {
+			changeType = ChangeCancel
+		}
+
+		changes = append(changes, Change{
+			CompositeEventID: key,
+			CityCode:         event.CityCode,
+			Source:           event.SourcedFrom,
+			Type:             changeType,
+			Diff:             diff,
+		})
+	}
+
+	return changed, changes
+}
+
+// DetectStaleGeocodes returns the fetched events DetectChanges left out as
+// unchanged whose start was located with a geocode_cache entry that has since
+// expired, so they are geocoded again rather than keeping those coordinates
+// until the ride next changes upstream
+func DetectStaleGeocodes(events, changed []Event, stored map[string]StoredEvent, cache map[string]GeoCodeCached, now time.Time) []Event {
+	pending := make(map[string]bool, len(changed))
+	for _, event := range changed {
+		pending[CompositeEventID(event)] = true
+	}
+
+	var stale []Event
+	for _, event := range events {
+		key := CompositeEventID(event)
+		existing, found := stored[key]
+		if !found || pending[key] || existing.GeocodeQuery == "" {
+			continue
+		}
+		if cached, ok := cache[existing.GeocodeQuery]; ok && cached.NeedsRefresh(now) {
+			stale = append(stale, event)
+		}
+	}
+	return stale
+}
+
+// DetectRemovals returns a remove change for every stored ride in the window
+// that belongs to one of the fetched sources but was not returned this run.
+// Only sources that were fetched successfully should be passed in, so an
+// upstream outage never removes rides.
+func DetectRemovals(events []Event, stored map[string]StoredEvent, fetched []EventSource) []Change {
+	seen := make(map[string]bool, len(events))
+	for _, event := range events {
+		seen[CompositeEventID(event)] = true
+	}
+
+	var changes []Change
+	for key, existing := range stored {
+		if seen[key] || existing.RemovedAt != "" {
+			continue
+		}
+		for _, source := range fetched {
+			if source.Owns(existing) {
+				changes = append(changes, Change{
+					CompositeEventID: key,
+					CityCode:         existing.CityCode,
+					Source:           existing.Source,
+					Type:             ChangeRemove,
+				})
+				break
+			}
+		}
+	}
+
+	return changes
+}
+
+// diffSourceData returns the upstream fields whose values differ between the
+// stored source_data and the event
+func diffSourceData(storedData string, event Event) map[string]FieldDiff {
+	var before, after map[string]any
+	if err := json.Unmarshal([]byte(storedData), &before); err != nil {
+		before = map[string]any{}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(payload, &after); err != nil {
+		return nil
+	}
+
+	diff := make(map[string]FieldDiff)
+	for field, newValue := range after {
+		if oldValue := before[field]; !reflect.DeepEqual(oldValue, newValue) {
+			diff[field] = FieldDiff{Old: oldValue, New: newValue}
+		}
+	}
+	for field, oldValue := range before {
+		if _, ok := after[field]; !ok {
+			diff[field] = FieldDiff{Old: oldValue, New: nil}
+		}
+	}
+
+	return diff
+}
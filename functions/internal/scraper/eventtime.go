@@ -0,0 +1,105 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoStartTime is returned for rides without a start time, such as all day
+// events
+var ErrNoStartTime = errors.New("ride has no start time")
+
+// EventTimes is when a ride starts and ends as absolute instants. Rides store
+// their date and times as local wall clock strings in the city's time zone;
+// ResolveEventTimes turns them into instants.
+type EventTimes struct {
+	Start time.Time
+	// End is zero when the ride has neither an end time nor a duration
+	End time.Time
+}
+
+// HasEnd reports whether the ride's end is known
+func (t EventTimes) HasEnd() bool {
+	return !t.End.IsZero()
+}
+
+// ResolveEventTimes resolves a ride's local date ("2006-01-02") and start time
+// ("15:04:05" or "15:04") in location. The end comes from the duration when
+// there is one, since it is exact even for rides that last past midnight, and
+// otherwise from the end time, which is taken to be on the next day when it
+// isn't after the start.
+//
+// A wall clock time skipped when the clocks go forward is read with the
+// offset from before the change, as RFC 5545 does, so 02:30 becomes 03:30.
+func ResolveEventTimes(location *time.Location, date, startTime, endTime string, durationMinutes int) (EventTimes, error) {
+	day, err := time.Parse("2006-01-02", strings.TrimSpace(date))
+	if err != nil {
+		return EventTimes{}, fmt.Errorf("invalid date %q: %w", date, err)
+	}
+
+	if strings.TrimSpace(startTime) == "" {
+		return EventTimes{}, ErrNoStartTime
+	}
+	start, ok := ParseWallClock(startTime)
+	if !ok {
+		return EventTimes{}, fmt.Errorf("invalid start time %q", startTime)
+	}
+
+	times := EventTimes{Start: atWallClock(day, start, location)}
+
+	if durationMinutes > 0 {
+		times.End = times.Start.Add(time.Duration(durationMinutes) * time.Minute)
+		return times, nil
+	}
+
+	if end, ok := ParseWallClock(endTime); ok {
+		endDay := day
+		if end <= start {
+			endDay = day.AddDate(0, 0, 1)
+		}
+		times.End = atWallClock(endDay, end, location)
+	}
+
+	return times, nil
+}
+
+// Times resolves the event's date, time and end in its city's time zone
+func (e Event) Times() (EventTimes, error) {
+	location, err := CityLocation(e.CityCode)
+	if err != nil {
+		return EventTimes{}, err
+	}
+	return ResolveEventTimes(location, e.Date, e.Time, e.Endtime, e.Eventduration)
+}
+
+// ParseWallClock parses "15:04:05" or "15:04" into the time since midnight
+func ParseWallClock(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// atWallClock is the instant the clocks in location read clock on day
+func atWallClock(day time.Time, clock time.Duration, location *time.Location) time.Time {
+	hours := int(clock / time.Hour)
+	minutes := int(clock % time.Hour / time.Minute)
+	seconds := int(clock % time.Minute / time.Second)
+	t := time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, seconds, 0, location)
+	if t.Hour() == hours && t.Minute() == minutes {
+		return t
+	}
+
+	// the time doesn't exist on that day, so apply the offset in effect
+	// before the clocks went forward
+	_, offset := t.Add(-12 * time.Hour).Zone()
+	wall := time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, seconds, 0, time.UTC)
+	return wall.Add(-time.Duration(offset) * time.Second).In(location)
+}
@@ -2,78 +2,92 @@ package scraper
 
 import (
 	"context"
-	_ "embed"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
-	"time"
+	"sync"
 
 	"google.golang.org/api/option"
 	"google.golang.org/api/transport"
+	htransport "google.golang.org/api/transport/http"
 )
 
-const addressScope = "https://www.googleapis.com/auth/maps-platform.geocode.address"
+const (
+	addressScope       = "https://www.googleapis.com/auth/maps-platform.geocode.address"
+	googleGeocoderName = "google"
+)
+
+// GoogleGeocoder uses the Google Geocoding v4beta address endpoint
+type GoogleGeocoder struct {
+	mu         sync.Mutex
+	httpClient *http.Client
+	base       http.RoundTripper
+}
+
+// NewGoogleGeocoder returns a Google geocoder. When httpClient is nil an
+// authenticated client is created from Application Default Credentials on
+// first use.
+func NewGoogleGeocoder(httpClient *http.Client) *GoogleGeocoder {
+	return &GoogleGeocoder{httpClient: httpClient}
+}
 
-var authenticatedClient *http.Client
+// NewGoogleGeocoderWithTransport returns a Google geocoder whose
+// Application Default Credentials client sends requests through base
+func NewGoogleGeocoderWithTransport(base http.RoundTripper) *GoogleGeocoder {
+	return &GoogleGeocoder{base: base}
+}
+
+func (g *GoogleGeocoder) Name() string {
+	return googleGeocoderName
+}
 
-func getAuthenticatedClient(ctx context.Context) (*http.Client, error) {
-	if authenticatedClient != nil {
-		return authenticatedClient, nil
+func (g *GoogleGeocoder) client(ctx context.Context) (*http.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.httpClient != nil {
+		return g.httpClient, nil
 	}
 
 	// Use Application Default Credentials (ADC) from the service account running on Cloud Run
 	// This will automatically use the credentials of the Cloud Run service account
 	clientOption := option.WithScopes(addressScope)
 
-	// Create HTTP client with ADC
-	httpClient, _, err := transport.NewHTTPClient(ctx, clientOption)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create authenticated HTTP client with ADC: %w", err)
+	// Create HTTP client with ADC, layered over the base transport if there is one
+	var httpClient *http.Client
+	if g.base != nil {
+		authTransport, err := htransport.NewTransport(ctx, g.base, clientOption)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create authenticated HTTP transport with ADC: %w", err)
+		}
+		httpClient = &http.Client{Transport: authTransport}
+	} else {
+		var err error
+		httpClient, _, err = transport.NewHTTPClient(ctx, clientOption)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create authenticated HTTP client with ADC: %w", err)
+		}
 	}
 
-	httpClient.Timeout = 15 * time.Second
-	authenticatedClient = httpClient
+	g.httpClient = httpClient
 
-	return authenticatedClient, nil
-
-}
-
-type CityDetails struct {
-	CityName string  `json:"cityName"`
-	State    string  `json:"state"`
-	NELat    float64 `json:"neLat"`
-	NELng    float64 `json:"neLng"`
-	SWLat    float64 `json:"swLat"`
-	SWLng    float64 `json:"swLng"`
-}
-
-//go:embed cities.json
-var citiesJSON []byte
-
-var cityMap map[string]CityDetails
-
-func init() {
-	if err := json.Unmarshal(citiesJSON, &cityMap); err != nil {
-		panic(fmt.Sprintf("failed to parse cities.json: %v", err))
-	}
+	return g.httpClient, nil
 }
 
-func GeocodeQuery(query, cityCode string) (float64, float64, error) {
-	ctx := context.Background()
-	client, err := getAuthenticatedClient(ctx)
+func (g *GoogleGeocoder) Geocode(ctx context.Context, query, cityCode string) (GeocodeResult, error) {
+	client, err := g.client(ctx)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get authenticated client: %w", err)
+		return GeocodeResult{}, fmt.Errorf("failed to get authenticated client: %w", err)
 	}
 
 	baseURL := "https://geocode.googleapis.com/v4beta/geocode/address/"
 
 	cityDetails := cityMap[cityCode]
 
-	var req *http.Request
-	req, err = http.NewRequest(http.MethodGet, baseURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
 	if err != nil {
-		return 0.0, 0.0, err
+		return GeocodeResult{}, err
 	}
 
 	q := req.URL.Query()
@@ -91,29 +105,38 @@ func GeocodeQuery(query, cityCode string) (float64, float64, error) {
 
 	res, err := client.Do(req)
 	if err != nil {
-		return 0.0, 0.0, err
+		return GeocodeResult{}, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		// bodyBtyes, _ := io.ReadAll(res.Body)
-		// fmt.Println(string(bodyBtyes))
-		return 0.0, 0.0, fmt.Errorf("Google Geocoding API returned non-OK status code %d", res.StatusCode)
+		return GeocodeResult{}, fmt.Errorf("Google Geocoding API returned non-OK status code %d", res.StatusCode)
 	}
 
 	var googleResponse GoogleGeocodeResponse
 	if err := json.NewDecoder(res.Body).Decode(&googleResponse); err != nil {
-		return 0.0, 0.0, fmt.Errorf("failed to decode Google geocoding response: %v", err)
+		return GeocodeResult{}, fmt.Errorf("failed to decode Google geocoding response: %v", err)
 	}
 
 	if len(googleResponse.Results) == 0 {
-		return 0.0, 0.0, fmt.Errorf("no results found for address: '%s' (GOOGLE API 'OK' status but empty results", query)
+		return GeocodeResult{}, fmt.Errorf("no results found for address: '%s' (GOOGLE API 'OK' status but empty results", query)
 	}
 
-	lat := googleResponse.Results[0].Location.Latitude
-	lng := googleResponse.Results[0].Location.Longitude
-
-	fmt.Println(lat, lng)
-
-	return lat, lng, nil
+	result := googleResponse.Results[0]
+
+	return GeocodeResult{
+		Latitude:         result.Location.Latitude,
+		Longitude:        result.Location.Longitude,
+		FormattedAddress: result.FormattedAddress,
+		PlaceID:          result.PlaceID,
+		Granularity:      result.Granularity,
+		Types:            result.Types,
+		Viewport: &BoundingBox{
+			NELat: result.Viewport.High.Latitude,
+			NELng: result.Viewport.High.Longitude,
+			SWLat: result.Viewport.Low.Latitude,
+			SWLng: result.Viewport.Low.Longitude,
+		},
+		Provider: googleGeocoderName,
+	}, nil
 }
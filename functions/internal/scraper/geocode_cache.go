@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ManualProvider marks geocode_cache entries pinned by an admin. They never
+// expire and are never overwritten by a geocoder.
+const ManualProvider = "manual"
+
+// NormalizeGeocodeQuery returns the geocode_cache key for a query
+func NormalizeGeocodeQuery(query string) string {
+	return strings.ToLower(strings.TrimSpace(query))
+}
+
+// CachingGeocoder answers from geocode_cache before falling back to another
+// geocoder, so API lookups share the scraper's cache and admin overrides
+type CachingGeocoder struct {
+	db       *sql.DB
+	geocoder Geocoder
+}
+
+func NewCachingGeocoder(db *sql.DB, geocoder Geocoder) *CachingGeocoder {
+	return &CachingGeocoder{db: db, geocoder: geocoder}
+}
+
+func (g *CachingGeocoder) Name() string {
+	return g.geocoder.Name()
+}
+
+func (g *CachingGeocoder) Geocode(ctx context.Context, query, cityCode string) (GeocodeResult, error) {
+	key := NormalizeGeocodeQuery(query)
+
+	cached, err := GetGeocodeCacheEntry(g.db, key)
+	if err != nil {
+		slog.Warn("failed to read geocode cache, geocoding directly", "query", key, "error", err)
+	}
+	if cached != nil && !cached.NeedsRefresh(time.Now()) {
+		return GeocodeResult{
+			Latitude:         cached.Latitude,
+			Longitude:        cached.Longitude,
+			FormattedAddress: cached.FormattedAddress,
+			PlaceID:          cached.PlaceID,
+			Granularity:      cached.Granularity,
+			Types:            cached.Types,
+			Provider:         cached.Provider,
+			Cached:           true,
+		}, nil
+	}
+
+	result, err := g.geocoder.Geocode(ctx, query, cityCode)
+	if err != nil {
+		return GeocodeResult{}, err
+	}
+
+	location := Location{
+		City:      cityCode,
+		Query:     key,
+		Latitude:  result.Latitude,
+		Longitude: result.Longitude,
+		Geocoded:  &result,
+	}
+	if err := BulkUpsertGeocodeData(g.db, []Location{location}); err != nil {
+		slog.Warn("failed to cache geocode result", "query", key, "error", err)
+	}
+
+	return result, nil
+}
+
+// GetGeocodeCacheEntry returns the cache entry for a normalized query, or nil
+// if there is none
+func GetGeocodeCacheEntry(db *sql.DB, key string) (*GeoCodeCached, error) {
+	var cached GeoCodeCached
+	var types, confidence, expiresAt string
+
+	err := db.QueryRow(`
+		SELECT lat, lng, COALESCE(provider, ''), COALESCE(place_id, ''),
+		       COALESCE(formatted_address, ''), COALESCE(granularity, ''), COALESCE(types, ''),
+		       COALESCE(confidence, ''), COALESCE(expires_at, '')
+		FROM geocode_cache
+		WHERE location_key = ?
+	`, key).Scan(
+		&cached.Latitude, &cached.Longitude, &cached.Provider, &cached.PlaceID,
+		&cached.FormattedAddress, &cached.Granularity, &types, &confidence, &expiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geocode cache: %w", err)
+	}
+
+	cached.Query = key
+	cached.setMetadata(types, confidence, expiresAt)
+
+	return &cached, nil
+}
+
+// setMetadata fills in the types, confidence and expiry columns as read from
+// the database
+func (c *GeoCodeCached) setMetadata(types, confidence, expiresAt string) {
+	if types != "" {
+		_ = json.Unmarshal([]byte(types), &c.Types)
+	}
+	c.Confidence = ConfidenceUnknown
+	if confidence != "" {
+		c.Confidence = Confidence(confidence)
+	}
+	if expiresAt != "" {
+		if t, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+			c.ExpiresAt = t
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+const fakeGeocoderName = "fake"
+
+// FakeGeocoder returns deterministic coordinates inside the city's bounds
+// without making any network calls. The same query always lands on the same
+// point, so scrapes and submissions can be run offline and compared.
+type FakeGeocoder struct {
+	// Results pins specific queries (matched case-insensitively) to fixed results
+	Results map[string]GeocodeResult
+	// Failures lists queries that return an error, to exercise fallbacks
+	Failures map[string]bool
+}
+
+func NewFakeGeocoder() *FakeGeocoder {
+	return &FakeGeocoder{
+		Results:  make(map[string]GeocodeResult),
+		Failures: make(map[string]bool),
+	}
+}
+
+func (g *FakeGeocoder) Name() string {
+	return fakeGeocoderName
+}
+
+func (g *FakeGeocoder) Geocode(_ context.Context, query, cityCode string) (GeocodeResult, error) {
+	key := strings.ToLower(strings.TrimSpace(query))
+
+	if g.Failures[key] {
+		return GeocodeResult{}, fmt.Errorf("no results found for address: '%s'", query)
+	}
+	if result, ok := g.Results[key]; ok {
+		result.Provider = fakeGeocoderName
+		return result, nil
+	}
+
+	city, ok := cityMap[cityCode]
+	if !ok {
+		return GeocodeResult{}, fmt.Errorf("unknown city %q", cityCode)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+
+	// Spread the hash over the middle of the bounding box so fake points stay
+	// well inside the city
+	latFrac := float64(sum&0xffffffff) / float64(0xffffffff)
+	lngFrac := float64(sum>>32) / float64(0xffffffff)
+	latSpan := city.NELat - city.SWLat
+	lngSpan := city.NELng - city.SWLng
+
+	return GeocodeResult{
+		Latitude:         city.SWLat + latSpan*(0.25+latFrac/2),
+		Longitude:        city.SWLng + lngSpan*(0.25+lngFrac/2),
+		FormattedAddress: fmt.Sprintf("%s, %s, %s", query, city.CityName, city.State),
+		PlaceID:          fmt.Sprintf("fake-%016x", sum),
+		Granularity:      "ROOFTOP",
+		Types:            []string{"street_address"},
+		Provider:         fakeGeocoderName,
+	}, nil
+}
@@ -0,0 +1,207 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	nominatimGeocoderName = "nominatim"
+	peliasGeocoderName    = "pelias"
+	osmUserAgent          = "cyclescene-geocoder/1.0"
+)
+
+// NominatimGeocoder queries a Nominatim compatible /search endpoint, such as a
+// locally run instance
+type NominatimGeocoder struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func NewNominatimGeocoder(httpClient *http.Client, baseURL string) *NominatimGeocoder {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &NominatimGeocoder{httpClient: httpClient, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (g *NominatimGeocoder) Name() string {
+	return nominatimGeocoderName
+}
+
+type nominatimResult struct {
+	PlaceID     json.Number `json:"place_id"`
+	Lat         string      `json:"lat"`
+	Lon         string      `json:"lon"`
+	DisplayName string      `json:"display_name"`
+	Category    string      `json:"category"`
+	Class       string      `json:"class"`
+	Type        string      `json:"type"`
+	AddressType string      `json:"addresstype"`
+	// BoundingBox is [south, north, west, east]
+	BoundingBox []string `json:"boundingbox"`
+}
+
+func (g *NominatimGeocoder) Geocode(ctx context.Context, query, cityCode string) (GeocodeResult, error) {
+	cityDetails := cityMap[cityCode]
+
+	params := url.Values{}
+	params.Set("q", queryWithCity(query, cityDetails))
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	params.Set("countrycodes", "us")
+	if cityDetails.CityName != "" {
+		// viewbox is left,top,right,bottom
+		params.Set("viewbox", fmt.Sprintf("%f,%f,%f,%f", cityDetails.SWLng, cityDetails.NELat, cityDetails.NELng, cityDetails.SWLat))
+	}
+
+	var results []nominatimResult
+	if err := osmGet(ctx, g.httpClient, g.baseURL+"/search?"+params.Encode(), &results); err != nil {
+		return GeocodeResult{}, fmt.Errorf("nominatim request failed: %w", err)
+	}
+
+	if len(results) == 0 {
+		return GeocodeResult{}, fmt.Errorf("no results found for address: '%s'", query)
+	}
+
+	result := results[0]
+	lat, err := strconv.ParseFloat(result.Lat, 64)
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("invalid latitude %q in nominatim response", result.Lat)
+	}
+	lng, err := strconv.ParseFloat(result.Lon, 64)
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("invalid longitude %q in nominatim response", result.Lon)
+	}
+
+	class := result.Category
+	if class == "" {
+		class = result.Class
+	}
+
+	geocoded := GeocodeResult{
+		Latitude:         lat,
+		Longitude:        lng,
+		FormattedAddress: result.DisplayName,
+		PlaceID:          result.PlaceID.String(),
+		Granularity:      result.AddressType,
+		Types:            []string{class, result.Type},
+		Provider:         nominatimGeocoderName,
+	}
+
+	if len(result.BoundingBox) == 4 {
+		var box [4]float64
+		valid := true
+		for i, v := range result.BoundingBox {
+			if box[i], err = strconv.ParseFloat(v, 64); err != nil {
+				valid = false
+			}
+		}
+		if valid {
+			geocoded.Viewport = &BoundingBox{SWLat: box[0], NELat: box[1], SWLng: box[2], NELng: box[3]}
+		}
+	}
+
+	return geocoded, nil
+}
+
+// PeliasGeocoder queries a Pelias /v1/search endpoint
+type PeliasGeocoder struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func NewPeliasGeocoder(httpClient *http.Client, baseURL string) *PeliasGeocoder {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &PeliasGeocoder{httpClient: httpClient, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (g *PeliasGeocoder) Name() string {
+	return peliasGeocoderName
+}
+
+type peliasResponse struct {
+	Features []struct {
+		// BBox is [min lng, min lat, max lng, max lat]
+		BBox     []float64 `json:"bbox"`
+		Geometry struct {
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			GID        string  `json:"gid"`
+			Layer      string  `json:"layer"`
+			Label      string  `json:"label"`
+			Confidence float64 `json:"confidence"`
+			MatchType  string  `json:"match_type"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+func (g *PeliasGeocoder) Geocode(ctx context.Context, query, cityCode string) (GeocodeResult, error) {
+	cityDetails := cityMap[cityCode]
+
+	params := url.Values{}
+	params.Set("text", queryWithCity(query, cityDetails))
+	params.Set("size", "1")
+	params.Set("boundary.country", "US")
+	if cityDetails.CityName != "" {
+		params.Set("focus.point.lat", strconv.FormatFloat((cityDetails.NELat+cityDetails.SWLat)/2, 'f', -1, 64))
+		params.Set("focus.point.lon", strconv.FormatFloat((cityDetails.NELng+cityDetails.SWLng)/2, 'f', -1, 64))
+	}
+
+	var response peliasResponse
+	if err := osmGet(ctx, g.httpClient, g.baseURL+"/v1/search?"+params.Encode(), &response); err != nil {
+		return GeocodeResult{}, fmt.Errorf("pelias request failed: %w", err)
+	}
+
+	if len(response.Features) == 0 || len(response.Features[0].Geometry.Coordinates) < 2 {
+		return GeocodeResult{}, fmt.Errorf("no results found for address: '%s'", query)
+	}
+
+	feature := response.Features[0]
+
+	var viewport *BoundingBox
+	if len(feature.BBox) == 4 {
+		viewport = &BoundingBox{SWLng: feature.BBox[0], SWLat: feature.BBox[1], NELng: feature.BBox[2], NELat: feature.BBox[3]}
+	}
+
+	return GeocodeResult{
+		Latitude:         feature.Geometry.Coordinates[1],
+		Longitude:        feature.Geometry.Coordinates[0],
+		FormattedAddress: feature.Properties.Label,
+		PlaceID:          feature.Properties.GID,
+		Granularity:      feature.Properties.Layer,
+		Types:            []string{feature.Properties.Layer, feature.Properties.MatchType},
+		Viewport:         viewport,
+		Provider:         peliasGeocoderName,
+	}, nil
+}
+
+func osmGet(ctx context.Context, client *http.Client, requestURL string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	// Nominatim's usage policy requires an identifying User-Agent
+	req.Header.Set("User-Agent", osmUserAgent)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("returned non-OK status code %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(target)
+}
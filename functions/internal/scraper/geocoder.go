@@ -0,0 +1,185 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GeocodeResult is a geocoded location as reported by a provider
+type GeocodeResult struct {
+	Latitude         float64
+	Longitude        float64
+	FormattedAddress string
+	PlaceID          string
+	// Granularity is the provider's description of how precise the match is,
+	// e.g. ROOFTOP for Google or house/street for OSM based providers
+	Granularity string
+	Types       []string
+	Viewport    *BoundingBox
+	Provider    string
+	// Cached reports the result was read from geocode_cache rather than
+	// looked up by the provider
+	Cached bool
+}
+
+// Confidence is how precisely a geocoding result pins down a ride's start
+type Confidence string
+
+const (
+	ConfidenceHigh    Confidence = "high"
+	ConfidenceMedium  Confidence = "medium"
+	ConfidenceLow     Confidence = "low"
+	ConfidenceUnknown Confidence = "unknown"
+)
+
+// Cache TTLs by confidence. Low confidence hits, which usually resolve to a
+// neighbourhood or the city centroid, are retried soon in case the provider
+// (or the ride's address) improves.
+var confidenceTTL = map[Confidence]time.Duration{
+	ConfidenceHigh:    180 * 24 * time.Hour,
+	ConfidenceMedium:  90 * 24 * time.Hour,
+	ConfidenceLow:     7 * 24 * time.Hour,
+	ConfidenceUnknown: 30 * 24 * time.Hour,
+}
+
+// TTL returns how long a cached result with this confidence stays fresh
+func (c Confidence) TTL() time.Duration {
+	if ttl, ok := confidenceTTL[c]; ok {
+		return ttl
+	}
+	return confidenceTTL[ConfidenceUnknown]
+}
+
+// Granularities across providers: Google's granularity, Nominatim's
+// addresstype and Pelias' layer
+var granularityConfidence = map[string]Confidence{
+	"rooftop":            ConfidenceHigh,
+	"house":              ConfidenceHigh,
+	"building":           ConfidenceHigh,
+	"amenity":            ConfidenceHigh,
+	"leisure":            ConfidenceHigh,
+	"park":               ConfidenceHigh,
+	"venue":              ConfidenceHigh,
+	"address":            ConfidenceHigh,
+	"range_interpolated": ConfidenceMedium,
+	"geometric_center":   ConfidenceMedium,
+	"road":               ConfidenceMedium,
+	"street":             ConfidenceMedium,
+	"approximate":        ConfidenceLow,
+	"neighbourhood":      ConfidenceLow,
+	"neighborhood":       ConfidenceLow,
+	"suburb":             ConfidenceLow,
+	"quarter":            ConfidenceLow,
+	"postcode":           ConfidenceLow,
+	"city":               ConfidenceLow,
+	"town":               ConfidenceLow,
+	"village":            ConfidenceLow,
+	"locality":           ConfidenceLow,
+	"localadmin":         ConfidenceLow,
+	"county":             ConfidenceLow,
+	"state":              ConfidenceLow,
+	"region":             ConfidenceLow,
+	"country":            ConfidenceLow,
+}
+
+// Result types that mean the provider only matched an area
+var areaTypes = []string{
+	"locality",
+	"political",
+	"postal_code",
+	"neighborhood",
+	"sublocality",
+	"administrative_area_level_1",
+	"administrative_area_level_2",
+	"country",
+}
+
+// Result types for a specific place, such as a park or a business
+var placeTypes = []string{
+	"establishment",
+	"point_of_interest",
+	"park",
+	"street_address",
+	"premise",
+}
+
+// Confidence rates a result from its granularity and types. A match on an
+// area such as a locality is always low confidence, whatever its granularity.
+func (r GeocodeResult) Confidence() Confidence {
+	isPlace := hasAnyType(r.Types, placeTypes)
+	if !isPlace && hasAnyType(r.Types, areaTypes) {
+		return ConfidenceLow
+	}
+
+	confidence, ok := granularityConfidence[strings.ToLower(r.Granularity)]
+	if !ok {
+		return ConfidenceUnknown
+	}
+
+	// Google reports GEOMETRIC_CENTER for parks and other places that have an
+	// area, which are exactly where rides meet
+	if confidence == ConfidenceMedium && isPlace {
+		return ConfidenceHigh
+	}
+	return confidence
+}
+
+func hasAnyType(types, wanted []string) bool {
+	return slices.ContainsFunc(types, func(t string) bool {
+		return slices.Contains(wanted, strings.ToLower(t))
+	})
+}
+
+// Geocoder turns a free-form address query into coordinates. cityCode biases
+// the search towards the city's bounds from cities.json.
+type Geocoder interface {
+	Name() string
+	Geocode(ctx context.Context, query, cityCode string) (GeocodeResult, error)
+}
+
+// NewGeocoderFromEnv picks a geocoder from GEOCODER_PROVIDER:
+//   - google (default): Google Geocoding API using Application Default Credentials
+//   - nominatim or pelias: a self-hosted instance at GEOCODER_URL
+//   - fake: deterministic coordinates inside the city, for tests and offline dev
+//
+// Requests are sent through base, such as a rate limiting transport, or
+// http.DefaultTransport when base is nil. The clients have no timeout of their
+// own, since it would count time spent waiting on base's rate limit; bound
+// each lookup with its context instead.
+func NewGeocoderFromEnv(base http.RoundTripper) (Geocoder, error) {
+	provider := strings.ToLower(os.Getenv("GEOCODER_PROVIDER"))
+	baseURL := os.Getenv("GEOCODER_URL")
+
+	switch provider {
+	case "", googleGeocoderName:
+		return NewGoogleGeocoderWithTransport(base), nil
+	case nominatimGeocoderName:
+		if baseURL == "" {
+			return nil, fmt.Errorf("GEOCODER_URL must be set for the nominatim geocoder")
+		}
+		return NewNominatimGeocoder(&http.Client{Transport: base}, baseURL), nil
+	case peliasGeocoderName:
+		if baseURL == "" {
+			return nil, fmt.Errorf("GEOCODER_URL must be set for the pelias geocoder")
+		}
+		return NewPeliasGeocoder(&http.Client{Transport: base}, baseURL), nil
+	case fakeGeocoderName:
+		return NewFakeGeocoder(), nil
+	default:
+		return nil, fmt.Errorf("unknown GEOCODER_PROVIDER %q", provider)
+	}
+}
+
+// queryWithCity appends the city and state to a query that doesn't already
+// name the city, for providers that only take a single search string
+func queryWithCity(query string, city CityDetails) string {
+	if city.CityName == "" || strings.Contains(strings.ToLower(query), strings.ToLower(city.CityName)) {
+		return query
+	}
+	return fmt.Sprintf("%s, %s, %s", query, city.CityName, city.State)
+}
@@ -0,0 +1,581 @@
+package scraper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICalEvent is a VEVENT from an iCalendar (RFC 5545) feed
+type ICalEvent struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	URL          string
+	Organizer    string
+	Status       string
+	Start        icalTime
+	End          icalTime
+	Duration     time.Duration
+	RRule        *RecurrenceRule
+	RDates       []icalTime
+	ExDates      []icalTime
+	RecurrenceID *icalTime
+}
+
+// ICalOccurrence is a single instance of an event after recurrence expansion
+type ICalOccurrence struct {
+	Event     *ICalEvent
+	Start     time.Time
+	End       time.Time
+	AllDay    bool
+	Cancelled bool
+}
+
+// Calendar is a parsed VCALENDAR
+type Calendar struct {
+	Name   string
+	Events []ICalEvent
+}
+
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type icalComponent struct {
+	Name       string
+	Properties []icalProperty
+	Children   []*icalComponent
+}
+
+func (c *icalComponent) property(name string) (icalProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return icalProperty{}, false
+}
+
+func (c *icalComponent) text(name string) string {
+	p, ok := c.property(name)
+	if !ok {
+		return ""
+	}
+	return unescapeICalText(p.Value)
+}
+
+// ParseICalendar reads an iCalendar feed. Floating times and all-day dates are
+// interpreted in defaultLoc; TZIDs are resolved from the IANA database first
+// and then from the feed's own VTIMEZONE definitions.
+func ParseICalendar(r io.Reader, defaultLoc *time.Location) (*Calendar, error) {
+	root, err := parseICalComponents(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var vcalendar *icalComponent
+	for _, child := range root.Children {
+		if child.Name == "VCALENDAR" {
+			vcalendar = child
+			break
+		}
+	}
+	if vcalendar == nil {
+		return nil, fmt.Errorf("no VCALENDAR found in feed")
+	}
+
+	zones := map[string]zoneResolver{}
+	for _, child := range vcalendar.Children {
+		if child.Name != "VTIMEZONE" {
+			continue
+		}
+		tz, err := parseVTimezone(child)
+		if err != nil {
+			return nil, err
+		}
+		zones[tz.id] = tz
+	}
+
+	calendar := &Calendar{Name: vcalendar.text("X-WR-CALNAME")}
+	parser := icalTimeParser{defaultLoc: defaultLoc, zones: zones}
+
+	for _, child := range vcalendar.Children {
+		if child.Name != "VEVENT" {
+			continue
+		}
+		event, err := parser.parseEvent(child)
+		if err != nil {
+			return nil, err
+		}
+		calendar.Events = append(calendar.Events, event)
+	}
+
+	return calendar, nil
+}
+
+// Expand returns every occurrence that starts within [from, to), sorted by start time
+func (c *Calendar) Expand(from, to time.Time) []ICalOccurrence {
+	// Instances that were moved or cancelled individually, keyed by UID and
+	// the original start of the instance they replace
+	overridden := map[string]map[int64]bool{}
+	for i := range c.Events {
+		event := &c.Events[i]
+		if event.RecurrenceID == nil {
+			continue
+		}
+		if overridden[event.UID] == nil {
+			overridden[event.UID] = map[int64]bool{}
+		}
+		overridden[event.UID][event.RecurrenceID.instant().Unix()] = true
+	}
+
+	var occurrences []ICalOccurrence
+	for i := range c.Events {
+		event := &c.Events[i]
+		for _, start := range event.instances(to) {
+			startInstant := start.instant()
+			if event.RecurrenceID == nil && overridden[event.UID][startInstant.Unix()] {
+				continue
+			}
+			if startInstant.Before(from) || !startInstant.Before(to) {
+				continue
+			}
+			occurrences = append(occurrences, ICalOccurrence{
+				Event:     event,
+				Start:     startInstant,
+				End:       event.endFor(start),
+				AllDay:    start.allDay,
+				Cancelled: strings.EqualFold(event.Status, "CANCELLED"),
+			})
+		}
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences
+}
+
+// instances returns the start of every instance of the event that begins before until
+func (e *ICalEvent) instances(until time.Time) []icalTime {
+	var starts []icalTime
+	if e.RRule != nil {
+		for _, wall := range e.RRule.expand(e.Start, until) {
+			starts = append(starts, icalTime{wall: wall, zone: e.Start.zone, allDay: e.Start.allDay})
+		}
+	} else {
+		starts = append(starts, e.Start)
+	}
+
+	for _, rdate := range e.RDates {
+		if rdate.allDay || e.Start.allDay {
+			rdate = icalTime{wall: dateWithClock(rdate.wall, e.Start.wall), zone: e.Start.zone, allDay: e.Start.allDay}
+		}
+		starts = append(starts, rdate)
+	}
+
+	// Date-only EXDATEs remove every instance on that day
+	excludedInstants := map[int64]bool{}
+	excludedDates := map[string]bool{}
+	for _, exdate := range e.ExDates {
+		if exdate.allDay {
+			excludedDates[exdate.wall.Format("20060102")] = true
+			continue
+		}
+		excludedInstants[exdate.instant().Unix()] = true
+	}
+
+	var result []icalTime
+	seen := map[int64]bool{}
+	for _, start := range starts {
+		key := start.instant().Unix()
+		if seen[key] || excludedInstants[key] || excludedDates[start.wall.Format("20060102")] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, start)
+	}
+	return result
+}
+
+// endFor returns the end instant of the instance that starts at start
+func (e *ICalEvent) endFor(start icalTime) time.Time {
+	if !e.End.wall.IsZero() {
+		// Keep the wall-clock length so instances on either side of a DST
+		// change end at the same local time
+		length := e.End.wall.Sub(e.Start.wall)
+		if e.End.zone == e.Start.zone {
+			return icalTime{wall: start.wall.Add(length), zone: start.zone, allDay: start.allDay}.instant()
+		}
+		return start.instant().Add(e.End.instant().Sub(e.Start.instant()))
+	}
+	if e.Duration > 0 {
+		return start.instant().Add(e.Duration)
+	}
+	if start.allDay {
+		return icalTime{wall: start.wall.AddDate(0, 0, 1), zone: start.zone, allDay: true}.instant()
+	}
+	return start.instant()
+}
+
+// parseICalComponents unfolds content lines and builds the component tree
+func parseICalComponents(r io.Reader) (*icalComponent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read iCalendar feed: %w", err)
+	}
+
+	root := &icalComponent{}
+	stack := []*icalComponent{root}
+
+	for n, line := range lines {
+		prop, err := parseICalProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		current := stack[len(stack)-1]
+		switch prop.Name {
+		case "BEGIN":
+			child := &icalComponent{Name: strings.ToUpper(prop.Value)}
+			current.Children = append(current.Children, child)
+			stack = append(stack, child)
+		case "END":
+			if len(stack) == 1 || current.Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", n+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("unterminated %s component", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// parseICalProperty splits a content line into its name, parameters and value
+func parseICalProperty(line string) (icalProperty, error) {
+	prop := icalProperty{Params: map[string]string{}}
+
+	inQuotes := false
+	nameEnd, valueStart := -1, -1
+	for i, ch := range line {
+		if ch == '"' {
+			inQuotes = !inQuotes
+		}
+		if inQuotes {
+			continue
+		}
+		if ch == ';' && nameEnd == -1 {
+			nameEnd = i
+		}
+		if ch == ':' {
+			valueStart = i
+			break
+		}
+	}
+	if valueStart == -1 {
+		return prop, fmt.Errorf("malformed content line %q", line)
+	}
+	if nameEnd == -1 {
+		nameEnd = valueStart
+	}
+
+	prop.Name = strings.ToUpper(line[:nameEnd])
+	prop.Value = line[valueStart+1:]
+
+	if nameEnd < valueStart {
+		for _, param := range splitOutsideQuotes(line[nameEnd+1:valueStart], ';') {
+			key, value, found := strings.Cut(param, "=")
+			if !found {
+				continue
+			}
+			prop.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return prop, nil
+}
+
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, ch := range s {
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case ch == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// icalTime is a wall-clock time plus the zone that turns it into an instant.
+// Wall clocks are stored as UTC time.Time values so that recurrence rules can
+// step through local dates without DST getting in the way.
+type icalTime struct {
+	wall   time.Time
+	zone   zoneResolver
+	allDay bool
+}
+
+func (t icalTime) instant() time.Time {
+	return t.zone.resolve(t.wall)
+}
+
+type zoneResolver interface {
+	resolve(wall time.Time) time.Time
+}
+
+// locationZone resolves wall clocks with an IANA time zone
+type locationZone struct {
+	loc *time.Location
+}
+
+func (z locationZone) resolve(wall time.Time) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, z.loc)
+}
+
+type icalTimeParser struct {
+	defaultLoc *time.Location
+	zones      map[string]zoneResolver
+}
+
+func (p icalTimeParser) zoneFor(tzid string) zoneResolver {
+	if tzid == "" {
+		return locationZone{loc: p.defaultLoc}
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return locationZone{loc: loc}
+	}
+	if zone, ok := p.zones[tzid]; ok {
+		return zone
+	}
+	return locationZone{loc: p.defaultLoc}
+}
+
+// parseTime parses a DATE or DATE-TIME value using the property's TZID
+func (p icalTimeParser) parseTime(value string, params map[string]string) (icalTime, error) {
+	return p.parseTimeInZone(value, params["VALUE"], p.zoneFor(params["TZID"]))
+}
+
+func (p icalTimeParser) parseTimeInZone(value, valueType string, zone zoneResolver) (icalTime, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.EqualFold(valueType, "DATE") || len(value) == 8 {
+		wall, err := time.Parse("20060102", value)
+		if err != nil {
+			return icalTime{}, fmt.Errorf("invalid date %q: %w", value, err)
+		}
+		return icalTime{wall: wall, zone: locationZone{loc: p.defaultLoc}, allDay: true}, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		wall, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return icalTime{}, fmt.Errorf("invalid date-time %q: %w", value, err)
+		}
+		return icalTime{wall: wall, zone: locationZone{loc: time.UTC}}, nil
+	}
+
+	wall, err := time.Parse("20060102T150405", value)
+	if err != nil {
+		return icalTime{}, fmt.Errorf("invalid date-time %q: %w", value, err)
+	}
+	return icalTime{wall: wall, zone: zone}, nil
+}
+
+// parseTimeList parses a comma separated EXDATE or RDATE value
+func (p icalTimeParser) parseTimeList(prop icalProperty) ([]icalTime, error) {
+	var times []icalTime
+	for _, value := range strings.Split(prop.Value, ",") {
+		if value == "" {
+			continue
+		}
+		if strings.EqualFold(prop.Params["VALUE"], "PERIOD") {
+			value, _, _ = strings.Cut(value, "/")
+		}
+		t, err := p.parseTime(value, prop.Params)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+func (p icalTimeParser) parseEvent(c *icalComponent) (ICalEvent, error) {
+	event := ICalEvent{
+		UID:         c.text("UID"),
+		Summary:     c.text("SUMMARY"),
+		Description: c.text("DESCRIPTION"),
+		Location:    c.text("LOCATION"),
+		URL:         c.text("URL"),
+		Status:      c.text("STATUS"),
+	}
+
+	if organizer, ok := c.property("ORGANIZER"); ok {
+		event.Organizer = organizer.Params["CN"]
+		if event.Organizer == "" {
+			event.Organizer = strings.TrimPrefix(strings.TrimPrefix(organizer.Value, "mailto:"), "MAILTO:")
+		}
+	}
+
+	dtstart, ok := c.property("DTSTART")
+	if !ok {
+		return event, fmt.Errorf("VEVENT %q has no DTSTART", event.UID)
+	}
+	start, err := p.parseTime(dtstart.Value, dtstart.Params)
+	if err != nil {
+		return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+	}
+	event.Start = start
+
+	if dtend, ok := c.property("DTEND"); ok {
+		end, err := p.parseTime(dtend.Value, dtend.Params)
+		if err != nil {
+			return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+		}
+		event.End = end
+	} else if duration, ok := c.property("DURATION"); ok {
+		d, err := parseICalDuration(duration.Value)
+		if err != nil {
+			return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+		}
+		event.Duration = d
+	}
+
+	if rrule, ok := c.property("RRULE"); ok {
+		rule, err := parseRecurrenceRule(rrule.Value, p)
+		if err != nil {
+			return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+		}
+		event.RRule = rule
+	}
+
+	for _, prop := range c.Properties {
+		switch prop.Name {
+		case "EXDATE":
+			times, err := p.parseTimeList(prop)
+			if err != nil {
+				return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+			}
+			event.ExDates = append(event.ExDates, times...)
+		case "RDATE":
+			times, err := p.parseTimeList(prop)
+			if err != nil {
+				return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+			}
+			event.RDates = append(event.RDates, times...)
+		case "RECURRENCE-ID":
+			t, err := p.parseTime(prop.Value, prop.Params)
+			if err != nil {
+				return event, fmt.Errorf("VEVENT %q: %w", event.UID, err)
+			}
+			event.RecurrenceID = &t
+		}
+	}
+
+	return event, nil
+}
+
+// parseICalDuration parses an RFC 5545 DURATION such as PT1H30M or P1D
+func parseICalDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	negative := false
+	if strings.HasPrefix(value, "-") {
+		negative = true
+		s = strings.TrimPrefix(value, "-P")
+	}
+
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, ch := range s {
+		switch {
+		case ch >= '0' && ch <= '9':
+			num += string(ch)
+		case ch == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			num = ""
+			switch {
+			case ch == 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case ch == 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case ch == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case ch == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case ch == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// dateWithClock returns the date of day with the time of day of clock
+func dateWithClock(day, clock time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+}
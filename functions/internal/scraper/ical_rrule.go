@@ -0,0 +1,516 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrencePeriods bounds expansion of rules with no COUNT or UNTIL
+// whose BY* parts never match
+const maxRecurrencePeriods = 10000
+
+// RecurrenceRule is a parsed RRULE. Only the parts bike club calendars use
+// are supported: FREQ DAILY through YEARLY with INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH and BYSETPOS.
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *icalTime
+	ByDay      []weekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+}
+
+// weekdayNum is a BYDAY entry such as MO, 2SU or -1FR
+type weekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRecurrenceRule(value string, parser icalTimeParser) (*RecurrenceRule, error) {
+	rule := &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(part, "=")
+		if !found {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid RRULE INTERVAL %q", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid RRULE COUNT %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parser.parseTimeInZone(val, "", locationZone{loc: parser.defaultLoc})
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE UNTIL: %w", err)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				wd, err := parseWeekdayNum(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			nums, err := parseIntList(val, -31, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE BYMONTHDAY: %w", err)
+			}
+			rule.ByMonthDay = nums
+		case "BYMONTH":
+			nums, err := parseIntList(val, 1, 12)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE BYMONTH: %w", err)
+			}
+			for _, n := range nums {
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "BYSETPOS":
+			nums, err := parseIntList(val, -366, 366)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE BYSETPOS: %w", err)
+			}
+			rule.BySetPos = nums
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported RRULE FREQ %q", rule.Freq)
+	}
+
+	return rule, nil
+}
+
+func parseWeekdayNum(value string) (weekdayNum, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid RRULE BYDAY %q", value)
+	}
+
+	weekday, ok := icalWeekdays[value[len(value)-2:]]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid RRULE BYDAY %q", value)
+	}
+
+	wd := weekdayNum{Weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 {
+			return weekdayNum{}, fmt.Errorf("invalid RRULE BYDAY %q", value)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var nums []int
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", s)
+		}
+		nums = append(nums, n)
+	}
+	return nums, nil
+}
+
+// expand returns the wall-clock start of every instance of the rule, beginning
+// with dtstart, that starts before until
+func (r *RecurrenceRule) expand(dtstart icalTime, until time.Time) []time.Time {
+	var starts []time.Time
+	count := 0
+	period := periodStart(r.Freq, dtstart.wall)
+
+	for i := 0; i < maxRecurrencePeriods; i++ {
+		for _, day := range r.candidates(period, dtstart.wall) {
+			wall := dateWithClock(day, dtstart.wall)
+			if wall.Before(dtstart.wall) {
+				continue
+			}
+			if r.Until != nil && r.pastUntil(wall, dtstart) {
+				return starts
+			}
+
+			instance := icalTime{wall: wall, zone: dtstart.zone, allDay: dtstart.allDay}
+			if !instance.instant().Before(until) {
+				return starts
+			}
+
+			starts = append(starts, wall)
+			count++
+			if r.Count > 0 && count >= r.Count {
+				return starts
+			}
+		}
+		period = r.nextPeriod(period)
+	}
+
+	return starts
+}
+
+func (r *RecurrenceRule) pastUntil(wall time.Time, dtstart icalTime) bool {
+	if r.Until.allDay {
+		// A date-only UNTIL includes every instance on that date
+		return wall.Truncate(24 * time.Hour).After(r.Until.wall)
+	}
+	instance := icalTime{wall: wall, zone: dtstart.zone}
+	return instance.instant().After(r.Until.instant())
+}
+
+func periodStart(freq string, wall time.Time) time.Time {
+	day := time.Date(wall.Year(), wall.Month(), wall.Day(), 0, 0, 0, 0, time.UTC)
+	switch freq {
+	case "WEEKLY":
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "MONTHLY":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "YEARLY":
+		return time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func (r *RecurrenceRule) nextPeriod(period time.Time) time.Time {
+	switch r.Freq {
+	case "WEEKLY":
+		return period.AddDate(0, 0, 7*r.Interval)
+	case "MONTHLY":
+		return period.AddDate(0, r.Interval, 0)
+	case "YEARLY":
+		return period.AddDate(r.Interval, 0, 0)
+	default:
+		return period.AddDate(0, 0, r.Interval)
+	}
+}
+
+// candidates returns the sorted days within a period that the rule selects
+func (r *RecurrenceRule) candidates(period, dtstart time.Time) []time.Time {
+	var days []time.Time
+
+	switch r.Freq {
+	case "DAILY":
+		if r.matchesMonth(period) && r.matchesWeekday(period) && r.matchesMonthDay(period) {
+			days = append(days, period)
+		}
+
+	case "WEEKLY":
+		weekdays := r.ByDay
+		if len(weekdays) == 0 {
+			weekdays = []weekdayNum{{Weekday: dtstart.Weekday()}}
+		}
+		for i := 0; i < 7; i++ {
+			day := period.AddDate(0, 0, i)
+			if !r.matchesMonth(day) {
+				continue
+			}
+			for _, wd := range weekdays {
+				if day.Weekday() == wd.Weekday {
+					days = append(days, day)
+					break
+				}
+			}
+		}
+
+	case "MONTHLY":
+		if r.matchesMonth(period) {
+			days = r.daysInMonth(period, dtstart)
+		}
+
+	case "YEARLY":
+		months := r.ByMonth
+		if len(months) == 0 {
+			if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+				months = []time.Month{dtstart.Month()}
+			} else {
+				months = []time.Month{time.January, time.February, time.March, time.April, time.May, time.June,
+					time.July, time.August, time.September, time.October, time.November, time.December}
+			}
+		}
+		for _, month := range months {
+			monthStart := time.Date(period.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+			days = append(days, r.daysInMonth(monthStart, dtstart)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return r.applySetPos(days)
+}
+
+// daysInMonth applies BYMONTHDAY and BYDAY within a single month. Ordinal
+// BYDAY entries such as 2SU count from the start or end of that month.
+func (r *RecurrenceRule) daysInMonth(monthStart, dtstart time.Time) []time.Time {
+	last := monthStart.AddDate(0, 1, -1).Day()
+
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		if dtstart.Day() > last {
+			return nil
+		}
+		return []time.Time{monthStart.AddDate(0, 0, dtstart.Day()-1)}
+	}
+
+	var days []time.Time
+	for d := 1; d <= last; d++ {
+		day := monthStart.AddDate(0, 0, d-1)
+		if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(day) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !r.matchesWeekdayInMonth(day, last) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+func (r *RecurrenceRule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if day.Month() == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecurrenceRule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if day.Weekday() == wd.Weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecurrenceRule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, n := range r.ByMonthDay {
+		if n == day.Day() || (n < 0 && last+n+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecurrenceRule) matchesWeekdayInMonth(day time.Time, lastDay int) bool {
+	for _, wd := range r.ByDay {
+		if day.Weekday() != wd.Weekday {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (day.Day()-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (lastDay-day.Day())/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecurrenceRule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) {
+			selected = append(selected, days[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	return selected
+}
+
+// vtimezone resolves wall clocks using the STANDARD and DAYLIGHT observances
+// of a VTIMEZONE, for feeds whose TZIDs are not IANA names
+type vtimezone struct {
+	id          string
+	observances []tzObservance
+}
+
+type tzObservance struct {
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	rule       *RecurrenceRule
+	rdates     []time.Time
+}
+
+func parseVTimezone(c *icalComponent) (*vtimezone, error) {
+	tz := &vtimezone{id: c.text("TZID")}
+	if tz.id == "" {
+		return nil, fmt.Errorf("VTIMEZONE has no TZID")
+	}
+
+	// Observance onsets are local times in the offset that precedes them
+	floating := icalTimeParser{defaultLoc: time.UTC}
+
+	for _, child := range c.Children {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+
+		var obs tzObservance
+		var err error
+
+		dtstart, ok := child.property("DTSTART")
+		if !ok {
+			return nil, fmt.Errorf("VTIMEZONE %q observance has no DTSTART", tz.id)
+		}
+		start, err := floating.parseTimeInZone(dtstart.Value, "", locationZone{loc: time.UTC})
+		if err != nil {
+			return nil, fmt.Errorf("VTIMEZONE %q: %w", tz.id, err)
+		}
+		obs.start = start.wall
+
+		if obs.offsetFrom, err = parseUTCOffset(child.text("TZOFFSETFROM")); err != nil {
+			return nil, fmt.Errorf("VTIMEZONE %q: %w", tz.id, err)
+		}
+		if obs.offsetTo, err = parseUTCOffset(child.text("TZOFFSETTO")); err != nil {
+			return nil, fmt.Errorf("VTIMEZONE %q: %w", tz.id, err)
+		}
+
+		if rrule, ok := child.property("RRULE"); ok {
+			if obs.rule, err = parseRecurrenceRule(rrule.Value, floating); err != nil {
+				return nil, fmt.Errorf("VTIMEZONE %q: %w", tz.id, err)
+			}
+		}
+		for _, prop := range child.Properties {
+			if prop.Name != "RDATE" {
+				continue
+			}
+			times, err := floating.parseTimeList(prop)
+			if err != nil {
+				return nil, fmt.Errorf("VTIMEZONE %q: %w", tz.id, err)
+			}
+			for _, t := range times {
+				obs.rdates = append(obs.rdates, t.wall)
+			}
+		}
+
+		tz.observances = append(tz.observances, obs)
+	}
+
+	if len(tz.observances) == 0 {
+		return nil, fmt.Errorf("VTIMEZONE %q has no observances", tz.id)
+	}
+	return tz, nil
+}
+
+// resolve finds the observance with the latest onset at or before the wall
+// clock and applies its offset
+func (tz *vtimezone) resolve(wall time.Time) time.Time {
+	offset := tz.observances[0].offsetFrom
+	var latest time.Time
+
+	for _, obs := range tz.observances {
+		if onset, ok := obs.latestOnset(wall); ok && onset.After(latest) {
+			latest = onset
+			offset = obs.offsetTo
+		}
+	}
+
+	return wall.Add(-time.Duration(offset) * time.Second).In(time.FixedZone(tz.id, offset))
+}
+
+func (obs tzObservance) latestOnset(wall time.Time) (time.Time, bool) {
+	var latest time.Time
+	found := false
+
+	onsets := obs.rdates
+	if obs.rule != nil {
+		start := icalTime{wall: obs.start, zone: locationZone{loc: time.UTC}}
+		onsets = append(onsets, obs.rule.expand(start, wall.Add(time.Second))...)
+	} else {
+		onsets = append(onsets, obs.start)
+	}
+
+	for _, onset := range onsets {
+		if !onset.After(wall) && (!found || onset.After(latest)) {
+			latest = onset
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// parseUTCOffset parses a TZOFFSETFROM/TZOFFSETTO value such as -0800 into seconds
+func parseUTCOffset(value string) (int, error) {
+	if len(value) != 5 && len(value) != 7 {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+
+	hours, err := strconv.Atoi(value[1:3])
+	if err != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	minutes, err := strconv.Atoi(value[3:5])
+	if err != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	seconds := 0
+	if len(value) == 7 {
+		if seconds, err = strconv.Atoi(value[5:7]); err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", value)
+		}
+	}
+
+	return sign * (hours*3600 + minutes*60 + seconds), nil
+}
@@ -0,0 +1,159 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/netguard"
+)
+
+const icalSourceName = "iCal"
+
+// GroupCalendar is a ride group that publishes its rides as an iCalendar feed
+type GroupCalendar struct {
+	GroupCode string
+	GroupName string
+	CityCode  string
+	URL       string
+}
+
+// ICalSource imports rides from a ride group's iCalendar feed. Group owners
+// supply the URL, so only http(s) and webcal feeds are fetched, and the HTTP
+// client should refuse internal addresses like netguard's transport does.
+type ICalSource struct {
+	httpClient *http.Client
+	calendar   GroupCalendar
+	// readFeed reads a feed once its URL has been checked; tests swap it for
+	// one that reads fixture files
+	readFeed func(ctx context.Context, feedURL string) (io.ReadCloser, error)
+}
+
+func NewICalSource(httpClient *http.Client, calendar GroupCalendar) *ICalSource {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second, Transport: netguard.NewTransport()}
+	}
+	s := &ICalSource{httpClient: httpClient, calendar: calendar}
+	s.readFeed = s.fetch
+	return s
+}
+
+func (s *ICalSource) Name() string {
+	return icalSourceName
+}
+
+// Owns only claims rides imported from this group's feed, since every group
+// calendar shares the iCal source name
+func (s *ICalSource) Owns(stored StoredEvent) bool {
+	return stored.Source == icalSourceName && stored.GroupCode == s.calendar.GroupCode
+}
+
+// FetchEvents expands the feed's recurring events and returns every
+// occurrence that starts on a day within the window
+func (s *ICalSource) FetchEvents(ctx context.Context, window Window) ([]Event, error) {
+	body, err := s.open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar for group %s: %w", s.calendar.GroupCode, err)
+	}
+	defer body.Close()
+
+	location := window.Start.Location()
+	calendar, err := ParseICalendar(body, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse calendar for group %s: %w", s.calendar.GroupCode, err)
+	}
+
+	occurrences := calendar.Expand(window.Start, window.End.AddDate(0, 0, 1))
+
+	events := make([]Event, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		events = append(events, s.toEvent(occurrence, location))
+	}
+	return events, nil
+}
+
+// open checks the feed URL the same way group registration does and reads
+// the feed, fetching webcal feeds over https
+func (s *ICalSource) open(ctx context.Context) (io.ReadCloser, error) {
+	u, err := url.Parse(strings.TrimSpace(s.calendar.URL))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid calendar URL %q", s.calendar.URL)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	case "webcal":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported calendar URL scheme %q", u.Scheme)
+	}
+
+	return s.readFeed(ctx, u.String())
+}
+
+// fetch downloads a feed with the source's HTTP client
+func (s *ICalSource) fetch(ctx context.Context, feedURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("calendar request returned status %d", res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+// toEvent maps an occurrence onto the row shape shared with Shift2Bikes rides
+func (s *ICalSource) toEvent(occurrence ICalOccurrence, location *time.Location) Event {
+	ical := occurrence.Event
+	start := occurrence.Start.In(location)
+	end := occurrence.End.In(location)
+
+	event := Event{
+		ID:        s.eventID(ical.UID),
+		Title:     ical.Summary,
+		Address:   ical.Location,
+		Details:   ical.Description,
+		Date:      start.Format("2006-01-02"),
+		Cancelled: occurrence.Cancelled,
+		Organizer: ical.Organizer,
+		Weburl:    ical.URL,
+		Shareable: ical.URL,
+		GroupCode: s.calendar.GroupCode,
+	}
+
+	if event.Organizer == "" {
+		event.Organizer = s.calendar.GroupName
+	}
+
+	if occurrence.AllDay {
+		event.Timedetails = "All day"
+		return event
+	}
+
+	event.Time = start.Format("15:04:05")
+	if end.After(start) {
+		event.Endtime = end.Format("15:04:05")
+		event.Eventduration = int(end.Sub(start).Minutes())
+	}
+	return event
+}
+
+// eventID derives a stable ride ID from the group and the feed's UID, so the
+// same event keeps its row across scrapes
+func (s *ICalSource) eventID(uid string) string {
+	sum := sha1.Sum([]byte(s.calendar.GroupCode + "|" + uid))
+	return "ical-" + hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newFixtureICalSource returns a source whose feeds are read from testdata,
+// using the last element of the feed URL as the fixture name
+func newFixtureICalSource(feedURL string) (*ICalSource, *[]string) {
+	var read []string
+	s := NewICalSource(nil, GroupCalendar{GroupCode: "night-riders", GroupName: "Night Riders", URL: feedURL})
+	s.readFeed = func(_ context.Context, feedURL string) (io.ReadCloser, error) {
+		read = append(read, feedURL)
+		return os.Open(filepath.Join("testdata", path.Base(feedURL)))
+	}
+	return s, &read
+}
+
+func marchWindow(t *testing.T) Window {
+	t.Helper()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+	return Window{
+		Start: time.Date(2025, time.March, 1, 0, 0, 0, 0, loc),
+		End:   time.Date(2025, time.March, 31, 0, 0, 0, 0, loc),
+	}
+}
+
+func TestICalSourceFetchEvents(t *testing.T) {
+	s, read := newFixtureICalSource("webcal://example.com/ical_weekly_count.ics")
+	events, err := s.FetchEvents(context.Background(), marchWindow(t))
+	if err != nil {
+		t.Fatalf("FetchEvents: %v", err)
+	}
+
+	// webcal feeds are fetched over https
+	if want := []string{"https://example.com/ical_weekly_count.ics"}; !slices.Equal(*read, want) {
+		t.Errorf("read %q, want %q", *read, want)
+	}
+
+	var got []string
+	for _, event := range events {
+		got = append(got, event.Date+" "+event.Time+" "+event.Title)
+	}
+	want := []string{
+		"2025-03-06 18:30:00 Thursday Night Ride",
+		"2025-03-13 18:30:00 Thursday Night Ride",
+		"2025-03-20 18:30:00 Thursday Night Ride",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("FetchEvents() = %q, want %q", got, want)
+	}
+}
+
+func TestICalSourceRejectsLocalFeeds(t *testing.T) {
+	for _, feedURL := range []string{
+		"file:///etc/passwd",
+		"/etc/passwd",
+		"testdata/ical_weekly_count.ics",
+		"ftp://example.com/rides.ics",
+		"https:///rides.ics",
+	} {
+		t.Run(feedURL, func(t *testing.T) {
+			s, read := newFixtureICalSource(feedURL)
+			if _, err := s.FetchEvents(context.Background(), marchWindow(t)); err == nil {
+				t.Fatal("FetchEvents succeeded, want error")
+			}
+			if len(*read) > 0 {
+				t.Errorf("read %q, want no feed read", *read)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func parseICalFixture(t *testing.T, name string) *Calendar {
+	t.Helper()
+
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer f.Close()
+
+	calendar, err := ParseICalendar(f, loc)
+	if err != nil {
+		t.Fatalf("ParseICalendar(%s): %v", name, err)
+	}
+	return calendar
+}
+
+// formatOccurrence renders an occurrence as "start/end summary" in UTC
+func formatOccurrence(o ICalOccurrence) string {
+	s := fmt.Sprintf("%s/%s %s", o.Start.UTC().Format(time.RFC3339), o.End.UTC().Format(time.RFC3339), o.Event.Summary)
+	if o.Cancelled {
+		s += " (cancelled)"
+	}
+	return s
+}
+
+func TestCalendarExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    []string
+	}{
+		{
+			// Wall clocks hold across the March DST change
+			name:    "weekly with COUNT",
+			fixture: "ical_weekly_count.ics",
+			want: []string{
+				"2025-03-07T02:30:00Z/2025-03-07T04:00:00Z Thursday Night Ride",
+				"2025-03-14T01:30:00Z/2025-03-14T03:00:00Z Thursday Night Ride",
+				"2025-03-21T01:30:00Z/2025-03-21T03:00:00Z Thursday Night Ride",
+			},
+		},
+		{
+			// The Apr 17 instance starts after UNTIL
+			name:    "BYDAY with UNTIL",
+			fixture: "ical_until_byday.ics",
+			want: []string{
+				"2025-04-01T14:00:00Z/2025-04-01T15:00:00Z Coffee Spin",
+				"2025-04-03T14:00:00Z/2025-04-03T15:00:00Z Coffee Spin",
+				"2025-04-08T14:00:00Z/2025-04-08T15:00:00Z Coffee Spin",
+				"2025-04-10T14:00:00Z/2025-04-10T15:00:00Z Coffee Spin",
+				"2025-04-15T14:00:00Z/2025-04-15T15:00:00Z Coffee Spin",
+			},
+		},
+		{
+			// A date-only UNTIL keeps the instance on that date
+			name:    "monthly ordinal BYDAY",
+			fixture: "ical_monthly_byday.ics",
+			want: []string{
+				"2025-05-11T16:00:00Z/2025-05-11T19:00:00Z Second Sunday Social",
+				"2025-05-31T01:00:00Z/2025-05-31T03:00:00Z Last Friday Mass",
+				"2025-06-08T16:00:00Z/2025-06-08T19:00:00Z Second Sunday Social",
+				"2025-06-28T01:00:00Z/2025-06-28T03:00:00Z Last Friday Mass",
+				"2025-07-13T16:00:00Z/2025-07-13T19:00:00Z Second Sunday Social",
+				"2025-07-26T01:00:00Z/2025-07-26T03:00:00Z Last Friday Mass",
+			},
+		},
+		{
+			// Excluded instances still count towards COUNT
+			name:    "EXDATE by date-time and by date",
+			fixture: "ical_exdate.ics",
+			want: []string{
+				"2025-05-08T01:00:00Z/2025-05-08T02:30:00Z Wednesday Hills",
+				"2025-05-22T01:00:00Z/2025-05-22T02:30:00Z Wednesday Hills",
+				"2025-06-05T01:00:00Z/2025-06-05T02:30:00Z Wednesday Hills",
+			},
+		},
+		{
+			name:    "RECURRENCE-ID moves and cancels instances",
+			fixture: "ical_recurrence_id.ics",
+			want: []string{
+				"2025-06-07T17:00:00Z/2025-06-07T20:00:00Z Saturday Gravel",
+				"2025-06-15T18:00:00Z/2025-06-15T21:00:00Z Saturday Gravel (moved to Sunday)",
+				"2025-06-21T17:00:00Z/2025-06-21T20:00:00Z Saturday Gravel (cancelled)",
+				"2025-06-28T17:00:00Z/2025-06-28T20:00:00Z Saturday Gravel",
+			},
+		},
+		{
+			// The TZID is not an IANA name, so the feed's VTIMEZONE is used
+			// and the offset changes on the first Sunday of November
+			name:    "VTIMEZONE across DST",
+			fixture: "ical_vtimezone.ics",
+			want: []string{
+				"2025-10-31T00:00:00Z/2025-10-31T01:30:00Z Canyon Climb",
+				"2025-11-07T01:00:00Z/2025-11-07T02:30:00Z Canyon Climb",
+			},
+		},
+	}
+
+	from := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar := parseICalFixture(t, tt.fixture)
+
+			var got []string
+			for _, occurrence := range calendar.Expand(from, to) {
+				got = append(got, formatOccurrence(occurrence))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expand() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarExpandWindow(t *testing.T) {
+	calendar := parseICalFixture(t, "ical_weekly_count.ics")
+
+	// Only the middle instance starts within the window
+	from := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.March, 20, 0, 0, 0, 0, time.UTC)
+
+	var got []string
+	for _, occurrence := range calendar.Expand(from, to) {
+		got = append(got, formatOccurrence(occurrence))
+	}
+	want := []string{"2025-03-14T01:30:00Z/2025-03-14T03:00:00Z Thursday Night Ride"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+}
+
+func TestParseICalendarFoldedLines(t *testing.T) {
+	calendar := parseICalFixture(t, "ical_folded.ics")
+
+	if calendar.Name != "Folded Lines Club" {
+		t.Errorf("Name = %q, want %q", calendar.Name, "Folded Lines Club")
+	}
+	if len(calendar.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(calendar.Events))
+	}
+
+	event := calendar.Events[0]
+	tests := []struct {
+		field string
+		got   string
+		want  string
+	}{
+		{"Summary", event.Summary, "Full Moon Ride, Slow Roll"},
+		{"Description", event.Description, "Meet at the fountain; bring lights.\nWe roll at 9 and stop for tacos."},
+		{"Location", event.Location, "Salmon Street Springs"},
+		{"Organizer", event.Organizer, "Doe, Jane"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+
+	if start := event.Start.instant(); !start.Equal(time.Date(2025, time.July, 12, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Start = %v, want 2025-07-12T04:00:00Z", start)
+	}
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RecurrenceRule
+		wantErr bool
+	}{
+		{
+			value: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			want: RecurrenceRule{Freq: "WEEKLY", Interval: 2, ByDay: []weekdayNum{
+				{Weekday: time.Monday}, {Weekday: time.Wednesday},
+			}},
+		},
+		{
+			value: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=6",
+			want:  RecurrenceRule{Freq: "MONTHLY", Interval: 1, Count: 6, ByDay: []weekdayNum{{N: -1, Weekday: time.Friday}}},
+		},
+		{
+			value: "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=-1;BYSETPOS=1",
+			want:  RecurrenceRule{Freq: "YEARLY", Interval: 1, ByMonth: []time.Month{time.March}, ByMonthDay: []int{-1}, BySetPos: []int{1}},
+		},
+		{value: "FREQ=HOURLY", wantErr: true},
+		{value: "FREQ=WEEKLY;COUNT=0", wantErr: true},
+		{value: "FREQ=WEEKLY;BYDAY=0MO", wantErr: true},
+		{value: "FREQ=MONTHLY;BYMONTHDAY=32", wantErr: true},
+	}
+
+	parser := icalTimeParser{defaultLoc: time.UTC}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRecurrenceRule(tt.value, parser)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseRecurrenceRule(%q) succeeded, want error", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRecurrenceRule(%q): %v", tt.value, err)
+			}
+			if got.Freq != tt.want.Freq || got.Interval != tt.want.Interval || got.Count != tt.want.Count ||
+				!slices.Equal(got.ByDay, tt.want.ByDay) || !slices.Equal(got.ByMonth, tt.want.ByMonth) ||
+				!slices.Equal(got.ByMonthDay, tt.want.ByMonthDay) || !slices.Equal(got.BySetPos, tt.want.BySetPos) {
+				t.Errorf("parseRecurrenceRule(%q) = %+v, want %+v", tt.value, *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsExternalImage reports whether a ride's image is hosted elsewhere and
+// still needs an optimized copy. Images the optimizer already produced end in
+// _optimized.webp.
+func IsExternalImage(imageURL string) bool {
+	imageURL = strings.TrimSpace(imageURL)
+	if !strings.HasPrefix(imageURL, "https://") && !strings.HasPrefix(imageURL, "http://") {
+		return false
+	}
+	return !strings.HasSuffix(imageURL, "_optimized.webp")
+}
+
+// QueueImageMirrors adds the external images of new or changed rides to the
+// image_mirrors queue for the optimizer. Images already queued or mirrored are
+// left alone, so an image shared by every date of a series is fetched once.
+// It returns how many images were queued.
+func QueueImageMirrors(db *sql.DB, cityCode string, events []Event) (int, error) {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, event := range events {
+		imageURL := strings.TrimSpace(event.Image)
+		if !IsExternalImage(imageURL) || seen[imageURL] {
+			continue
+		}
+		seen[imageURL] = true
+		urls = append(urls, imageURL)
+	}
+	if len(urls) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin image queue transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO image_mirrors (source_url, citycode, status, requested_at, updated_at)
+		VALUES (?, ?, 'pending', ?, ?)
+		ON CONFLICT (source_url) DO NOTHING
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare image queue statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	queued := 0
+	for _, imageURL := range urls {
+		var result sql.Result
+		result, err = stmt.Exec(imageURL, cityCode, now, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to queue image %s: %w", imageURL, err)
+		}
+		if n, rowsErr := result.RowsAffected(); rowsErr == nil {
+			queued += int(n)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit queued images: %w", err)
+	}
+
+	return queued, nil
+}
+
+// RequestImageMirrors asks the image optimizer at baseURL to work through the
+// city's pending images. The queue is kept in the database, so images a
+// failed request leaves behind are picked up by the next one.
+func RequestImageMirrors(ctx context.Context, httpClient *http.Client, baseURL, cityCode string) error {
+	body, err := json.Marshal(map[string]string{"cityCode": cityCode})
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/mirror", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create mirror request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call image optimizer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("image optimizer returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mapLinkRegex finds links and geo: URIs in ride text. Short links are often
+// pasted without a scheme, so those hosts are matched on their own too.
+var mapLinkRegex = regexp.MustCompile(
+	`(?i)(?:https?://|geo:|\b(?:maps\.app\.goo\.gl|goo\.gl/maps|maps\.apple|osm\.org/go)/)[^\s<>"'()\[\]]+`,
+)
+
+// googlePinRegex matches the "!3d<lat>!4d<lng>" pin Google Maps place links
+// carry in their data parameter, which is more precise than the "@" viewport
+var googlePinRegex = regexp.MustCompile(`!3d(-?\d+(?:\.\d+)?)!4d(-?\d+(?:\.\d+)?)`)
+
+// googleViewportRegex matches the "@<lat>,<lng>" map center in a Google Maps path
+var googleViewportRegex = regexp.MustCompile(`@(-?\d+(?:\.\d+)?),(-?\d+(?:\.\d+)?)`)
+
+// plusCodeRegex matches full ("84QVG8MX+F4") and short ("G8MX+F4") Open
+// Location Codes
+var plusCodeRegex = regexp.MustCompile(
+	`(?i)(?:^|[^0-9A-Z+])([23456789CFGHJMPQRVWX]{4,8}\+[23456789CFGHJMPQRVWX]{2,3})(?:$|[^0-9A-Z+])`,
+)
+
+// shortLinkHosts are map link shorteners that only reveal their coordinates
+// once followed. A path prefix is required when the host shortens other links.
+var shortLinkHosts = map[string]string{
+	"maps.app.goo.gl":   "",
+	"goo.gl":            "/maps",
+	"maps.apple":        "",
+	"osm.org":           "/go/",
+	"openstreetmap.org": "/go/",
+}
+
+// processMapLinks looks for map links, geo: URIs and Plus Codes in source and
+// takes the first one that points inside the city. A short link that needs
+// resolving is kept in loc.MapLink for ResolveMapLink.
+func processMapLinks(source string, city CityDetails, loc *Location) bool {
+	bounds := city.RideBounds()
+
+	for _, link := range mapLinkRegex.FindAllString(source, -1) {
+		link = strings.TrimRight(link, ".,;:!?")
+
+		if lat, lng, ok := parseMapLink(link); ok {
+			if bounds.Contains(lat, lng) {
+				setCoordinates(loc, lat, lng)
+				return true
+			}
+			continue
+		}
+
+		if loc.MapLink == "" && isShortMapLink(link) {
+			loc.MapLink = link
+		}
+	}
+
+	for _, matches := range plusCodeRegex.FindAllStringSubmatch(source, -1) {
+		if lat, lng, ok := decodePlusCode(matches[1], city); ok && bounds.Contains(lat, lng) {
+			setCoordinates(loc, lat, lng)
+			return true
+		}
+	}
+
+	return false
+}
+
+func setCoordinates(loc *Location, lat, lng float64) {
+	loc.Latitude = lat
+	loc.Longitude = lng
+	loc.NeedsGeocoding = false
+	loc.MapLink = ""
+}
+
+// parseMapLink reads the coordinates out of a Google Maps, OpenStreetMap or
+// Apple Maps link, or a geo: URI, without making any requests
+func parseMapLink(link string) (float64, float64, bool) {
+	if len(link) > 4 && strings.EqualFold(link[:4], "geo:") {
+		return parseGeoURI(link[4:])
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, 0, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	query := u.Query()
+
+	switch {
+	case strings.HasPrefix(host, "google.") || strings.HasPrefix(host, "maps.google."):
+		if matches := googlePinRegex.FindStringSubmatch(u.Path); matches != nil {
+			return parseLatLng(matches[1], matches[2])
+		}
+		for _, param := range []string{"q", "query", "ll", "destination", "daddr"} {
+			if lat, lng, ok := parseLatLngPair(query.Get(param)); ok {
+				return lat, lng, true
+			}
+		}
+		if matches := googleViewportRegex.FindStringSubmatch(u.Path); matches != nil {
+			return parseLatLng(matches[1], matches[2])
+		}
+
+	case host == "openstreetmap.org" || host == "osm.org":
+		if lat, lng, ok := parseLatLng(query.Get("mlat"), query.Get("mlon")); ok {
+			return lat, lng, true
+		}
+		// #map=<zoom>/<lat>/<lng>
+		fragment, _ := url.ParseQuery(u.Fragment)
+		if parts := strings.Split(fragment.Get("map"), "/"); len(parts) == 3 {
+			return parseLatLng(parts[1], parts[2])
+		}
+
+	case host == "maps.apple.com":
+		for _, param := range []string{"ll", "coordinate", "q", "sll"} {
+			if lat, lng, ok := parseLatLngPair(query.Get(param)); ok {
+				return lat, lng, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// parseGeoURI parses the part of a geo: URI after the scheme, e.g.
+// "45.52,-122.68;u=35" or Android's "0,0?q=45.52,-122.68(Start)"
+func parseGeoURI(uri string) (float64, float64, bool) {
+	coords, rawQuery, _ := strings.Cut(uri, "?")
+	coords, _, _ = strings.Cut(coords, ";")
+
+	lat, lng, ok := parseLatLngPair(coords)
+	if ok && (lat != 0 || lng != 0) {
+		return lat, lng, true
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return 0, 0, false
+	}
+	q, _, _ := strings.Cut(query.Get("q"), "(")
+	return parseLatLngPair(q)
+}
+
+// parseLatLngPair parses "lat,lng", ignoring anything after the longitude
+// such as an altitude or zoom level
+func parseLatLngPair(pair string) (float64, float64, bool) {
+	parts := strings.Split(pair, ",")
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	return parseLatLng(parts[0], parts[1])
+}
+
+func parseLatLng(rawLat, rawLng string) (float64, float64, bool) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(rawLat), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(rawLng), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if math.Abs(lat) > 90 || math.Abs(lng) > 180 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+func isShortMapLink(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	prefix, ok := shortLinkHosts[host]
+	return ok && strings.HasPrefix(u.Path, prefix)
+}
+
+// Open Location Code constants, see
+// https://github.com/google/open-location-code/blob/main/docs/specification.md
+const (
+	olcAlphabet       = "23456789CFGHJMPQRVWX"
+	olcSeparatorIndex = 8
+	olcPairLength     = 10
+	olcGridRows       = 5
+	olcGridColumns    = 4
+)
+
+// olcPlaceValues are the size in degrees of each digit pair of a code
+var olcPlaceValues = [...]float64{20, 1, 0.05, 0.0025, 0.000125}
+
+// decodePlusCode returns the center of a Plus Code's area. Short codes, with
+// leading digits left off, are recovered relative to the city's center, the
+// same way Google Maps does relative to the viewer's location.
+func decodePlusCode(code string, city CityDetails) (float64, float64, bool) {
+	code = strings.ToUpper(code)
+	separator := strings.IndexByte(code, '+')
+	if separator%2 != 0 {
+		return 0, 0, false
+	}
+
+	if separator < olcSeparatorIndex {
+		return recoverShortPlusCode(code, city.CenterLat, city.CenterLng)
+	}
+
+	return decodeFullPlusCode(code)
+}
+
+func decodeFullPlusCode(code string) (float64, float64, bool) {
+	digits := strings.Replace(code, "+", "", 1)
+	if len(digits) < olcSeparatorIndex {
+		return 0, 0, false
+	}
+
+	// the first pair can only encode 0-180 degrees of latitude and 0-360 of
+	// longitude
+	if strings.IndexByte(olcAlphabet, digits[0]) >= 9 || strings.IndexByte(olcAlphabet, digits[1]) >= 18 {
+		return 0, 0, false
+	}
+
+	lat, lng := -90.0, -180.0
+	var latSize, lngSize float64
+	for i := 0; i < len(digits) && i < olcPairLength; i += 2 {
+		if i+1 >= len(digits) {
+			return 0, 0, false
+		}
+		latDigit := strings.IndexByte(olcAlphabet, digits[i])
+		lngDigit := strings.IndexByte(olcAlphabet, digits[i+1])
+		if latDigit < 0 || lngDigit < 0 {
+			return 0, 0, false
+		}
+		place := olcPlaceValues[i/2]
+		lat += float64(latDigit) * place
+		lng += float64(lngDigit) * place
+		latSize, lngSize = place, place
+	}
+
+	// an 11th digit picks a cell of a 5x4 grid within the last pair's area
+	if len(digits) > olcPairLength {
+		digit := strings.IndexByte(olcAlphabet, digits[olcPairLength])
+		if digit < 0 {
+			return 0, 0, false
+		}
+		latSize /= olcGridRows
+		lngSize /= olcGridColumns
+		lat += float64(digit/olcGridColumns) * latSize
+		lng += float64(digit%olcGridColumns) * lngSize
+	}
+
+	return lat + latSize/2, lng + lngSize/2, true
+}
+
+// recoverShortPlusCode fills in a short code's missing leading digits from the
+// reference location, picking the nearest area that matches the code
+func recoverShortPlusCode(code string, refLat, refLng float64) (float64, float64, bool) {
+	padding := olcSeparatorIndex - strings.IndexByte(code, '+')
+	resolution := math.Pow(20, float64(2-padding/2))
+	half := resolution / 2
+
+	lat, lng, ok := decodeFullPlusCode(encodePlusCodePrefix(refLat, refLng, padding) + code)
+	if !ok {
+		return 0, 0, false
+	}
+
+	if refLat+half < lat && lat-resolution >= -90 {
+		lat -= resolution
+	} else if refLat-half > lat && lat+resolution <= 90 {
+		lat += resolution
+	}
+	if refLng+half < lng {
+		lng -= resolution
+	} else if refLng-half > lng {
+		lng += resolution
+	}
+
+	return lat, lng, true
+}
+
+// encodePlusCodePrefix returns the first length digits of the code for a
+// location
+func encodePlusCodePrefix(lat, lng float64, length int) string {
+	lat = math.Min(math.Max(lat, -90), 90) + 90
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+
+	var prefix strings.Builder
+	for i := 0; i < length; i += 2 {
+		place := olcPlaceValues[i/2]
+		latDigit := min(int(lat/place), len(olcAlphabet)-1)
+		lngDigit := min(int(lng/place), len(olcAlphabet)-1)
+		prefix.WriteByte(olcAlphabet[latDigit])
+		prefix.WriteByte(olcAlphabet[lngDigit])
+		lat -= float64(latDigit) * place
+		lng -= float64(lngDigit) * place
+	}
+	return prefix.String()
+}
+
+// LinkResolver expands a short map link, such as maps.app.goo.gl, into the
+// URL it redirects to
+type LinkResolver interface {
+	Resolve(ctx context.Context, link string) (string, error)
+}
+
+// HTTPLinkResolver resolves short links by following their redirects
+type HTTPLinkResolver struct {
+	client *http.Client
+}
+
+const maxLinkRedirects = 5
+
+// NewHTTPLinkResolver creates a resolver that makes its requests with client's
+// transport and timeout
+func NewHTTPLinkResolver(client *http.Client) *HTTPLinkResolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPLinkResolver{
+		client: &http.Client{
+			Transport: client.Transport,
+			Timeout:   client.Timeout,
+			// redirects are followed by hand so a link that lands on a map
+			// page doesn't have to be downloaded
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (r *HTTPLinkResolver) Resolve(ctx context.Context, link string) (string, error) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	for range maxLinkRedirects {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("User-Agent", "CycleScene/1.0 (+https://cyclescene.cc)")
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", link, err)
+		}
+		resp.Body.Close()
+
+		location, err := resp.Location()
+		if err != nil {
+			// not a redirect, so this is where the link ends up
+			return link, nil
+		}
+		link = location.String()
+
+		if _, _, ok := parseMapLink(link); ok {
+			return link, nil
+		}
+	}
+
+	return link, nil
+}
+
+// ResolveMapLink follows the location's short map link and takes the
+// coordinates of the page it lands on, if they are inside the city. It reports
+// whether the location no longer needs geocoding.
+func ResolveMapLink(ctx context.Context, resolver LinkResolver, loc *Location, cityCode string) (bool, error) {
+	if resolver == nil || loc.MapLink == "" || !loc.NeedsGeocoding {
+		return false, nil
+	}
+
+	city, ok := cityMap[cityCode]
+	if !ok {
+		return false, nil
+	}
+
+	resolved, err := resolver.Resolve(ctx, loc.MapLink)
+	if err != nil {
+		return false, err
+	}
+
+	lat, lng, ok := parseMapLink(resolved)
+	if !ok {
+		return false, fmt.Errorf("no coordinates in %s", resolved)
+	}
+	if !city.RideBounds().Contains(lat, lng) {
+		return false, fmt.Errorf("%s points outside %s", resolved, city.CityName)
+	}
+
+	setCoordinates(loc, lat, lng)
+	return true, nil
+}
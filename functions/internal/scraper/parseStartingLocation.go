@@ -7,50 +7,101 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
+// coordinatesRegex matches a decimal coordinate pair with optional degree signs
+// and hemisphere letters, e.g. "45.52, -122.68" or "45.52° N 122.68° W"
 var coordinatesRegex = regexp.MustCompile(
-	`(-?\d+\.\d+)\s*[°\s]*[NnSs]?[,\s]+(-?\d+\.\d+)\s*[°\s]*[EeWw]?`,
+	`(-?\d+\.\d+)\s*[°\s]*(?:([NnSsEeWw])\b)?[,\s]+(-?\d+\.\d+)\s*[°\s]*(?:([NnSsEeWw])\b)?`,
 )
 
 const KeyPrecision = 6
 
-func isValidPortlandCoordinate(lat float64, lng float64) bool {
-	const minLat = 45.0
-	const maxLat = 46.0
-	const minLng = -123.0
-	const maxLng = -121.5
-
-	return lat >= minLat && lat <= maxLat && lng >= minLng && lng <= maxLng
+// coordinate is one half of a coordinate pair as written in ride text
+type coordinate struct {
+	value      float64
+	hemisphere byte
+	signed     bool
 }
 
-func processGps(source string, loc *Location) bool {
-	matches := coordinatesRegex.FindStringSubmatch(source)
+func parseCoordinate(number, hemisphere string) (coordinate, bool) {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return coordinate{}, false
+	}
 
-	if len(matches) == 3 {
-		lat, latErr := strconv.ParseFloat(matches[1], 64)
-		lng, lngErr := strconv.ParseFloat(matches[2], 64)
+	c := coordinate{value: value, signed: strings.HasPrefix(number, "-")}
+	if hemisphere != "" {
+		c.hemisphere = strings.ToUpper(hemisphere)[0]
+	}
+	return c, true
+}
 
-		if latErr == nil && lngErr == nil {
+// resolve applies the coordinate's hemisphere letter or explicit sign. When
+// neither is written the sign is taken from the city, since organizers often
+// drop the minus sign on western longitudes.
+func (c coordinate) resolve(positive, negative byte, inferredSign float64) (float64, bool) {
+	switch c.hemisphere {
+	case 0:
+		if c.signed {
+			return c.value, true
+		}
+		return math.Abs(c.value) * inferredSign, true
+	case positive:
+		return math.Abs(c.value), true
+	case negative:
+		return -math.Abs(c.value), true
+	default:
+		return 0, false
+	}
+}
 
-			lat = math.Abs(lat)
+func sign(v float64) float64 {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
 
-			lng = -math.Abs(lng)
+// coordinatesInCity interprets a pair as lat,lng and then as lng,lat, and
+// returns the first reading that falls within the city's ride bounds
+func coordinatesInCity(first, second coordinate, city CityDetails) (float64, float64, bool) {
+	bounds := city.RideBounds()
+	latSign := sign(bounds.SWLat + bounds.NELat)
+	lngSign := sign(bounds.SWLng + bounds.NELng)
+
+	for _, pair := range [][2]coordinate{{first, second}, {second, first}} {
+		lat, latOK := pair[0].resolve('N', 'S', latSign)
+		lng, lngOK := pair[1].resolve('E', 'W', lngSign)
+		if latOK && lngOK && bounds.Contains(lat, lng) {
+			return lat, lng, true
+		}
+	}
+	return 0, 0, false
+}
 
-			if isValidPortlandCoordinate(lat, lng) {
-				loc.Latitude = lat
-				loc.Longitude = lng
-				loc.NeedsGeocoding = false
-				return true
-			} else {
-				slog.Warn("GPS coordinates extracted but out of Portland Range", "raw_source", source, "lat", lat, "lng", lng)
-			}
+func processGps(source string, city CityDetails, loc *Location) bool {
+	for _, matches := range coordinatesRegex.FindAllStringSubmatch(source, -1) {
+		first, firstOK := parseCoordinate(matches[1], matches[2])
+		second, secondOK := parseCoordinate(matches[3], matches[4])
+		if !firstOK || !secondOK {
+			continue
+		}
+
+		if lat, lng, ok := coordinatesInCity(first, second, city); ok {
+			loc.Latitude = lat
+			loc.Longitude = lng
+			loc.NeedsGeocoding = false
+			return true
 		}
+
+		slog.Warn("GPS coordinates extracted but out of city range", "city", city.CityName, "raw_source", source, "match", matches[0])
 	}
 	return false
 }
 
-func CreateLocationFromEvent(event *Shift2BikeEvent) Location {
+func CreateLocationFromEvent(event *Event) Location {
 	loc := Location{
 		Address:        strings.TrimSpace(event.Address),
 		Venue:          strings.TrimSpace(event.Venue),
@@ -58,40 +109,80 @@ func CreateLocationFromEvent(event *Shift2BikeEvent) Location {
 		NeedsGeocoding: true,
 	}
 
-	if processGps(event.Locdetails, &loc) {
+	city, ok := cityMap[event.CityCode]
+	if !ok {
+		slog.Warn("Unknown city, skipping coordinate extraction", "city", event.CityCode)
+		goto geocode
+	}
+
+	if processMapLinks(event.Locdetails, city, &loc) || processGps(event.Locdetails, city, &loc) {
 		goto cleanup
 	}
 
-	if processGps(event.Details, &loc) {
+	if processMapLinks(event.Details, city, &loc) || processGps(event.Details, city, &loc) {
 		goto cleanup
 	}
 
-	if processGps(event.Address, &loc) {
+	if processMapLinks(event.Address, city, &loc) || processGps(event.Address, city, &loc) {
 		goto cleanup
 	}
 
-	if processGps(event.Venue, &loc) {
+	if processMapLinks(event.Venue, city, &loc) || processGps(event.Venue, city, &loc) {
 		goto cleanup
 	}
 
-	if loc.NeedsGeocoding {
-		addressLower := strings.ToLower(loc.Address)
-		if strings.EqualFold(addressLower, "tba") ||
-			strings.EqualFold(addressLower, "tbd") ||
-			strings.Contains(addressLower, "maps.app.goo") ||
-			strings.Contains(addressLower, "http") ||
-			loc.Address == "" {
-			loc.Address = ""
-			loc.Venue = ""
-			loc.Details = ""
-		}
+geocode:
+
+	if loc.NeedsGeocoding && !isGeocodableAddress(loc.Address) {
+		loc.Address = ""
+		loc.Venue = ""
+		loc.Details = ""
 	}
 
 cleanup:
 	return loc
 }
 
-func CreateGeoCodingQuery(loc *Location) string {
+// CreateEndLocationFromEvent parses where a ride finishes. Loop rides and rides
+// without an ending location get an empty location that needs no geocoding.
+func CreateEndLocationFromEvent(event *Event) Location {
+	loc := Location{
+		Address: strings.TrimSpace(event.Locend),
+		City:    event.CityCode,
+	}
+	if event.Loopride || loc.Address == "" {
+		return loc
+	}
+	loc.NeedsGeocoding = true
+
+	if city, ok := cityMap[event.CityCode]; ok {
+		if processMapLinks(loc.Address, city, &loc) || processGps(loc.Address, city, &loc) {
+			return loc
+		}
+	}
+
+	if !isGeocodableAddress(loc.Address) {
+		// a short map link may still be resolved
+		loc.Address = ""
+	}
+	return loc
+}
+
+// isGeocodableAddress reports whether an address is worth sending to the
+// geocoder, rather than a placeholder or a link
+func isGeocodableAddress(address string) bool {
+	addressLower := strings.ToLower(address)
+	return address != "" &&
+		addressLower != "tba" &&
+		addressLower != "tbd" &&
+		!strings.Contains(addressLower, "maps.app.goo") &&
+		!strings.Contains(addressLower, "http")
+}
+
+// CreateGeoCodingQuery builds the geocoding query for a location, adding the
+// city and state unless the address already names a state the city's rides
+// are held in
+func CreateGeoCodingQuery(loc *Location, cityCode string) string {
 	address := loc.Address
 	venue := loc.Venue
 
@@ -106,13 +197,37 @@ func CreateGeoCodingQuery(loc *Location) string {
 		return ""
 	}
 
-	lowerQuery := strings.ToLower(baseQuery)
+	city, ok := cityMap[cityCode]
+	if !ok {
+		return baseQuery
+	}
 
-	if strings.Contains(lowerQuery, ", or") || strings.Contains(lowerQuery, ", wa") {
+	if mentionsState(baseQuery, city.States()) {
 		return baseQuery
 	}
 
-	return fmt.Sprintf("%s, Portland, OR", baseQuery)
+	return fmt.Sprintf("%s, %s, %s", baseQuery, city.CityName, city.State)
+}
+
+// mentionsState reports whether the query contains ", <state>" as a whole word
+func mentionsState(query string, states []string) bool {
+	lowerQuery := strings.ToLower(query)
+
+	for _, state := range states {
+		needle := ", " + strings.ToLower(state)
+		for offset := 0; ; {
+			i := strings.Index(lowerQuery[offset:], needle)
+			if i == -1 {
+				break
+			}
+			end := offset + i + len(needle)
+			if end == len(lowerQuery) || !unicode.IsLetter(rune(lowerQuery[end])) {
+				return true
+			}
+			offset = end
+		}
+	}
+	return false
 }
 
 func CreateCanonicalCoordKey(lat float64, lng float64) string {
@@ -1,123 +1,128 @@
 package scraper
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
-func buildShift2BikesURLUpcoming() (string, error) {
-	location, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		return "", fmt.Errorf("failed to load timezone location: %w", err)
-	}
-
-	nowInPortland := time.Now().In(location)
-
-	year, month, day := nowInPortland.Date()
-	startDate := time.Date(year, month, day, 0, 0, 0, 0, location)
-
-	endDate := startDate.AddDate(0, 0, 99)
+const shift2BikesSourceName = "Shift2Bikes"
 
-	formattedStartDate := startDate.Format(time.RFC3339)
-	formattedEndDate := endDate.Format(time.RFC3339)
+// DefaultShift2BikesChunkDays is how many days each request to the Shift2Bikes
+// events API covers, keeping responses small however wide the window is
+const DefaultShift2BikesChunkDays = 31
 
-	baseURL := "https://www.shift2bikes.org/api/events.php"
-
-	finalURL, _ := url.Parse(baseURL)
-
-	params := url.Values{}
-	params.Set("startdate", formattedStartDate)
-	params.Set("enddate", formattedEndDate)
-
-	finalURL.RawQuery = params.Encode()
-
-	return finalURL.String(), nil
+// Shift2BikesSource fetches rides from the Shift2Bikes events API
+type Shift2BikesSource struct {
+	httpClient *http.Client
+	chunkDays  int
 }
 
-func buildShift2BikesURLPast() (string, error) {
-	location, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		return "", fmt.Errorf("failed to load timezone location: %w", err)
+// NewShift2BikesSource returns a source that requests chunkDays days at a
+// time, or DefaultShift2BikesChunkDays when chunkDays is zero or less
+func NewShift2BikesSource(httpClient *http.Client, chunkDays int) *Shift2BikesSource {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if chunkDays <= 0 {
+		chunkDays = DefaultShift2BikesChunkDays
 	}
+	return &Shift2BikesSource{httpClient: httpClient, chunkDays: chunkDays}
+}
 
-	nowInPortland := time.Now().In(location)
+func (s *Shift2BikesSource) Name() string {
+	return shift2BikesSourceName
+}
 
-	year, month, day := nowInPortland.Date()
-	startDate := time.Date(year, month, day, 0, 0, 0, 0, location)
+func (s *Shift2BikesSource) Owns(stored StoredEvent) bool {
+	return stored.Source == shift2BikesSourceName
+}
 
-	endDate := startDate.AddDate(0, 0, -99)
+// FetchEvents requests the window in chunks of chunkDays, one after another,
+// so each request stays within the range Shift2Bikes serves. Rides missing an
+// id, title or date are skipped.
+func (s *Shift2BikesSource) FetchEvents(ctx context.Context, window Window) ([]Event, error) {
+	var events []Event
+	seen := make(map[string]bool)
+	for _, chunk := range window.Chunks(s.chunkDays) {
+		url := buildShift2BikesURL(chunk.Start, chunk.End)
+		page, err := s.fetchPage(ctx, url)
+		if err != nil {
+			slog.Error("shift2Bikes API request failed", "url", url, "error", err.Error())
+			return nil, err
+		}
+
+		// chunks don't overlap, but a ride on the boundary is only kept once
+		// in case upstream treats the end date as inclusive of the next day
+		for _, event := range page.Events {
+			key := CompositeEventID(event)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			events = append(events, event)
+		}
+	}
 
-	formattedStartDate := startDate.Format(time.RFC3339)
-	formattedEndDate := endDate.Format(time.RFC3339)
+	return skipInvalidEvents(shift2BikesSourceName, events)
+}
 
+func buildShift2BikesURL(startDate, endDate time.Time) string {
 	baseURL := "https://www.shift2bikes.org/api/events.php"
 
 	finalURL, _ := url.Parse(baseURL)
 
 	params := url.Values{}
-	params.Set("startdate", formattedStartDate)
-	params.Set("enddate", formattedEndDate)
+	params.Set("startdate", startDate.Format(time.RFC3339))
+	params.Set("enddate", endDate.Format(time.RFC3339))
 
 	finalURL.RawQuery = params.Encode()
 
-	return finalURL.String(), nil
+	return finalURL.String()
 }
 
-func fetchAndDecode(url string, target any) error {
-	req, _ := http.NewRequest(http.MethodGet, url, nil)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// fetchPage requests a page of events and checks the response before any of
+// it is used: the status must be 200 and the body must be complete JSON with
+// an events array
+func (s *Shift2BikesSource) fetchPage(ctx context.Context, url string) (Shift2BikeEvents, error) {
+	var envelope struct {
+		Events *[]Event `json:"events"`
 	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		return err
+	if err := s.fetchAndDecode(ctx, url, &envelope); err != nil {
+		return Shift2BikeEvents{}, err
 	}
-	defer res.Body.Close()
-
-	decoder := json.NewDecoder(res.Body)
-	return decoder.Decode(&target)
+	if envelope.Events == nil {
+		return Shift2BikeEvents{}, fmt.Errorf("%w: response has no events array", ErrInvalidResponse)
+	}
+	return Shift2BikeEvents{Events: *envelope.Events}, nil
 }
 
-func getPastRides(events *Shift2BikeEvents) error {
-	url, err := buildShift2BikesURLPast()
+func (s *Shift2BikesSource) fetchAndDecode(ctx context.Context, url string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		slog.Error("shift2Bikes API request failed", "url", url, "error", err.Error())
-		return err
+		return fmt.Errorf("failed to create request: %w", err)
 	}
-	return fetchAndDecode(url, &events)
 
-}
-func getUpcomingRides(events *Shift2BikeEvents) error {
-	url, err := buildShift2BikesURLUpcoming()
+	res, err := s.httpClient.Do(req)
 	if err != nil {
-		slog.Error("shift2Bikes API request failed", "url", url, "error", err.Error())
 		return err
 	}
-	return fetchAndDecode(url, &events)
-}
-
-func GetRides() (Shift2BikeEvents, error) {
-	var allEvents Shift2BikeEvents
-	var upcomingEvents Shift2BikeEvents
-	var pastEvents Shift2BikeEvents
+	defer res.Body.Close()
 
-	if err := getUpcomingRides(&upcomingEvents); err != nil {
-		return allEvents, err
+	if res.StatusCode != http.StatusOK {
+		snippet, _ := io.ReadAll(io.LimitReader(res.Body, 200))
+		return fmt.Errorf("%w: status %d: %s", ErrInvalidResponse, res.StatusCode, strings.TrimSpace(string(snippet)))
 	}
-	if err := getPastRides(&pastEvents); err != nil {
-		return allEvents, err
-	}
-
-	allEvents.Events = append(
-		upcomingEvents.Events,
-		pastEvents.Events...,
-	)
 
-	return allEvents, nil
+	decoder := json.NewDecoder(res.Body)
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("%w: failed to decode body: %v", ErrInvalidResponse, err)
+	}
+	return nil
 }
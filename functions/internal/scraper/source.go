@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultWindowDays is how far before and after today a scrape looks for rides
+const DefaultWindowDays = 99
+
+// EventSource is an upstream calendar that rides can be scraped from
+type EventSource interface {
+	// Name is stored as the ridesource of every event the source returns
+	Name() string
+	// FetchEvents returns the source's events that take place within window
+	FetchEvents(ctx context.Context, window Window) ([]Event, error)
+	// Owns reports whether a stored ride was scraped from this source, so rides
+	// the source stops returning can be marked removed
+	Owns(stored StoredEvent) bool
+}
+
+// Window is the inclusive range of days a scrape covers
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// DefaultWindow returns the window from DefaultWindowDays ago to DefaultWindowDays
+// from now, starting at midnight in the city's time zone
+func DefaultWindow(cityCode string) (Window, error) {
+	return WindowAround(cityCode, DefaultWindowDays)
+}
+
+// WindowAround returns the window from days ago to days from now, starting at
+// midnight in the city's time zone
+func WindowAround(cityCode string, days int) (Window, error) {
+	if days < 0 {
+		return Window{}, fmt.Errorf("window days must not be negative, got %d", days)
+	}
+	location, err := CityLocation(cityCode)
+	if err != nil {
+		return Window{}, err
+	}
+
+	year, month, day := time.Now().In(location).Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, location)
+
+	return Window{
+		Start: today.AddDate(0, 0, -days),
+		End:   today.AddDate(0, 0, days),
+	}, nil
+}
+
+// ParseWindow returns the window days either side of today with either end
+// replaced by a YYYY-MM-DD date in the city's time zone. Empty dates keep the
+// default.
+func ParseWindow(cityCode, since, until string, days int) (Window, error) {
+	window, err := WindowAround(cityCode, days)
+	if err != nil {
+		return Window{}, err
+	}
+	location := window.Start.Location()
+
+	if since != "" {
+		if window.Start, err = time.ParseInLocation("2006-01-02", since, location); err != nil {
+			return Window{}, fmt.Errorf("invalid since date %q: %w", since, err)
+		}
+	}
+	if until != "" {
+		if window.End, err = time.ParseInLocation("2006-01-02", until, location); err != nil {
+			return Window{}, fmt.Errorf("invalid until date %q: %w", until, err)
+		}
+	}
+	if window.End.Before(window.Start) {
+		return Window{}, fmt.Errorf("window ends (%s) before it starts (%s)", window.End.Format("2006-01-02"), window.Start.Format("2006-01-02"))
+	}
+
+	return window, nil
+}
+
+// Chunks splits the window into consecutive windows of at most days days, in
+// order. A days of zero or less returns the window whole.
+func (w Window) Chunks(days int) []Window {
+	if days <= 0 {
+		return []Window{w}
+	}
+
+	var chunks []Window
+	for start := w.Start; !start.After(w.End); start = start.AddDate(0, 0, days) {
+		end := start.AddDate(0, 0, days-1)
+		if end.After(w.End) {
+			end = w.End
+		}
+		chunks = append(chunks, Window{Start: start, End: end})
+	}
+	return chunks
+}
+
+// Registry holds the event sources configured for each city
+type Registry struct {
+	sources map[string][]EventSource
+}
+
+func NewRegistry() *Registry {
+	return &Registry{sources: make(map[string][]EventSource)}
+}
+
+// Register adds a source to the list scraped for a city
+func (r *Registry) Register(cityCode string, source EventSource) {
+	r.sources[cityCode] = append(r.sources[cityCode], source)
+}
+
+// Sources returns every source registered for a city
+func (r *Registry) Sources(cityCode string) []EventSource {
+	return r.sources[cityCode]
+}
+
+// DefaultRegistry returns a registry with the built-in sources for each city.
+// Shift2Bikes is fetched chunkDays at a time, or DefaultShift2BikesChunkDays
+// when chunkDays is zero.
+func DefaultRegistry(httpClient *http.Client, chunkDays int) *Registry {
+	registry := NewRegistry()
+	registry.Register("pdx", NewShift2BikesSource(httpClient, chunkDays))
+	return registry
+}
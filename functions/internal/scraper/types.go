@@ -1,6 +1,11 @@
 package scraper
 
-type Shift2BikeEvent struct {
+import "time"
+
+// Event is the normalized ride every EventSource produces. Field names and
+// JSON tags follow the Shift2Bikes API, which was the first source and which
+// shaped the shift2bikes_events table all scraped rides are stored in.
+type Event struct {
 	ID            string `json:"id"`
 	Title         string `json:"title"`
 	Venue         string `json:"venue"`
@@ -46,17 +51,31 @@ type Shift2BikeEvent struct {
 	/// Location details
 	LocationID int      `json:"-"`
 	Location   Location `json:"-"`
+	// EndLocation is where a ride that isn't a loop finishes. It has no
+	// coordinates when the ride has no ending location or it couldn't be found.
+	EndLocation Location `json:"-"`
 
 	/// Sourced From details
 	SourcedFrom string `json:"sourcedfrom"`
 	CityCode    string `json:"citycode"`
+	// GroupCode ties rides imported from a group's calendar feed to its ride_groups row
+	GroupCode string `json:"-"`
+
+	// GeocodeQuery is the geocode_cache key the ride's start was looked up
+	// with, so admin corrections to that key can be re-applied to the ride
+	GeocodeQuery string `json:"-"`
 
 	/// Route details
 	RouteID string `json:"-"`
+
+	// Tags are the slugs the tagging rules gave the ride. They aren't part of
+	// the upstream payload, so they don't change its source_hash.
+	Tags []string `json:"-"`
 }
 
+// Shift2BikeEvents is the envelope returned by the Shift2Bikes events API
 type Shift2BikeEvents struct {
-	Events []Shift2BikeEvent `json:"events"`
+	Events []Event `json:"events"`
 }
 
 type Location struct {
@@ -69,13 +88,54 @@ type Location struct {
 	Venue          string  `json:"venue"`
 	Details        string  `json:"details"`
 	NeedsGeocoding bool    `json:"-"`
+	// MapLink is a short map link found in the ride text, to be followed by
+	// ResolveMapLink when nothing else gave coordinates
+	MapLink string `json:"-"`
+	// Geocoded is the provider result when the location was geocoded this run
+	Geocoded *GeocodeResult `json:"-"`
+	// Quality is where the coordinates came from
+	Quality LocationQuality `json:"-"`
 }
 
+// LocationQuality says where a ride's coordinates came from, so clients can
+// tell a real pin from a placeholder
+type LocationQuality string
+
+const (
+	// LocationExact coordinates were given by the ride itself, in its text or
+	// a map link
+	LocationExact LocationQuality = "exact"
+	// LocationGeocoded coordinates were looked up with the geocoder
+	LocationGeocoded LocationQuality = "geocoded"
+	// LocationCached coordinates came from the geocode cache, including
+	// admin overrides
+	LocationCached LocationQuality = "cached"
+	// LocationFallback rides couldn't be located. Scraped ones sit on the
+	// city's fallback point; submitted ones have no coordinates.
+	LocationFallback LocationQuality = "fallback"
+	// LocationUnknown rides were stored before quality was recorded
+	LocationUnknown LocationQuality = "unknown"
+)
+
 type GeoCodeCached struct {
-	ID        string
-	Query     string
-	Latitude  float64
-	Longitude float64
+	ID               string
+	Query            string
+	Latitude         float64
+	Longitude        float64
+	Provider         string
+	PlaceID          string
+	FormattedAddress string
+	Granularity      string
+	Types            []string
+	Confidence       Confidence
+	// ExpiresAt is zero for entries that never expire
+	ExpiresAt time.Time
+}
+
+// NeedsRefresh reports whether a cached entry should be geocoded again
+// because it has expired
+func (c GeoCodeCached) NeedsRefresh(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && now.After(c.ExpiresAt)
 }
 
 type GoogleGeocodeResponse struct {
@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DefaultMaxDrop is the largest share of a source's stored rides a fetch may
+// come back without before the scrape refuses to write
+const DefaultMaxDrop = 0.5
+
+// minDropBaseline is the fewest stored rides a source needs before a drop in
+// its count is treated as suspicious; small calendars swing too much
+const minDropBaseline = 20
+
+// maxInvalidShare is the largest share of a fetch's events that may be
+// skipped as invalid before the whole fetch is rejected
+const maxInvalidShare = 0.1
+
+// maxSchemaProblems caps how many invalid events a schema error lists
+const maxSchemaProblems = 5
+
+var (
+	// ErrInvalidResponse is returned for upstream responses that don't have
+	// the expected shape
+	ErrInvalidResponse = errors.New("invalid upstream response")
+	// ErrAnomalousDrop is returned when a source returns far fewer rides than
+	// are stored for it
+	ErrAnomalousDrop = errors.New("anomalous drop in event count")
+)
+
+// skipInvalidEvents drops the events that lack a field a ride can't be stored
+// without: an id, a title and a YYYY-MM-DD date. Each one is logged and
+// skipped, unless more than maxInvalidShare of the events are invalid, which
+// points at a broken response rather than a few bad rides; then it returns
+// ErrInvalidResponse. Fetches under minDropBaseline events only fail when
+// every event is invalid.
+func skipInvalidEvents(source string, events []Event) ([]Event, error) {
+	var problems []string
+	valid := make([]Event, 0, len(events))
+	for i, event := range events {
+		var missing []string
+		if strings.TrimSpace(event.ID) == "" {
+			missing = append(missing, "id")
+		}
+		if strings.TrimSpace(event.Title) == "" {
+			missing = append(missing, "title")
+		}
+		if _, err := time.Parse("2006-01-02", event.Date); err != nil {
+			missing = append(missing, "date")
+		}
+		if len(missing) == 0 {
+			valid = append(valid, event)
+			continue
+		}
+
+		slog.Warn("skipping invalid event", "source", source, "index", i, "id", event.ID, "missing", strings.Join(missing, ", "))
+		if len(problems) < maxSchemaProblems {
+			problems = append(problems, fmt.Sprintf("event %d (id %q) has no valid %s", i, event.ID, strings.Join(missing, ", ")))
+		}
+	}
+
+	invalid := len(events) - len(valid)
+	if invalid == 0 {
+		return events, nil
+	}
+
+	share := float64(invalid) / float64(len(events))
+	if share > maxInvalidShare && (len(events) >= minDropBaseline || invalid == len(events)) {
+		return nil, fmt.Errorf("%w: %d of %d events are invalid (limit %.0f%%): %s",
+			ErrInvalidResponse, invalid, len(events), maxInvalidShare*100, strings.Join(problems, "; "))
+	}
+	return valid, nil
+}
+
+// CountStoredEvents returns how many of the stored rides belong to source and
+// haven't been removed, the baseline CheckEventCount compares a fetch against
+func CountStoredEvents(stored map[string]StoredEvent, source EventSource) int {
+	count := 0
+	for _, event := range stored {
+		if event.RemovedAt == "" && source.Owns(event) {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckEventCount returns ErrAnomalousDrop when a source fetched more than
+// maxDrop (0 to 1) fewer rides than the baseline stored for it. Baselines
+// under minDropBaseline aren't checked, and a maxDrop of 1 or more turns the
+// check off.
+func CheckEventCount(source string, fetched, baseline int, maxDrop float64) error {
+	if maxDrop >= 1 || baseline < minDropBaseline {
+		return nil
+	}
+
+	drop := 1 - float64(fetched)/float64(baseline)
+	if drop > maxDrop {
+		return fmt.Errorf("%w: %s returned %d events, %.0f%% fewer than the %d stored (limit %.0f%%)",
+			ErrAnomalousDrop, source, fetched, drop*100, baseline, maxDrop*100)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package series groups the dates of a repeating ride into a series and
+// describes how it repeats, such as "Every Thursday". Scraped rides form a
+// series from every stored date sharing the upstream event id, and submitted
+// rides from their occurrences; both are kept in the ride_series table so the
+// API can describe them the same way.
+package series
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Kind is how a series repeats
+type Kind string
+
+const (
+	KindOnce      Kind = "once"
+	KindDaily     Kind = "daily"
+	KindWeekly    Kind = "weekly"
+	KindBiweekly  Kind = "biweekly"
+	KindMonthly   Kind = "monthly"
+	KindWeekday   Kind = "weekday"
+	KindIrregular Kind = "irregular"
+)
+
+// SubmittedSource is the ridesource of user-submitted rides in the API, and
+// the prefix of their series ids
+const SubmittedSource = "user-submitted"
+
+// ID is the series id of the rides a source published under one upstream id
+func ID(source, id string) string {
+	return source + ":" + id
+}
+
+// Pattern describes how a series repeats
+type Pattern struct {
+	Kind Kind
+	// Weekday is the day every date falls on, for weekly, biweekly, monthly
+	// and weekday series
+	Weekday time.Weekday
+	// Week is which week of the month a monthly series falls in, 1 to 4, or
+	// -1 for the last
+	Week int
+	// Summary is a short description such as "Every Thursday", empty for
+	// rides held once
+	Summary string
+}
+
+// Describe works out the pattern of a series from its dates, in any order
+func Describe(dates []time.Time) Pattern {
+	days := uniqueDays(dates)
+	if len(days) <= 1 {
+		return Pattern{Kind: KindOnce}
+	}
+
+	weekday := days[0].Weekday()
+	sameWeekday := true
+	gaps := make([]int, 0, len(days)-1)
+	for i := 1; i < len(days); i++ {
+		if days[i].Weekday() != weekday {
+			sameWeekday = false
+		}
+		gaps = append(gaps, int(days[i].Sub(days[i-1]).Hours()/24+0.5))
+	}
+
+	switch {
+	case allEqual(gaps, 1):
+		return Pattern{Kind: KindDaily, Summary: "Every day"}
+	case allEqual(gaps, 7):
+		return Pattern{Kind: KindWeekly, Weekday: weekday, Summary: "Every " + weekday.String()}
+	case allEqual(gaps, 14):
+		return Pattern{Kind: KindBiweekly, Weekday: weekday, Summary: "Every other " + weekday.String()}
+	case !sameWeekday:
+		return Pattern{Kind: KindIrregular, Summary: fmt.Sprintf("%d dates", len(days))}
+	}
+
+	if week, ok := monthlyWeek(days); ok {
+		return Pattern{
+			Kind:    KindMonthly,
+			Weekday: weekday,
+			Week:    week,
+			Summary: fmt.Sprintf("%s %s of every month", ordinal(week), weekday),
+		}
+	}
+	return Pattern{Kind: KindWeekday, Weekday: weekday, Summary: weekday.String() + "s"}
+}
+
+// monthlyWeek reports the week of the month shared by dates that fall one
+// month apart on the same weekday: 1 to 4, or -1 when they are all in the
+// last week of the month
+func monthlyWeek(days []time.Time) (int, bool) {
+	for i := 1; i < len(days); i++ {
+		prev := days[i-1].Year()*12 + int(days[i-1].Month())
+		if days[i].Year()*12+int(days[i].Month()) != prev+1 {
+			return 0, false
+		}
+	}
+
+	week := weekOfMonth(days[0])
+	sameWeek, allLast := true, true
+	for _, day := range days {
+		if weekOfMonth(day) != week {
+			sameWeek = false
+		}
+		if !isLastWeek(day) {
+			allLast = false
+		}
+	}
+
+	switch {
+	case sameWeek && week < 5:
+		return week, true
+	case allLast:
+		return -1, true
+	}
+	return 0, false
+}
+
+func weekOfMonth(day time.Time) int {
+	return (day.Day()-1)/7 + 1
+}
+
+func isLastWeek(day time.Time) bool {
+	return day.AddDate(0, 0, 7).Month() != day.Month()
+}
+
+func ordinal(week int) string {
+	switch week {
+	case 1:
+		return "First"
+	case 2:
+		return "Second"
+	case 3:
+		return "Third"
+	case 4:
+		return "Fourth"
+	default:
+		return "Last"
+	}
+}
+
+// uniqueDays returns the dates at midnight UTC, sorted, without repeats
+func uniqueDays(dates []time.Time) []time.Time {
+	days := make([]time.Time, 0, len(dates))
+	for _, date := range dates {
+		year, month, day := date.Date()
+		days = append(days, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	}
+	slices.SortFunc(days, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(days, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+func allEqual(values []int, want int) bool {
+	for _, value := range values {
+		if value != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+package series
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Series is a row of ride_series
+type Series struct {
+	ID              string
+	City            string
+	Source          string
+	Title           string
+	Pattern         Pattern
+	FirstDate       string
+	LastDate        string
+	OccurrenceCount int
+}
+
+// New builds the series of a ride from its YYYY-MM-DD dates. Dates that don't
+// parse are ignored; it returns false when none do.
+func New(id, city, source, title string, dates []string) (Series, bool) {
+	var parsed []time.Time
+	for _, date := range dates {
+		if day, err := time.Parse("2006-01-02", date); err == nil {
+			parsed = append(parsed, day)
+		}
+	}
+	days := uniqueDays(parsed)
+	if len(days) == 0 {
+		return Series{}, false
+	}
+
+	return Series{
+		ID:              id,
+		City:            city,
+		Source:          source,
+		Title:           title,
+		Pattern:         Describe(days),
+		FirstDate:       days[0].Format("2006-01-02"),
+		LastDate:        days[len(days)-1].Format("2006-01-02"),
+		OccurrenceCount: len(days),
+	}, true
+}
+
+// Sync rebuilds the series of every scraped ride in the city that has a date
+// between since and until, from all of that ride's stored dates. Only series
+// that changed are written, and series whose dates were all removed are
+// deleted. It returns the number of series written or deleted.
+func Sync(db *sql.DB, city, since, until string) (int, error) {
+	rows, err := db.Query(`
+		SELECT ridesource, id, date, title, removed_at IS NULL
+		FROM shift2bikes_events
+		WHERE citycode = ? AND (ridesource, id) IN (
+			SELECT ridesource, id FROM shift2bikes_events
+			WHERE citycode = ? AND date BETWEEN ? AND ?
+		)
+		ORDER BY date
+	`, city, city, since, until)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load scraped ride dates: %w", err)
+	}
+
+	type ride struct {
+		source, id, title string
+		dates             []string
+	}
+	rides := make(map[string]*ride)
+	for rows.Next() {
+		var source, id, date, title string
+		var active bool
+		if err := rows.Scan(&source, &id, &date, &title, &active); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan scraped ride date: %w", err)
+		}
+		key := ID(source, id)
+		r, ok := rides[key]
+		if !ok {
+			r = &ride{source: source, id: id}
+			rides[key] = r
+		}
+		if active {
+			// rows come oldest first, so the title is the latest date's
+			r.title = title
+			r.dates = append(r.dates, date)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	stored, err := loadStored(db, city)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	written := 0
+	for key, r := range rides {
+		s, ok := New(key, city, r.source, r.title, r.dates)
+		if !ok {
+			if _, exists := stored[key]; exists {
+				if _, err := tx.Exec(`DELETE FROM ride_series WHERE series_id = ?`, key); err != nil {
+					return 0, fmt.Errorf("failed to delete series %s: %w", key, err)
+				}
+				written++
+			}
+			continue
+		}
+		if existing, exists := stored[key]; exists && existing == s {
+			continue
+		}
+		if err := upsert(tx, s); err != nil {
+			return 0, err
+		}
+		written++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// SyncSubmitted rebuilds the series of a submitted ride from its occurrences
+func SyncSubmitted(db *sql.DB, eventID int64) error {
+	var city, title string
+	if err := db.QueryRow(`SELECT city, title FROM events WHERE id = ?`, eventID).Scan(&city, &title); err != nil {
+		return fmt.Errorf("failed to load ride %d: %w", eventID, err)
+	}
+
+	rows, err := db.Query(`SELECT start_date FROM event_occurrences WHERE event_id = ?`, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to load occurrences of ride %d: %w", eventID, err)
+	}
+	defer rows.Close()
+
+	var dates []string
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return err
+		}
+		dates = append(dates, date)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	id := ID(SubmittedSource, strconv.FormatInt(eventID, 10))
+	s, ok := New(id, city, SubmittedSource, title, dates)
+	if !ok {
+		_, err := db.Exec(`DELETE FROM ride_series WHERE series_id = ?`, id)
+		return err
+	}
+	return upsert(db, s)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func upsert(db execer, s Series) error {
+	var weekday, week any
+	switch s.Pattern.Kind {
+	case KindWeekly, KindBiweekly, KindMonthly, KindWeekday:
+		weekday = int(s.Pattern.Weekday)
+	}
+	if s.Pattern.Kind == KindMonthly {
+		week = s.Pattern.Week
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO ride_series (
+			series_id, citycode, ridesource, title, recurrence, weekday, week, summary,
+			first_date, last_date, occurrence_count, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(series_id) DO UPDATE SET
+			citycode = excluded.citycode,
+			ridesource = excluded.ridesource,
+			title = excluded.title,
+			recurrence = excluded.recurrence,
+			weekday = excluded.weekday,
+			week = excluded.week,
+			summary = excluded.summary,
+			first_date = excluded.first_date,
+			last_date = excluded.last_date,
+			occurrence_count = excluded.occurrence_count,
+			updated_at = excluded.updated_at
+	`, s.ID, s.City, s.Source, s.Title, string(s.Pattern.Kind), weekday, week, s.Pattern.Summary,
+		s.FirstDate, s.LastDate, s.OccurrenceCount, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to write series %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// loadStored returns the city's stored series, keyed by series id
+func loadStored(db *sql.DB, city string) (map[string]Series, error) {
+	rows, err := db.Query(`
+		SELECT series_id, citycode, ridesource, title, recurrence, COALESCE(weekday, 0), COALESCE(week, 0),
+		       summary, first_date, last_date, occurrence_count
+		FROM ride_series
+		WHERE citycode = ?
+	`, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored series: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]Series)
+	for rows.Next() {
+		var s Series
+		var kind string
+		var weekday int
+		if err := rows.Scan(&s.ID, &s.City, &s.Source, &s.Title, &kind, &weekday, &s.Pattern.Week,
+			&s.Pattern.Summary, &s.FirstDate, &s.LastDate, &s.OccurrenceCount); err != nil {
+			return nil, fmt.Errorf("failed to scan stored series: %w", err)
+		}
+		s.Pattern.Kind = Kind(kind)
+		s.Pattern.Weekday = time.Weekday(weekday)
+		stored[s.ID] = s
+	}
+	return stored, rows.Err()
+}
@@ -0,0 +1,135 @@
+package tagging
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// submittedRide is the part of a submitted ride the rules are matched against
+type submittedRide struct {
+	id          int64
+	input       Input
+	submitter   []string
+	storedRules []string
+}
+
+// SyncSubmitted re-runs the rules over the city's submitted rides with a date
+// on or after fromDate, so edits to the rules reach rides nobody has edited
+// since. Tags their submitter picked are left alone. It returns the number of
+// rides retagged.
+func SyncSubmitted(db *sql.DB, city, fromDate string, t *Tagger) (int, error) {
+	rows, err := db.Query(`
+		SELECT e.id, COALESCE(e.audience, ''), e.title, e.description, COALESCE(e.ride_length, ''),
+		       COALESCE((SELECT o.start_time FROM event_occurrences o WHERE o.event_id = e.id
+		                 ORDER BY o.start_date, o.start_time LIMIT 1), '')
+		FROM events e
+		WHERE e.city = ?
+		  AND EXISTS (SELECT 1 FROM event_occurrences o WHERE o.event_id = e.id AND o.start_date >= ?)
+	`, city, fromDate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query submitted rides: %w", err)
+	}
+
+	var rides []*submittedRide
+	byID := make(map[int64]*submittedRide)
+	for rows.Next() {
+		var ride submittedRide
+		var title, description, rideLength string
+		if err := rows.Scan(&ride.id, &ride.input.Audience, &title, &description, &rideLength, &ride.input.StartTime); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan submitted ride: %w", err)
+		}
+		ride.input.City = city
+		ride.input.Text = []string{title, description, rideLength}
+		rides = append(rides, &ride)
+		byID[ride.id] = &ride
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("row iteration error: %w", err)
+	}
+	if len(rides) == 0 {
+		return 0, nil
+	}
+
+	tagRows, err := db.Query(`
+		SELECT t.event_id, t.tag, t.source
+		FROM event_tags t
+		JOIN events e ON e.id = t.event_id
+		WHERE e.city = ?
+	`, city)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query submitted ride tags: %w", err)
+	}
+	for tagRows.Next() {
+		var id int64
+		var tag, source string
+		if err := tagRows.Scan(&id, &tag, &source); err != nil {
+			tagRows.Close()
+			return 0, fmt.Errorf("failed to scan submitted ride tag: %w", err)
+		}
+		ride, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if source == "submitter" {
+			ride.submitter = append(ride.submitter, tag)
+		} else {
+			ride.storedRules = append(ride.storedRules, tag)
+		}
+	}
+	tagRows.Close()
+	if err = tagRows.Err(); err != nil {
+		return 0, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	// A tag that is both the submitter's and a rule's is kept as the
+	// submitter's, as when the ride is saved
+	retag := make(map[int64][]string)
+	for _, ride := range rides {
+		var ruleTags []string
+		for _, tag := range t.Tag(ride.input) {
+			if !slices.Contains(ride.submitter, tag) {
+				ruleTags = append(ruleTags, tag)
+			}
+		}
+		slices.Sort(ride.storedRules)
+		if !slices.Equal(ruleTags, ride.storedRules) {
+			retag[ride.id] = ruleTags
+		}
+	}
+	if len(retag) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tag transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for id, ruleTags := range retag {
+		if _, err = tx.Exec(`DELETE FROM event_tags WHERE event_id = ? AND source = 'rule'`, id); err != nil {
+			return 0, fmt.Errorf("failed to clear tags for ride %d: %w", id, err)
+		}
+		for _, tag := range ruleTags {
+			if _, err = tx.Exec(`
+				INSERT INTO event_tags (event_id, tag, source) VALUES (?, ?, 'rule')
+				ON CONFLICT(event_id, tag) DO NOTHING
+			`, id, tag); err != nil {
+				return 0, fmt.Errorf("failed to tag ride %d: %w", id, err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tags: %w", err)
+	}
+
+	return len(retag), nil
+}
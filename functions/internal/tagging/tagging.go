@@ -0,0 +1,246 @@
+// Package tagging labels rides with tags such as "family-friendly" or "gravel"
+// by matching keyword and regex rules against their title and description.
+// The same rules tag scraped rides in the scraper and submitted rides in the
+// API, so both end up with the same vocabulary.
+package tagging
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spacesedan/cyclescene/functions/internal/scraper"
+)
+
+//go:embed rules.json
+var defaultRulesJSON []byte
+
+// Tag is a label rides can carry
+type Tag struct {
+	Slug  string `json:"slug"`
+	Label string `json:"label"`
+}
+
+// Rule adds Tag to a ride when any of its conditions match. Keywords match
+// whole words or phrases, ignoring case, with spaces and hyphens treated alike.
+// Patterns are regular expressions, also matched ignoring case.
+type Rule struct {
+	Tag string `json:"tag"`
+	// Cities limits the rule to these city codes; empty applies everywhere
+	Cities   []string `json:"cities,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	// Audiences matches the ride's audience code, e.g. "F" for family
+	Audiences []string `json:"audiences,omitempty"`
+	// StartsAfter matches rides starting at or after this "15:04" time
+	StartsAfter string `json:"starts_after,omitempty"`
+}
+
+// Config is the set of tags and the rules that apply them
+type Config struct {
+	Tags  []Tag  `json:"tags"`
+	Rules []Rule `json:"rules"`
+}
+
+// Input is the part of a ride the rules are matched against
+type Input struct {
+	City      string
+	Audience  string
+	StartTime string
+	// Text is every free text field of the ride, such as title and details
+	Text []string
+}
+
+type compiledRule struct {
+	tag         string
+	cities      []string
+	matchers    []*regexp.Regexp
+	audiences   []string
+	startsAfter time.Duration
+	hasStart    bool
+}
+
+// Tagger applies a Config to rides
+type Tagger struct {
+	tags  []Tag
+	known map[string]bool
+	rules []compiledRule
+}
+
+// NewTagger compiles a Config, failing on rules for unknown tags or invalid
+// patterns
+func NewTagger(config Config) (*Tagger, error) {
+	t := &Tagger{known: make(map[string]bool, len(config.Tags))}
+	for _, tag := range config.Tags {
+		slug := NormalizeTag(tag.Slug)
+		if slug == "" {
+			return nil, fmt.Errorf("tag %q has no slug", tag.Label)
+		}
+		t.known[slug] = true
+		t.tags = append(t.tags, Tag{Slug: slug, Label: tag.Label})
+	}
+
+	for _, rule := range config.Rules {
+		compiled := compiledRule{tag: NormalizeTag(rule.Tag), cities: rule.Cities, audiences: rule.Audiences}
+		if !t.known[compiled.tag] {
+			return nil, fmt.Errorf("rule for unknown tag %q", rule.Tag)
+		}
+
+		for _, keyword := range rule.Keywords {
+			compiled.matchers = append(compiled.matchers, keywordRegexp(keyword))
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q for tag %q: %w", pattern, rule.Tag, err)
+			}
+			compiled.matchers = append(compiled.matchers, re)
+		}
+		if rule.StartsAfter != "" {
+			start, ok := scraper.ParseWallClock(rule.StartsAfter)
+			if !ok {
+				return nil, fmt.Errorf("invalid starts_after %q for tag %q", rule.StartsAfter, rule.Tag)
+			}
+			compiled.startsAfter, compiled.hasStart = start, true
+		}
+
+		t.rules = append(t.rules, compiled)
+	}
+
+	return t, nil
+}
+
+// LoadConfig reads a Config from a JSON file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return parseConfig(data)
+}
+
+// DefaultTagger returns a Tagger for the rules built into the binary
+func DefaultTagger() (*Tagger, error) {
+	config, err := parseConfig(defaultRulesJSON)
+	if err != nil {
+		return nil, err
+	}
+	return NewTagger(config)
+}
+
+// NewTaggerFromEnv returns a Tagger for the rules file at TAG_RULES_FILE, or
+// the built-in rules when it isn't set
+func NewTaggerFromEnv() (*Tagger, error) {
+	path := os.Getenv("TAG_RULES_FILE")
+	if path == "" {
+		return DefaultTagger()
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tag rules from %s: %w", path, err)
+	}
+	return NewTagger(config)
+}
+
+func parseConfig(data []byte) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse tag rules: %w", err)
+	}
+	return config, nil
+}
+
+// Tag returns the sorted slugs of every tag whose rules match the ride
+func (t *Tagger) Tag(in Input) []string {
+	matched := make(map[string]bool)
+	for _, rule := range t.rules {
+		if matched[rule.tag] || !rule.appliesTo(in.City) {
+			continue
+		}
+		if rule.matches(in) {
+			matched[rule.tag] = true
+		}
+	}
+
+	tags := make([]string, 0, len(matched))
+	for tag := range matched {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Known reports whether slug is one of the configured tags
+func (t *Tagger) Known(slug string) bool {
+	return t.known[NormalizeTag(slug)]
+}
+
+// TagsFor returns the tags that can be used in a city: those with a rule for
+// the city, and those with no rules at all, which only submitters apply
+func (t *Tagger) TagsFor(city string) []Tag {
+	hasRule := make(map[string]bool)
+	applies := make(map[string]bool)
+	for _, rule := range t.rules {
+		hasRule[rule.tag] = true
+		if rule.appliesTo(city) {
+			applies[rule.tag] = true
+		}
+	}
+
+	tags := []Tag{}
+	for _, tag := range t.tags {
+		if applies[tag.Slug] || !hasRule[tag.Slug] {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// NormalizeTag lower cases a tag and joins its words with hyphens, so
+// "Night Ride" and "night_ride" both become "night-ride"
+func NormalizeTag(tag string) string {
+	fields := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_' || r == '\t'
+	})
+	return strings.Join(fields, "-")
+}
+
+func (r compiledRule) appliesTo(city string) bool {
+	return len(r.cities) == 0 || slices.Contains(r.cities, city)
+}
+
+func (r compiledRule) matches(in Input) bool {
+	if in.Audience != "" && slices.Contains(r.audiences, in.Audience) {
+		return true
+	}
+	if r.hasStart {
+		if start, ok := scraper.ParseWallClock(in.StartTime); ok && start >= r.startsAfter {
+			return true
+		}
+	}
+	for _, re := range r.matchers {
+		for _, text := range in.Text {
+			if re.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keywordRegexp matches keyword as a whole word or phrase. Words may be
+// separated by any run of spaces or hyphens, so "no drop" matches "no-drop".
+func keywordRegexp(keyword string) *regexp.Regexp {
+	words := strings.FieldsFunc(keyword, func(r rune) bool {
+		return r == ' ' || r == '-'
+	})
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return regexp.MustCompile(`(?i)(?:^|[^\pL\pN])` + strings.Join(words, `[\s-]+`) + `(?:$|[^\pL\pN])`)
+}